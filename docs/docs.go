@@ -23,128 +23,67 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/login": {
-            "post": {
-                "description": "Validates user credentials and returns a JWT token upon successful authentication",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "auth"
-                ],
-                "summary": "Authenticate user and return JWT token",
-                "parameters": [
+        "/api/admin/health": {
+            "get": {
+                "security": [
                     {
-                        "description": "User credentials",
-                        "name": "credentials",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.LoginRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.TokenResponse"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
-                        }
+                        "BearerAuth": []
                     }
-                }
-            }
-        },
-        "/api/register": {
-            "post": {
-                "description": "Register a new user with the provided details",
-                "consumes": [
-                    "application/json"
                 ],
+                "description": "Reports every dependency check with its latency and error. Results are cached briefly.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
-                ],
-                "summary": "Register a new user",
-                "parameters": [
-                    {
-                        "description": "User registration info",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.UserCreateRequest"
-                        }
-                    }
+                    "health"
                 ],
+                "summary": "Detailed health report",
                 "responses": {
-                    "201": {
-                        "description": "Created user",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "400": {
-                        "description": "Bad request",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/go_api_src_internal_health.Report"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/go_api_src_internal_health.Report"
                         }
                     }
                 }
             }
         },
-        "/api/roles": {
+        "/api/groups": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get all roles",
+                "description": "List the groups with their roles",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "roles"
+                    "groups"
                 ],
-                "summary": "Get all roles",
+                "summary": "List groups",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/go_api_internal_models.Role"
+                                "$ref": "#/definitions/go_api_src_internal_models.Group"
                             }
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
@@ -155,25 +94,26 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Create a new role with the provided details",
+                "description": "Create a group without members or roles in the active organization",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "roles"
+                    "groups"
                 ],
-                "summary": "Create a new role",
+                "summary": "Create a group",
                 "parameters": [
                     {
-                        "description": "Role information",
-                        "name": "role",
+                        "description": "Group",
+                        "name": "group",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.Role"
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
                         }
                     }
                 ],
@@ -181,44 +121,57 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.Role"
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/roles/name/{name}": {
+        "/api/groups/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get a role by name",
+                "description": "Get a group with its roles",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "roles"
+                    "groups"
                 ],
-                "summary": "Get a role by name",
+                "summary": "Get a group by ID",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Role Name",
-                        "name": "name",
+                        "type": "integer",
+                        "description": "Group ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -227,192 +180,173 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.Role"
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/api/roles/{id}": {
-            "get": {
+            },
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get a role by ID",
-                "produces": [
+                "description": "Change the name of a group; members and roles are managed separately",
+                "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
                 "tags": [
-                    "roles"
+                    "groups"
                 ],
-                "summary": "Get a role by ID",
+                "summary": "Rename a group",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Role ID",
+                        "description": "Group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Group",
+                        "name": "group",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.Role"
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
-                    }
-                }
-            }
-        },
-        "/api/users": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Get all users",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "users"
-                ],
-                "summary": "Get all users",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/go_api_internal_models.User"
-                            }
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             },
-            "post": {
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Create a new user with the provided details",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Delete a group; its members lose the permissions of its roles",
                 "produces": [
-                    "application/json"
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "groups"
                 ],
-                "summary": "Create a new user",
+                "summary": "Delete a group",
                 "parameters": [
                     {
-                        "description": "User information",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.UserCreateRequest"
-                        }
+                        "type": "integer",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/users/email/{email}": {
+        "/api/groups/{id}/members": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get a user by email",
+                "description": "List the users in a group",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "groups"
                 ],
-                "summary": "Get a user by email",
+                "summary": "List the members of a group",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User Email",
-                        "name": "email",
+                        "type": "integer",
+                        "description": "Group ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -421,159 +355,171 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.User"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.User"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/users/password": {
-            "post": {
+        "/api/groups/{id}/members/{user_id}": {
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Change the current user's password",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Add a user to a group. Adding a member twice has no effect.",
                 "produces": [
-                    "application/json"
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "groups"
                 ],
-                "summary": "Change user password",
+                "summary": "Add a user to a group",
                 "parameters": [
                     {
-                        "description": "Old and new passwords",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/src_internal_handlers.ChangePasswordRequest"
-                        }
+                        "type": "integer",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/api/users/role/{role_id}": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get all users that have the specified role ID",
+                "description": "Remove a user from a group",
                 "produces": [
-                    "application/json"
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "groups"
                 ],
-                "summary": "Get users by role ID",
+                "summary": "Remove a user from a group",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Role ID",
-                        "name": "role_id",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/go_api_internal_models.User"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/users/username/{username}": {
-            "get": {
+        "/api/groups/{id}/roles/{role_id}": {
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get a user by username",
+                "description": "Give the members of a group the permissions of a role. The role must be shared or belong to the group's organization, and grant no permission the caller lacks unless the caller is a super-admin.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "groups"
                 ],
-                "summary": "Get a user by username",
+                "summary": "Attach a role to a group",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User Username",
-                        "name": "username",
+                        "type": "integer",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "role_id",
                         "in": "path",
                         "required": true
                     }
@@ -582,133 +528,322 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.User"
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/api/users/{id}": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get a user by ID",
+                "description": "Remove a role from a group",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "groups"
                 ],
-                "summary": "Get a user by ID",
+                "summary": "Detach a role from a group",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "role_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.User"
+                            "$ref": "#/definitions/go_api_src_internal_models.Group"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/invitations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the invitations that haven't been accepted, newest first, expired ones included",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "invitations"
+                ],
+                "summary": "List pending invitations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.Invitation"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             },
-            "put": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update an existing user",
+                "description": "Invite an email address into a role and mail the invitee a single-use link. The invitee chooses a username and password through /api/invitations/accept. The token is only returned here and on resend.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "invitations"
                 ],
-                "summary": "Update an existing user",
+                "summary": "Invite a user",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Email address and role",
+                        "name": "invitation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.InvitationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created invitation",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.InvitationCreated"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
                     },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/invitations/accept": {
+            "post": {
+                "description": "Create the invited user with the role and email address of the invitation and the chosen username and password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Accept an invitation",
+                "parameters": [
                     {
-                        "description": "User object to be updated",
-                        "name": "user",
+                        "description": "Invitation token and user details",
+                        "name": "invitation",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.User"
+                            "$ref": "#/definitions/go_api_src_internal_models.InvitationAcceptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created user",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired invitation, or registration closed",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Username or email already exists",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
+                }
+            }
+        },
+        "/api/invitations/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get an invitation with its role and, once accepted, the user who accepted it",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "invitations"
+                ],
+                "summary": "Get an invitation by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Invitation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.User"
+                            "$ref": "#/definitions/go_api_src_internal_models.Invitation"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
@@ -719,18 +854,18 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Delete a user",
+                "description": "Delete a pending invitation so its link can't be used",
                 "produces": [
-                    "application/json"
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "users"
+                    "invitations"
                 ],
-                "summary": "Delete a user",
+                "summary": "Revoke an invitation",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Invitation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -738,78 +873,3008 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "204": {
-                        "description": "No Content"
+                        "description": "Revoked"
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Invitation already accepted",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/go_api_internal_models.ErrorResponse"
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "go_api_internal_models.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "integer"
-                },
-                "message": {
-                    "type": "string"
-                }
-            }
-        },
-        "go_api_internal_models.LoginRequest": {
-            "type": "object",
-            "properties": {
-                "password": {
-                    "type": "string",
-                    "example": "password123"
-                },
-                "username": {
-                    "type": "string",
-                    "example": "admin"
-                }
-            }
         },
-        "go_api_internal_models.Role": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string",
-                    "example": "2023-04-01T12:00:00Z"
-                },
-                "id": {
-                    "type": "integer",
-                    "example": 1
-                },
-                "name": {
-                    "type": "string",
-                    "example": "admin"
-                },
-                "permissions": {
-                    "type": "array",
+        "/api/invitations/{id}/resend": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mail a pending invitation again with a new token and expiry; the previous link stops working",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "invitations"
+                ],
+                "summary": "Resend an invitation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Invitation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.InvitationCreated"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Invitation already accepted",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login": {
+            "post": {
+                "description": "Validates user credentials and returns a JWT token upon successful authentication",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Authenticate user and return JWT token",
+                "parameters": [
+                    {
+                        "description": "User credentials",
+                        "name": "credentials",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Password accepted, passkey assertion required",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.SecondFactorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "No authentication backend could be reached",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login/magic-link": {
+            "post": {
+                "description": "Emails a single-use, short-lived login link. The response is the same whether or not the address is known.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Request a magic login link",
+                "parameters": [
+                    {
+                        "description": "Email address",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.MagicLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login/magic-link/verify": {
+            "post": {
+                "description": "Exchanges a login link token for a JWT. Each link can be used once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Log in with a magic link",
+                "parameters": [
+                    {
+                        "description": "Login link token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.MagicLinkConsumeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Link accepted, passkey assertion required",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.SecondFactorResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login/webauthn/begin": {
+            "post": {
+                "description": "Returns PublicKeyCredentialRequestOptions for navigator.credentials.get(). Omit the username for a discoverable login.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Begin passkey login",
+                "parameters": [
+                    {
+                        "description": "Username",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCeremonyResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login/webauthn/finish": {
+            "post": {
+                "description": "Verifies the assertion returned by navigator.credentials.get() and returns a JWT",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Finish passkey login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session id from the begin step",
+                        "name": "session_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.TokenResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login/webauthn/second-factor/begin": {
+            "post": {
+                "description": "Exchanges the ticket returned by /api/login for PublicKeyCredentialRequestOptions",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Begin passkey second factor",
+                "parameters": [
+                    {
+                        "description": "Second-factor ticket",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnSecondFactorRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCeremonyResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/login/webauthn/second-factor/finish": {
+            "post": {
+                "description": "Verifies the passkey assertion and returns the JWT for the password login",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Finish passkey second factor",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session id from the begin step",
+                        "name": "session_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.TokenResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/organizations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the organizations of the current user; super-admins see every organization",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "List organizations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.Organization"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create an organization. Super-admins only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Create an organization",
+                "parameters": [
+                    {
+                        "description": "Organization",
+                        "name": "organization",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Organization"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Organization"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/organizations/{id}/members": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a user to an organization with a role, or change their role in it. The role must be shared or owned by the organization. Super-admins only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Add a member to an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User and role",
+                        "name": "membership",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.MembershipRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Membership"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/organizations/{id}/members/{user_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove a user from an organization; the account is kept. Super-admins only.",
+                "produces": [
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Remove a member from an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/organizations/{id}/switch": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue a new token acting in the organization. Members only; super-admins can switch to any organization, or to none with ID 0.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Switch the active organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/permissions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the permissions roles can grant, with what they allow and the routes requiring them",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "permissions"
+                ],
+                "summary": "List permissions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.Permission"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/policies/evaluate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Decide an action for the given subject and resource with the loaded policy, or the policy in the request, without performing it. With resource_id the resource is that user as the policy sees it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "policies"
+                ],
+                "summary": "Dry-run the access policy",
+                "parameters": [
+                    {
+                        "description": "Subject, action and resource",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.PolicyEvaluationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.PolicyDecision"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/register": {
+            "post": {
+                "description": "Register a new user with the provided details. The user gets the configured default role, or the role of the invitation given as invite_token. The registration mode may require an invitation or an allowed email domain.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register a new user",
+                "parameters": [
+                    {
+                        "description": "User registration info",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Registration closed, invitation required or invalid, or email domain not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Username or email already exists",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/roles": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all roles",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get all roles",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.Role"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new role with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Create a new role",
+                "parameters": [
+                    {
+                        "description": "Role information",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or unknown parent role",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/roles/name/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a role by name",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get a role by name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Role Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/roles/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a role by ID",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get a role by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the permissions and parent roles of a role. Roles inherit the permissions of their parents; a role can't become its own ancestor.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Update a role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Permissions and parent roles",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.RoleUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or unknown parent role",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/roles/{id}/magic-link": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Enable or disable magic-link login for a role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Magic-link setting",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.RoleMagicLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/setup": {
+            "post": {
+                "description": "Create the first administrator with the one-time setup token logged at startup. Fails once an administrator exists.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Create the first administrator",
+                "parameters": [
+                    {
+                        "description": "Setup token and administrator details",
+                        "name": "setup",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.SetupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created administrator",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or expired setup token",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "An administrator already exists",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all users",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get all users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.User"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new user with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "User information",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.UserCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/email/{email}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a user by email",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user by email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Email",
+                        "name": "email",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/password": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change the current user's password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Change user password",
+                "parameters": [
+                    {
+                        "description": "Old and new passwords",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/src_internal_handlers.ChangePasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/role/{role_id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all users that have the specified role ID",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get users by role ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Role ID",
+                        "name": "role_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.User"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/username/{username}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a user by username",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user by username",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a user by ID",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the profile, role and status of a user. Passwords, super-admin status and token revocations can't be changed here. Callers let through by a policy rule rather than the update permission keep the user's role and status. Within an organization only super-admins change the profile and status of users who are members of other organizations too, and only super-admins change super-admin accounts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update an existing user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User fields to update",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.UserUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a user",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}/effective-permissions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the permissions a user holds through their role and the roles of their groups, with the roles and groups granting each",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get the effective permissions of a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.EffectivePermissions"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webauthn/credentials": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "List my passkeys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCredential"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webauthn/credentials/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Rename one of my passkeys",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Passkey ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New name",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCredentialRenameRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Delete one of my passkeys",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Passkey ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webauthn/mfa": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Enables or disables passkeys as a second factor for the current user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Require a passkey after password login",
+                "parameters": [
+                    {
+                        "description": "Second factor setting",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.PasskeyMFARequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webauthn/register/begin": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns PublicKeyCredentialCreationOptions for navigator.credentials.create()",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Begin passkey registration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCeremonyResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webauthn/register/finish": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Verifies the attestation returned by navigator.credentials.create() and stores the passkey",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Finish passkey registration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session id from the begin step",
+                        "name": "session_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Display name for the passkey",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.WebAuthnCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Succeeds while the process is able to serve requests. It does not check dependencies.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks the database, the migration state and the signing keys. Fails during shutdown. Results are cached briefly; error details are only shown by /api/admin/health.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/go_api_src_internal_health.Report"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "go_api_src_internal_health.Report": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/go_api_src_internal_health.Result"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "go_api_src_internal_health.Result": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "go_api_src_internal_models.EffectivePermission": {
+            "type": "object",
+            "properties": {
+                "permission": {
+                    "type": "string",
+                    "example": "users.read"
+                },
+                "sources": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/go_api_src_internal_models.PermissionSource"
+                    }
+                }
+            }
+        },
+        "go_api_src_internal_models.EffectivePermissions": {
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/go_api_src_internal_models.EffectivePermission"
+                    }
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "username": {
+                    "type": "string",
+                    "example": "alice"
+                }
+            }
+        },
+        "go_api_src_internal_models.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "email"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be a valid email address"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "email"
+                }
+            }
+        },
+        "go_api_src_internal_models.Group": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "description": "Name may contain lower-case letters, digits, '_' and '-', and starts with a letter",
+                    "type": "string",
+                    "maxLength": 64,
+                    "minLength": 2,
+                    "example": "support"
+                },
+                "organization_id": {
+                    "description": "OrganizationID is the organization owning the group; groups created outside\norganizations have none",
+                    "type": "integer",
+                    "example": 1
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/go_api_src_internal_models.Role"
+                    }
+                }
+            }
+        },
+        "go_api_src_internal_models.Invitation": {
+            "type": "object",
+            "properties": {
+                "accepted_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "new.admin@example.com"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "invited_by": {
+                    "type": "string",
+                    "example": "admin"
+                },
+                "organization_id": {
+                    "description": "OrganizationID is the organization the invitee joins, the inviter's active one",
+                    "type": "integer",
+                    "example": 1
+                },
+                "role": {
+                    "$ref": "#/definitions/go_api_src_internal_models.Role"
+                },
+                "role_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "user": {
+                    "$ref": "#/definitions/go_api_src_internal_models.User"
+                },
+                "user_id": {
+                    "description": "the user who registered with it",
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "go_api_src_internal_models.InvitationAcceptRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "token",
+                "username"
+            ],
+            "properties": {
+                "first": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "John"
+                },
+                "last": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Doe"
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "strongpassword123"
+                },
+                "phone": {
+                    "description": "Phone is a number in E.164 format, e.g. +14155550123",
+                    "type": "string",
+                    "example": "+1234567890"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "Jx0w5v...8Qk"
+                },
+                "username": {
+                    "description": "Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit",
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
+                    "example": "johndoe"
+                }
+            }
+        },
+        "go_api_src_internal_models.InvitationCreated": {
+            "type": "object",
+            "properties": {
+                "accepted_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "new.admin@example.com"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "invited_by": {
+                    "type": "string",
+                    "example": "admin"
+                },
+                "organization_id": {
+                    "description": "OrganizationID is the organization the invitee joins, the inviter's active one",
+                    "type": "integer",
+                    "example": 1
+                },
+                "role": {
+                    "$ref": "#/definitions/go_api_src_internal_models.Role"
+                },
+                "role_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "token": {
+                    "type": "string",
+                    "example": "Jx0w5v...8Qk"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "http://localhost:8081/register?invite=Jx0w5v...8Qk"
+                },
+                "user": {
+                    "$ref": "#/definitions/go_api_src_internal_models.User"
+                },
+                "user_id": {
+                    "description": "the user who registered with it",
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "go_api_src_internal_models.InvitationRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "role_id"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "format": "email",
+                    "maxLength": 254,
+                    "example": "new.admin@example.com"
+                },
+                "role_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "go_api_src_internal_models.LoginRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "maxLength": 1024,
+                    "example": "password123"
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 254,
+                    "example": "admin"
+                }
+            }
+        },
+        "go_api_src_internal_models.MagicLinkConsumeRequest": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "example": "q3ZfV1...Zk2w"
+                }
+            }
+        },
+        "go_api_src_internal_models.MagicLinkRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "format": "email",
+                    "maxLength": 254,
+                    "example": "user@example.com"
+                }
+            }
+        },
+        "go_api_src_internal_models.Membership": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "organization": {
+                    "$ref": "#/definitions/go_api_src_internal_models.Organization"
+                },
+                "organization_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "role": {
+                    "$ref": "#/definitions/go_api_src_internal_models.Role"
+                },
+                "role_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "go_api_src_internal_models.MembershipRequest": {
+            "type": "object",
+            "required": [
+                "role_id",
+                "user_id"
+            ],
+            "properties": {
+                "role_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "go_api_src_internal_models.Organization": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "description": "Name may contain lower-case letters, digits, '_' and '-', and starts with a letter",
+                    "type": "string",
+                    "maxLength": 64,
+                    "minLength": 2,
+                    "example": "acme"
+                }
+            }
+        },
+        "go_api_src_internal_models.PasskeyMFARequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "go_api_src_internal_models.Permission": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Read users and their effective permissions"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "users.read"
+                },
+                "routes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "GET /api/users"
+                    ]
+                }
+            }
+        },
+        "go_api_src_internal_models.PermissionSource": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string",
+                    "example": "support"
+                },
+                "group_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "inherited_from": {
+                    "type": "string",
+                    "example": "viewer"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "guest"
+                },
+                "role_id": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "go_api_src_internal_models.PolicyDecision": {
+            "type": "object",
+            "properties": {
+                "decision": {
+                    "description": "Decision is allow, deny or not_applicable, when the route's permission decides",
+                    "type": "string",
+                    "example": "allow"
+                },
+                "matched": {
+                    "description": "Matched lists every matching rule in policy order",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "resource": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "rule": {
+                    "description": "Rule is the rule deciding, empty when none matched",
+                    "type": "string",
+                    "example": "users-update-themselves"
+                }
+            }
+        },
+        "go_api_src_internal_models.PolicyEvaluationRequest": {
+            "type": "object",
+            "required": [
+                "action",
+                "subject"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "user.update"
+                },
+                "policy": {
+                    "description": "Policy is a YAML policy to evaluate instead of the loaded one",
+                    "type": "string",
+                    "maxLength": 65536
+                },
+                "resource": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "resource_id": {
+                    "description": "ResourceID loads the attributes of that user in the active organization; Resource\nadds to or overrides them",
+                    "type": "integer",
+                    "example": 7
+                },
+                "subject": {
+                    "description": "Subject are the attributes of the caller: username, role, organization_id,\nsuper_admin and permissions",
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "go_api_src_internal_models.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is one of the enums listed for the schema. Codes may be added, so clients\nshould fall back on Status for a code they don't know",
+                    "type": "string",
+                    "enum": [
+                        "authentication_unavailable",
+                        "body_too_large",
+                        "email_domain_not_allowed",
+                        "group_exists",
+                        "group_member_not_found",
+                        "group_not_found",
+                        "group_role_not_found",
+                        "incorrect_password",
+                        "insufficient_permissions",
+                        "internal_error",
+                        "invalid_credentials",
+                        "invalid_id",
+                        "invalid_invitation",
+                        "invalid_magic_link",
+                        "invalid_policy",
+                        "invalid_request",
+                        "invalid_setup_token",
+                        "invalid_ticket",
+                        "invalid_token",
+                        "invitation_accepted",
+                        "invitation_not_found",
+                        "invitation_required",
+                        "magic_link_rate_limited",
+                        "membership_not_found",
+                        "missing_token",
+                        "no_passkeys",
+                        "not_authenticated",
+                        "not_member",
+                        "organization_exists",
+                        "organization_not_found",
+                        "parent_role_not_found",
+                        "passkey_not_found",
+                        "passkey_registration_failed",
+                        "policy_denied",
+                        "rate_limited",
+                        "registration_closed",
+                        "role_cycle",
+                        "role_escalation",
+                        "role_exists",
+                        "role_not_found",
+                        "route_not_found",
+                        "setup_complete",
+                        "shared_account",
+                        "shared_role",
+                        "super_admin_user",
+                        "token_revoked",
+                        "user_exists",
+                        "user_not_found",
+                        "validation_failed",
+                        "webauthn_session_invalid"
+                    ],
+                    "example": "user_not_found"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "User not found"
+                },
+                "errors": {
+                    "description": "Errors lists the failed rules of a 422 validation_failed problem",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/go_api_src_internal_models.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/users/42"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "8c1f0b6e2d4a4f7e9b3a5c7d9e1f2a3b"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "go_api_src_internal_models.RegisterRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "password",
+                "username"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "format": "email",
+                    "maxLength": 254,
+                    "example": "user@example.com"
+                },
+                "first": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "John"
+                },
+                "invite_token": {
+                    "type": "string",
+                    "example": "Jx0w5v...8Qk"
+                },
+                "last": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Doe"
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "strongpassword123"
+                },
+                "phone": {
+                    "description": "Phone is a number in E.164 format, e.g. +14155550123",
+                    "type": "string",
+                    "example": "+1234567890"
+                },
+                "username": {
+                    "description": "Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit",
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
+                    "example": "johndoe"
+                }
+            }
+        },
+        "go_api_src_internal_models.Role": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2023-04-01T12:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "magic_link_enabled": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "description": "Name may contain lower-case letters, digits, '_' and '-', and starts with a letter",
+                    "type": "string",
+                    "maxLength": 64,
+                    "minLength": 2,
+                    "example": "admin"
+                },
+                "organization_id": {
+                    "description": "OrganizationID is the organization owning the role; shared roles have none",
+                    "type": "integer",
+                    "example": 1
+                },
+                "parent_ids": {
+                    "description": "ParentIDs are the roles this role inherits the permissions of",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2
+                    ]
+                },
+                "permissions": {
+                    "description": "Permissions are names listed by GET /api/permissions",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "users.read",
+                        "users.create",
+                        "users.update"
+                    ]
+                }
+            }
+        },
+        "go_api_src_internal_models.RoleMagicLinkRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "go_api_src_internal_models.RoleUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "parent_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2
+                    ]
+                },
+                "permissions": {
+                    "description": "Permissions are names listed by GET /api/permissions",
+                    "type": "array",
                     "items": {
                         "type": "string"
                     },
                     "example": [
-                        "[\"read\"",
-                        "\"write\"",
-                        "\"delete\"]"
+                        "users.read",
+                        "users.update"
                     ]
                 }
             }
         },
-        "go_api_internal_models.TokenResponse": {
+        "go_api_src_internal_models.SecondFactorResponse": {
+            "type": "object",
+            "properties": {
+                "second_factor_required": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "ticket": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
+        "go_api_src_internal_models.SetupRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "password",
+                "token",
+                "username"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "format": "email",
+                    "maxLength": 254,
+                    "example": "admin@example.com"
+                },
+                "first": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Ada"
+                },
+                "last": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Admin"
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "strongpassword123"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "Jx0w5v...8Qk"
+                },
+                "username": {
+                    "description": "Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit",
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
+                    "example": "admin"
+                }
+            }
+        },
+        "go_api_src_internal_models.TokenResponse": {
             "type": "object",
             "properties": {
                 "token": {
@@ -818,79 +3883,260 @@ const docTemplate = `{
                 }
             }
         },
-        "go_api_internal_models.User": {
+        "go_api_src_internal_models.User": {
             "type": "object",
+            "required": [
+                "email",
+                "username"
+            ],
             "properties": {
+                "auth_source": {
+                    "type": "string",
+                    "example": "local"
+                },
                 "created_at": {
                     "type": "string"
                 },
+                "disabled": {
+                    "type": "boolean"
+                },
                 "email": {
-                    "type": "string"
+                    "type": "string",
+                    "format": "email",
+                    "maxLength": 254
                 },
                 "first": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 100
                 },
                 "id": {
                     "type": "integer"
                 },
                 "last": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "passkey_mfa": {
+                    "type": "boolean"
                 },
                 "phone": {
+                    "description": "Phone is a number in E.164 format, e.g. +14155550123",
                     "type": "string"
                 },
                 "role": {
                     "description": "Ensure this tag is correct",
                     "allOf": [
                         {
-                            "$ref": "#/definitions/go_api_internal_models.Role"
+                            "$ref": "#/definitions/go_api_src_internal_models.Role"
                         }
                     ]
                 },
                 "role_id": {
+                    "description": "RoleID is the user's role in the active organization; outside organizations it is\nthe role the account was created with",
                     "type": "integer"
                 },
+                "super_admin": {
+                    "description": "SuperAdmin users hold every permission and can act in any organization",
+                    "type": "boolean"
+                },
                 "updated_at": {
                     "type": "string"
                 },
                 "username": {
-                    "type": "string"
+                    "description": "Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit",
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3
                 }
             }
         },
-        "go_api_internal_models.UserCreateRequest": {
+        "go_api_src_internal_models.UserCreateRequest": {
             "type": "object",
+            "required": [
+                "email",
+                "password",
+                "username"
+            ],
             "properties": {
                 "email": {
                     "type": "string",
+                    "format": "email",
+                    "maxLength": 254,
                     "example": "user@example.com"
                 },
                 "first": {
                     "type": "string",
+                    "maxLength": 100,
                     "example": "John"
                 },
                 "last": {
                     "type": "string",
+                    "maxLength": 100,
                     "example": "Doe"
                 },
                 "password": {
                     "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
                     "example": "strongpassword123"
                 },
                 "phone": {
+                    "description": "Phone is a number in E.164 format, e.g. +14155550123",
+                    "type": "string",
+                    "example": "+1234567890"
+                },
+                "role_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "username": {
+                    "description": "Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit",
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
+                    "example": "johndoe"
+                }
+            }
+        },
+        "go_api_src_internal_models.UserUpdateRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "username"
+            ],
+            "properties": {
+                "disabled": {
+                    "description": "Disabled disables or re-enables the account; omitted keeps the current status",
+                    "type": "boolean",
+                    "example": false
+                },
+                "email": {
+                    "type": "string",
+                    "format": "email",
+                    "maxLength": 254,
+                    "example": "user@example.com"
+                },
+                "first": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "John"
+                },
+                "last": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Doe"
+                },
+                "phone": {
+                    "description": "Phone is a number in E.164 format, e.g. +14155550123",
                     "type": "string",
                     "example": "+1234567890"
                 },
                 "role_id": {
+                    "description": "RoleID is the user's role in the active organization; 0 keeps the current role",
+                    "type": "integer",
+                    "example": 1
+                },
+                "username": {
+                    "description": "Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit",
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
+                    "example": "johndoe"
+                }
+            }
+        },
+        "go_api_src_internal_models.WebAuthnCeremonyResponse": {
+            "type": "object",
+            "properties": {
+                "options": {},
+                "session_id": {
+                    "type": "string",
+                    "example": "4kq1F9n0S2y..."
+                }
+            }
+        },
+        "go_api_src_internal_models.WebAuthnCredential": {
+            "type": "object",
+            "properties": {
+                "attestation_type": {
+                    "type": "string",
+                    "example": "none"
+                },
+                "backup_eligible": {
+                    "type": "boolean"
+                },
+                "backup_state": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer",
                     "example": 1
                 },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "MacBook Touch ID"
+                },
+                "sign_count": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "transports": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "internal",
+                        "hybrid"
+                    ]
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "go_api_src_internal_models.WebAuthnCredentialRenameRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "YubiKey 5C"
+                }
+            }
+        },
+        "go_api_src_internal_models.WebAuthnLoginRequest": {
+            "type": "object",
+            "properties": {
                 "username": {
                     "type": "string",
+                    "maxLength": 254,
                     "example": "johndoe"
                 }
             }
         },
+        "go_api_src_internal_models.WebAuthnSecondFactorRequest": {
+            "type": "object",
+            "required": [
+                "ticket"
+            ],
+            "properties": {
+                "ticket": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
         "src_internal_handlers.ChangePasswordRequest": {
             "type": "object",
             "required": [
@@ -899,10 +4145,13 @@ const docTemplate = `{
             ],
             "properties": {
                 "newPassword": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8
                 },
                 "oldPassword": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 1024
                 }
             }
         }
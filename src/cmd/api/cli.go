@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"go_api/src/internal/config"
+	"go_api/src/internal/logging"
+	"go_api/src/internal/routes"
+	"go_api/src/internal/validation"
+)
+
+// Exit codes of the commands
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// command is a subcommand of the binary, named by one or two words such as "user create"
+type command struct {
+	name    string
+	args    string // positional arguments, for the usage line
+	summary string
+	run     func(args []string) error
+}
+
+// commands is filled in by init, since usage refers back to it
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "run the API server, the default command", runServe},
+		{"migrate", "up|down|to VERSION|status", "apply or roll back database migrations", runMigrate},
+		{"user create", "", "create a user, generating a password unless -password is given", runUserCreate},
+		{"user list", "", "list users", runUserList},
+		{"user disable", "USERNAME", "disable a user; their tokens are rejected", runUserDisable},
+		{"user enable", "USERNAME", "enable a disabled user", runUserEnable},
+		{"user reset-password", "USERNAME", "set a new password and revoke the user's tokens", runUserResetPassword},
+		{"role create", "NAME [PERMISSION...]", "create a role", runRoleCreate},
+		{"role grant", "NAME PERMISSION...", "add permissions to a role", runRoleGrant},
+		{"role revoke", "NAME PERMISSION...", "remove permissions from a role", runRoleRevoke},
+		{"role inherit", "NAME PARENT...", "make a role inherit the permissions of parent roles", runRoleInherit},
+		{"org create", "NAME", "create an organization", runOrgCreate},
+		{"org list", "", "list organizations", runOrgList},
+		{"org add-member", "ORGANIZATION USERNAME ROLE", "add a user to an organization, or change their role in it", runOrgAddMember},
+		{"token revoke", "USERNAME | -all", "reject the tokens issued so far", runTokenRevoke},
+		{"keys rotate", "", "generate a new JWT signing key, keeping the current one for verification", runKeysRotate},
+		{"seed", "", "create the built-in roles when they are missing", runSeed},
+	}
+}
+
+// errBadFlags is returned after the flag package reported a parse error and printed the usage
+var errBadFlags = errors.New("invalid flags")
+
+// usageError is a command line mistake; it exits with exitUsage
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// run executes the command named by the leading arguments and returns the exit code.
+// Without a command name, e.g. "api -pg-host db", the server is started.
+func run(args []string) int {
+	if len(args) > 0 && args[0] == "help" {
+		printUsage()
+		return exitOK
+	}
+
+	cmd, args, err := findCommand(args)
+	if err == nil {
+		err = cmd.run(args)
+	}
+
+	var usageErr *usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errBadFlags):
+		return exitUsage
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(os.Stderr, "error:", err)
+		fmt.Fprintln(os.Stderr, "Run 'api help' for usage.")
+		return exitUsage
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+}
+
+func findCommand(args []string) (command, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, nil
+	}
+	for _, words := range []int{2, 1} {
+		if len(args) < words {
+			continue
+		}
+		name := strings.Join(args[:words], " ")
+		for _, cmd := range commands {
+			if cmd.name == name {
+				return cmd, args[words:], nil
+			}
+		}
+	}
+	return command{}, nil, usageErrorf("unknown command %q", args[0])
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: api [command] [flags] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Every command accepts the configuration flags, see api <command> -h.")
+}
+
+// timeFormat is how table output shows times
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+// outputFormat is how a command prints its result
+type outputFormat string
+
+const (
+	outputTable outputFormat = "table"
+	outputJSON  outputFormat = "json"
+)
+
+// invocation is a command's configuration, flags and positional arguments
+type invocation struct {
+	cfg    *config.Config
+	args   []string
+	output outputFormat
+}
+
+// parseCommand loads the configuration the same way the server does, with the command's
+// own flags added by define, and logs to stderr so stdout only has the command's output.
+// Every command but serve, which only logs, gets -output.
+func parseCommand(name string, args []string, define func(*flag.FlagSet)) (*invocation, error) {
+	flags := flag.NewFlagSet("api "+name, flag.ContinueOnError)
+	output := string(outputTable)
+	if name != "serve" {
+		flags.StringVar(&output, "output", string(outputTable), "output format, table or json")
+	}
+	if define != nil {
+		define(flags)
+	}
+	// The flag package prints the usage before returning a parse error
+	var badFlags bool
+	defaultUsage := flags.Usage
+	flags.Usage = func() {
+		badFlags = true
+		defaultUsage()
+	}
+
+	cfg, err := config.LoadFlags(flags, args, os.LookupEnv)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return nil, err
+	case err != nil && badFlags:
+		return nil, errBadFlags
+	case err != nil:
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log))
+
+	inv := &invocation{cfg: cfg, args: flags.Args(), output: outputFormat(output)}
+	if inv.output != outputTable && inv.output != outputJSON {
+		return nil, usageErrorf("-output must be table or json, not %q", output)
+	}
+	return inv, nil
+}
+
+// print writes v as indented JSON, or as a table of rows under header
+func (inv *invocation) print(v any, header []string, rows [][]string) error {
+	if inv.output == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// validate checks v against its binding rules, the same ones the API applies to request bodies
+func validate(v any) error {
+	if err := validation.Setup(); err != nil {
+		return err
+	}
+	validation.RegisterPermissions(routes.Permissions()...)
+
+	err := binding.Validator.ValidateStruct(v)
+	if fields, ok := validation.Fields(err); ok {
+		problems := make([]string, 0, len(fields))
+		for _, field := range fields {
+			problems = append(problems, field.Field+" "+field.Message)
+		}
+		return fmt.Errorf("invalid input: %s", strings.Join(problems, "; "))
+	}
+	return err
+}
+
+// expectArgs checks the number of positional arguments, at least minArgs and at most
+// maxArgs; a negative maxArgs allows any number
+func (inv *invocation) expectArgs(minArgs, maxArgs int, names string) error {
+	if len(inv.args) < minArgs || (maxArgs >= 0 && len(inv.args) > maxArgs) {
+		return usageErrorf("expected arguments: %s", names)
+	}
+	return nil
+}
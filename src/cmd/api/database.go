@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/config"
+	"go_api/src/internal/logging"
+	"go_api/src/internal/migrate"
+	"go_api/src/internal/models"
+)
+
+// openDatabase connects to Postgres, retrying while the database starts up
+func openDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
+	maxRetries := 10
+	retryInterval := 5 * time.Second
+
+	slog.Info("Connecting to the database", "host", cfg.Host, "port", cfg.Port, "database", cfg.Name)
+
+	for i := 0; i < maxRetries; i++ {
+		db, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+			Logger:         logging.GormLogger(),
+			TranslateError: true,
+		})
+		if err == nil {
+			sqlDB, _ := db.DB()
+			err = sqlDB.Ping()
+		}
+		if err == nil {
+			slog.Info("Successfully connected to the database")
+			return db, nil
+		}
+		slog.Warn("Failed to connect to DB, retrying", "attempt", i+1, "max_attempts", maxRetries, "retry_in", retryInterval.String(), "err", err)
+		time.Sleep(retryInterval)
+	}
+	return nil, err
+}
+
+// connect opens the database for a command that isn't the server and returns a function closing it
+func connect(cfg *config.Config) (*gorm.DB, func(), error) {
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to the database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() { sqlDB.Close() }, nil
+}
+
+// prepareSchema applies pending migrations when apply is set and otherwise only reports
+// them, then checks that the tables match the models
+func prepareSchema(ctx context.Context, db *gorm.DB, apply bool) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	if apply {
+		if _, err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	} else {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			slog.Warn("Database has pending migrations, run \"migrate up\"", "pending", len(pending), "latest", migrator.Latest())
+		}
+	}
+	return migrate.CheckSchema(ctx, db, models.All()...)
+}
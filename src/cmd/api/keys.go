@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keysResult is printed by "keys rotate"
+type keysResult struct {
+	JWTKey          string   `json:"jwt_key"`
+	JWTPreviousKeys []string `json:"jwt_previous_keys"`
+}
+
+// runKeysRotate implements "keys rotate". It only prints the settings: the new key signs
+// tokens once it is deployed as JWT_KEY, while the current one, moved to JWT_PREVIOUS_KEYS,
+// keeps the tokens it signed valid until they expire.
+func runKeysRotate(args []string) error {
+	inv, err := parseCommand("keys rotate", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(0, 0, "none"); err != nil {
+		return err
+	}
+
+	b := make([]byte, 48)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	result := keysResult{
+		JWTKey:          base64.RawURLEncoding.EncodeToString(b),
+		JWTPreviousKeys: append([]string{inv.cfg.JWTKey}, inv.cfg.JWTPreviousKeys...),
+	}
+
+	if inv.output == outputJSON {
+		return inv.print(result, nil, nil)
+	}
+	// Table output is in dotenv format, ready to paste into the configuration
+	fmt.Printf("JWT_KEY=%s\n", result.JWTKey)
+	fmt.Printf("JWT_PREVIOUS_KEYS=%s\n", strings.Join(result.JWTPreviousKeys, ","))
+	fmt.Fprintln(os.Stderr, "Deploy both settings, then drop the previous keys once their tokens have expired (24 hours).")
+	if string(inv.cfg.MagicLink.Key) == inv.cfg.JWTKey {
+		fmt.Fprintln(os.Stderr, "Login links are signed with JWT_KEY too, links sent before the rotation stop working.")
+	}
+	return nil
+}
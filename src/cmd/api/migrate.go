@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/migrate"
+	"go_api/src/internal/models"
+)
+
+// runMigrate implements the migrate command
+func runMigrate(args []string) error {
+	inv, err := parseCommand("migrate", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(1, 2, "up|down|to VERSION|status"); err != nil {
+		return err
+	}
+
+	command := inv.args[0]
+	var version int64
+	switch command {
+	case "to":
+		if err := inv.expectArgs(2, 2, "to VERSION"); err != nil {
+			return err
+		}
+		if version, err = strconv.ParseInt(inv.args[1], 10, 64); err != nil || version < 0 {
+			return usageErrorf("invalid migration version %q", inv.args[1])
+		}
+	case "up", "down", "status":
+		if err := inv.expectArgs(1, 1, command); err != nil {
+			return err
+		}
+	default:
+		return usageErrorf("unknown migrate command %q", command)
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var ran []migrate.Migration
+	switch command {
+	case "up":
+		ran, err = migrator.Up(ctx)
+	case "down":
+		var rolledBack *migrate.Migration
+		if rolledBack, err = migrator.Down(ctx); rolledBack != nil {
+			ran = append(ran, *rolledBack)
+		}
+	case "to":
+		ran, err = migrator.To(ctx, version)
+	case "status":
+		return migrateStatus(ctx, inv, db, migrator)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", command, err)
+	}
+
+	results := make([]migrationResult, 0, len(ran))
+	rows := make([][]string, 0, len(ran))
+	for _, migration := range ran {
+		results = append(results, migrationResult{Version: migration.Version, Name: migration.Name})
+		rows = append(rows, []string{strconv.FormatInt(migration.Version, 10), migration.Name})
+	}
+	return inv.print(results, []string{"VERSION", "NAME"}, rows)
+}
+
+// migrationResult is a migration as printed by the migrate command, without its scripts
+type migrationResult struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status,omitempty"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrateStatus lists the migrations and fails when the schema doesn't match the models
+func migrateStatus(ctx context.Context, inv *invocation, db *gorm.DB, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("reading migration status: %w", err)
+	}
+
+	results := make([]migrationResult, 0, len(statuses))
+	rows := make([][]string, 0, len(statuses))
+	for _, status := range statuses {
+		result := migrationResult{Version: status.Version, Name: status.Name, Status: "pending"}
+		appliedAt := ""
+		if status.Applied {
+			result.Status, result.AppliedAt = "applied", &status.AppliedAt
+			appliedAt = status.AppliedAt.Format(timeFormat)
+		}
+		if status.Modified {
+			result.Status = "modified"
+		}
+		results = append(results, result)
+		rows = append(rows, []string{strconv.FormatInt(status.Version, 10), status.Name, result.Status, appliedAt})
+	}
+	if err := inv.print(results, []string{"VERSION", "NAME", "STATUS", "APPLIED AT"}, rows); err != nil {
+		return err
+	}
+	return migrate.CheckSchema(ctx, db, models.All()...)
+}
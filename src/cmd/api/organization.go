@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// organizationResult is an organization as printed by the org commands
+type organizationResult struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+func printOrganizations(inv *invocation, organizations []models.Organization) error {
+	results := make([]organizationResult, 0, len(organizations))
+	rows := make([][]string, 0, len(organizations))
+	for _, organization := range organizations {
+		result := organizationResult{ID: organization.ID, Name: organization.Name, CreatedAt: organization.CreatedAt.Format(timeFormat)}
+		results = append(results, result)
+		rows = append(rows, []string{strconv.FormatUint(uint64(result.ID), 10), result.Name, result.CreatedAt})
+	}
+	return inv.print(results, []string{"ID", "NAME", "CREATED AT"}, rows)
+}
+
+// runOrgList implements "org list"
+func runOrgList(args []string) error {
+	inv, err := parseCommand("org list", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(0, 0, "none"); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	organizations, err := services.NewOrganizationService(db).ListOrganizations(context.Background(), "")
+	if err != nil {
+		return err
+	}
+	return printOrganizations(inv, organizations)
+}
+
+// runOrgCreate implements "org create"
+func runOrgCreate(args []string) error {
+	inv, err := parseCommand("org create", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(1, 1, "NAME"); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	organization := &models.Organization{Name: inv.args[0]}
+	if err := validate(organization); err != nil {
+		return err
+	}
+	if err := services.NewOrganizationService(db).CreateOrganization(context.Background(), organization); err != nil {
+		return err
+	}
+	return printOrganizations(inv, []models.Organization{*organization})
+}
+
+// runOrgAddMember implements "org add-member". The role is looked up among the shared
+// roles and those of the organization.
+func runOrgAddMember(args []string) error {
+	inv, err := parseCommand("org add-member", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(3, 3, "ORGANIZATION USERNAME ROLE"); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	ctx := context.Background()
+	organizationService := services.NewOrganizationService(db)
+
+	organization, err := organizationService.GetOrganizationByName(ctx, inv.args[0])
+	if err != nil {
+		return err
+	}
+	user, err := services.NewUserService(db).GetUserByUsername(ctx, inv.args[1])
+	if err != nil {
+		return err
+	}
+	orgCtx := services.WithTenant(ctx, services.Tenant{OrganizationID: organization.ID})
+	role, err := services.NewRoleService(db).GetRoleByName(orgCtx, inv.args[2])
+	if err != nil {
+		return err
+	}
+
+	if _, err := organizationService.SetMember(ctx, organization.ID, &models.MembershipRequest{UserID: user.ID, RoleID: role.ID}); err != nil {
+		return err
+	}
+	user.Role = *role
+	return printUsers(inv, []userResult{newUserResult(user)})
+}
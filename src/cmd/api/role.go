@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/routes"
+	"go_api/src/internal/services"
+)
+
+// roleResult is a role as printed by the role commands
+type roleResult struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	ParentIDs   []int64  `json:"parent_ids"`
+	// Created is set by seed, which leaves existing roles alone
+	Created *bool `json:"created,omitempty"`
+}
+
+func newRoleResult(role *models.Role) roleResult {
+	return roleResult{ID: role.ID, Name: role.Name, Permissions: append([]string{}, role.Permissions...), ParentIDs: append([]int64{}, role.ParentIDs...)}
+}
+
+func printRoles(inv *invocation, results []roleResult) error {
+	header := []string{"ID", "NAME", "PERMISSIONS", "PARENTS"}
+	if len(results) > 0 && results[0].Created != nil {
+		header = append(header, "CREATED")
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		parents := make([]string, 0, len(result.ParentIDs))
+		for _, id := range result.ParentIDs {
+			parents = append(parents, strconv.FormatInt(id, 10))
+		}
+		row := []string{strconv.FormatUint(uint64(result.ID), 10), result.Name, strings.Join(result.Permissions, ","), strings.Join(parents, ",")}
+		if result.Created != nil {
+			row = append(row, strconv.FormatBool(*result.Created))
+		}
+		rows = append(rows, row)
+	}
+	return inv.print(results, header, rows)
+}
+
+// runRoleCreate implements "role create"
+func runRoleCreate(args []string) error {
+	inv, err := parseCommand("role create", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(1, -1, "NAME [PERMISSION...]"); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	roleService := services.NewRoleService(db)
+
+	role := &models.Role{Name: inv.args[0], Permissions: inv.args[1:]}
+	if err := validate(role); err != nil {
+		return err
+	}
+	if err := roleService.CreateRole(context.Background(), role); err != nil {
+		return err
+	}
+	return printRoles(inv, []roleResult{newRoleResult(role)})
+}
+
+// runRoleGrant implements "role grant"
+func runRoleGrant(args []string) error {
+	return updateRolePermissions("role grant", args, true, (*services.RoleService).GrantPermissions)
+}
+
+// runRoleRevoke implements "role revoke"
+func runRoleRevoke(args []string) error {
+	// Permissions the routes no longer check can still be removed
+	return updateRolePermissions("role revoke", args, false, (*services.RoleService).RevokePermissions)
+}
+
+type permissionUpdate func(s *services.RoleService, ctx context.Context, name string, permissions ...string) (*models.Role, error)
+
+func updateRolePermissions(name string, args []string, known bool, update permissionUpdate) error {
+	inv, err := parseCommand(name, args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(2, -1, "NAME PERMISSION..."); err != nil {
+		return err
+	}
+	if known {
+		for _, permission := range inv.args[1:] {
+			if !slices.Contains(routes.Permissions(), permission) {
+				return fmt.Errorf("unknown permission %q, the routes check %s", permission, strings.Join(routes.Permissions(), ", "))
+			}
+		}
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	roleService := services.NewRoleService(db)
+
+	role, err := update(roleService, context.Background(), inv.args[0], inv.args[1:]...)
+	if err != nil {
+		return err
+	}
+	return printRoles(inv, []roleResult{newRoleResult(role)})
+}
+
+// runRoleInherit implements "role inherit"
+func runRoleInherit(args []string) error {
+	inv, err := parseCommand("role inherit", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(2, -1, "NAME PARENT..."); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	role, err := services.NewRoleService(db).AddParents(context.Background(), inv.args[0], inv.args[1:]...)
+	if err != nil {
+		return err
+	}
+	return printRoles(inv, []roleResult{newRoleResult(role)})
+}
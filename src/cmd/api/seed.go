@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"go_api/src/internal/routes"
+	"go_api/src/internal/services"
+)
+
+// seedResult is printed by "seed"
+type seedResult struct {
+	Roles               []roleResult `json:"roles"`
+	Organization        string       `json:"organization_created,omitempty"`
+	Admin               string       `json:"admin_created,omitempty"`
+	SetupToken          string       `json:"setup_token,omitempty"`
+	SetupTokenExpiresAt *time.Time   `json:"setup_token_expires_at,omitempty"`
+}
+
+// runSeed implements "seed", the bootstrap the server runs at startup: the configured
+// roles get any missing permissions, the registration organization is created, and without an administrator one is created from
+// BOOTSTRAP_ADMIN_* or a setup token is printed. It is safe to run repeatedly.
+func runSeed(args []string) error {
+	inv, err := parseCommand("seed", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(0, 0, "none"); err != nil {
+		return err
+	}
+
+	if err := checkRoleSeeds(inv.cfg.Bootstrap.Roles); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	bootstrap, err := services.NewBootstrapService(db, inv.cfg.Bootstrap, inv.cfg.Registration).Run(context.Background())
+	if err != nil {
+		return err
+	}
+
+	result := seedResult{SetupToken: bootstrap.SetupToken}
+	for i := range bootstrap.Roles {
+		role := newRoleResult(&bootstrap.Roles[i])
+		created := false
+		for _, name := range bootstrap.CreatedRoles {
+			created = created || name == role.Name
+		}
+		role.Created = &created
+		result.Roles = append(result.Roles, role)
+	}
+	if bootstrap.CreatedOrganization {
+		result.Organization = bootstrap.Organization.Name
+	}
+	if bootstrap.Admin != nil {
+		result.Admin = bootstrap.Admin.Username
+	}
+	if bootstrap.SetupToken != "" {
+		result.SetupTokenExpiresAt = &bootstrap.SetupTokenExpiresAt
+	}
+
+	if inv.output == outputJSON {
+		return inv.print(result, nil, nil)
+	}
+	if err := printRoles(inv, result.Roles); err != nil {
+		return err
+	}
+	if result.Organization != "" {
+		fmt.Printf("\nCreated organization %s\n", result.Organization)
+	}
+	if result.Admin != "" {
+		fmt.Printf("\nCreated administrator %s\n", result.Admin)
+	}
+	if result.SetupToken != "" {
+		fmt.Printf("\nNo administrator exists. Create one with POST /api/setup and this token, valid until %s:\n%s\n",
+			result.SetupTokenExpiresAt.Format(timeFormat), result.SetupToken)
+	}
+	return nil
+}
+
+// checkRoleSeeds rejects BOOTSTRAP_ROLES granting a permission no route checks
+func checkRoleSeeds(seeds []services.RoleSeed) error {
+	known := routes.Permissions()
+	for _, seed := range seeds {
+		for _, permission := range seed.Permissions {
+			if !slices.Contains(known, permission) {
+				return fmt.Errorf("BOOTSTRAP_ROLES: role %s: unknown permission %q, the routes check %s", seed.Name, permission, strings.Join(known, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// logBootstrap reports the bootstrap of the server start
+func logBootstrap(result *services.BootstrapResult) {
+	if len(result.CreatedRoles) > 0 {
+		slog.Info("Bootstrap: created roles", "roles", result.CreatedRoles)
+	}
+	if result.CreatedOrganization {
+		slog.Info("Bootstrap: created organization", "organization", result.Organization.Name)
+	}
+	if result.Admin != nil {
+		slog.Info("Bootstrap: created the first administrator", "username", result.Admin.Username)
+	}
+	if result.SetupToken != "" {
+		// The token is in the message on purpose: attributes named like secrets are redacted,
+		// and this one must reach the operator. It is single-use and expires.
+		slog.Warn("Bootstrap: no administrator exists. Create one with POST /api/setup and the setup token "+result.SetupToken,
+			"expires_at", result.SetupTokenExpiresAt)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go_api/docs"
+	"go_api/src/internal/health"
+	"go_api/src/internal/logging"
+	"go_api/src/internal/metrics"
+	"go_api/src/internal/requestid"
+	"go_api/src/internal/routes"
+	"go_api/src/internal/server"
+	"go_api/src/internal/services"
+	"go_api/src/internal/tracing"
+)
+
+// runServe implements the serve command
+func runServe(args []string) error {
+	inv, err := parseCommand("serve", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(0, 0, "none"); err != nil {
+		return err
+	}
+	cfg := inv.cfg
+	logging.Setup(cfg.Log)
+
+	// Swagger info config
+	docs.SwaggerInfo.Host = cfg.Server.APIHost
+	docs.SwaggerInfo.BasePath = "/"
+	docs.SwaggerInfo.Schemes = []string{"http"}
+
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		logging.Fatal("Failed to connect to DB", "err", err)
+	}
+
+	// Schema: apply the embedded migrations and make sure the tables match the models
+	if err := prepareSchema(context.Background(), db, cfg.Database.MigrateOnStart); err != nil {
+		logging.Fatal("Database schema is not ready", "err", err)
+	}
+
+	// Bootstrap: default roles and the first administrator, or a setup token to create one
+	if err := checkRoleSeeds(cfg.Bootstrap.Roles); err != nil {
+		logging.Fatal("Invalid bootstrap roles", "err", err)
+	}
+	bootstrapService := services.NewBootstrapService(db, cfg.Bootstrap, cfg.Registration)
+	bootstrap, err := bootstrapService.Run(context.Background())
+	if err != nil {
+		logging.Fatal("Bootstrap failed", "err", err)
+	}
+	logBootstrap(bootstrap)
+
+	// Tracing: the exporter is flushed after the server has drained
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "err", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register gorm tracing", "err", err)
+	}
+	// Statements run for a request carry its id as a SQL comment
+	if err := db.Use(requestid.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register gorm request ids", "err", err)
+	}
+
+	// Database metrics: statement durations and pool statistics
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register gorm metrics", "err", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		logging.Fatal("Failed to get DB pool", "err", err)
+	}
+	if err := metrics.RegisterDBStats(sqlDB, cfg.Database.Name); err != nil {
+		logging.Fatal("Failed to register DB pool metrics", "err", err)
+	}
+
+	// Instantiate services; the router fills in the ones that only need the database
+	authenticators, err := services.NewAuthenticators(db, cfg.Auth.Backends, cfg.Auth.LDAP)
+	if err != nil {
+		logging.Fatal("Invalid authentication backends", "err", err)
+	}
+	loginService := services.NewLoginService(db, []byte(cfg.JWTKey), authenticators...)
+	webAuthnService, err := services.NewWebAuthnService(db, loginService, cfg.WebAuthn)
+	if err != nil {
+		logging.Fatal("Invalid WebAuthn configuration", "err", err)
+	}
+	svc := routes.Services{
+		Login:     loginService,
+		MagicLink: services.NewMagicLinkService(db, loginService, services.NewMailer(cfg.SMTP), cfg.MagicLink),
+		WebAuthn:  webAuthnService,
+		Bootstrap: bootstrapService,
+	}
+
+	readiness := health.NewReadiness()
+	svc.Readiness = readiness
+
+	handler, err := routes.New(cfg, db, svc)
+	if err != nil {
+		logging.Fatal("Failed to build router", "err", err)
+	}
+
+	// Serve until SIGINT or SIGTERM, then drain in-flight requests, close the DB pool and flush spans
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(cfg.Server, handler, readiness)
+	if cfg.Metrics.Addr != "" {
+		srv.AddListener(cfg.Metrics.Addr, metrics.Handler(cfg.Metrics.Token))
+	} else if !cfg.Metrics.Enabled() {
+		slog.Info("Metrics: /metrics is disabled, set METRICS_ADDR or METRICS_TOKEN to enable it")
+	}
+
+	slog.Info("Starting server", "port", cfg.Server.Port)
+	flushTracing := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	}
+	if err := srv.Run(ctx, sqlDB.Close, flushTracing); err != nil {
+		logging.Fatal("Server stopped with error", "err", err)
+	}
+	slog.Info("Server stopped")
+	return nil
+}
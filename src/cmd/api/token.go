@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"time"
+
+	"go_api/src/internal/services"
+)
+
+// revokeResult is printed by "token revoke"
+type revokeResult struct {
+	Username  string    `json:"username,omitempty"`
+	Users     int64     `json:"users"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// runTokenRevoke implements "token revoke". Tokens issued before now are rejected; the
+// users can log in again unless they are disabled.
+func runTokenRevoke(args []string) error {
+	var all bool
+	inv, err := parseCommand("token revoke", args, func(flags *flag.FlagSet) {
+		flags.BoolVar(&all, "all", false, "revoke the tokens of every user")
+	})
+	if err != nil {
+		return err
+	}
+	if all {
+		err = inv.expectArgs(0, 0, "none with -all")
+	} else {
+		err = inv.expectArgs(1, 1, "USERNAME")
+	}
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	ctx := context.Background()
+	userService := services.NewUserService(db)
+
+	result := revokeResult{RevokedAt: time.Now().Truncate(time.Second), Users: 1}
+	if all {
+		result.Users, err = userService.RevokeAllTokens(ctx)
+	} else {
+		result.Username = inv.args[0]
+		err = userService.RevokeTokens(ctx, result.Username)
+	}
+	if err != nil {
+		return err
+	}
+	return inv.print(result, []string{"USERNAME", "USERS", "REVOKED AT"}, [][]string{
+		{result.Username, strconv.FormatInt(result.Users, 10), result.RevokedAt.Format(timeFormat)},
+	})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// userResult is a user as printed by the user commands. Password is only set when it was generated.
+type userResult struct {
+	ID         uint   `json:"id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	AuthSource string `json:"auth_source"`
+	Disabled   bool   `json:"disabled"`
+	CreatedAt  string `json:"created_at"`
+	Password   string `json:"password,omitempty"`
+}
+
+func newUserResult(user *models.User) userResult {
+	return userResult{
+		ID:         user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		Role:       user.Role.Name,
+		AuthSource: user.AuthSource,
+		Disabled:   user.Disabled,
+		CreatedAt:  user.CreatedAt.Format(timeFormat),
+	}
+}
+
+func printUsers(inv *invocation, results []userResult) error {
+	header := []string{"ID", "USERNAME", "EMAIL", "ROLE", "SOURCE", "DISABLED", "CREATED AT"}
+	withPassword := false
+	for _, result := range results {
+		withPassword = withPassword || result.Password != ""
+	}
+	if withPassword {
+		header = append(header, "PASSWORD")
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		row := []string{strconv.FormatUint(uint64(result.ID), 10), result.Username, result.Email, result.Role,
+			result.AuthSource, strconv.FormatBool(result.Disabled), result.CreatedAt}
+		if withPassword {
+			row = append(row, result.Password)
+		}
+		rows = append(rows, row)
+	}
+	return inv.print(results, header, rows)
+}
+
+// runUserCreate implements "user create"
+func runUserCreate(args []string) error {
+	var req models.UserCreateRequest
+	var roleName string
+	inv, err := parseCommand("user create", args, func(flags *flag.FlagSet) {
+		flags.StringVar(&req.Username, "username", "", "username (required)")
+		flags.StringVar(&req.Email, "email", "", "email address (required)")
+		flags.StringVar(&roleName, "role", "", "role name (required)")
+		flags.StringVar(&req.Password, "password", "", "password, generated and printed when empty")
+		flags.StringVar(&req.First, "first", "", "first name")
+		flags.StringVar(&req.Last, "last", "", "last name")
+		flags.StringVar(&req.Phone, "phone", "", "phone number in E.164 format")
+	})
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(0, 0, "none, the user is described by flags"); err != nil {
+		return err
+	}
+	if roleName == "" {
+		return usageErrorf("-role is required")
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	ctx := context.Background()
+	userService := services.NewUserService(db)
+	roleService := services.NewRoleService(db)
+
+	role, err := roleService.GetRoleByName(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	req.RoleID = role.ID
+
+	generated := req.Password == ""
+	if generated {
+		if req.Password, err = generatePassword(); err != nil {
+			return err
+		}
+	}
+	if err := validate(&req); err != nil {
+		return err
+	}
+
+	user, err := userService.CreateUser(ctx, &req)
+	if err != nil {
+		return err
+	}
+	result := newUserResult(user)
+	if generated {
+		result.Password = req.Password
+	}
+	return printUsers(inv, []userResult{result})
+}
+
+// runUserList implements "user list"
+func runUserList(args []string) error {
+	var roleName string
+	inv, err := parseCommand("user list", args, func(flags *flag.FlagSet) {
+		flags.StringVar(&roleName, "role", "", "only list users with this role")
+	})
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(0, 0, "none"); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	ctx := context.Background()
+	userService := services.NewUserService(db)
+
+	var users []models.User
+	if roleName == "" {
+		users, err = userService.GetAllUsers(ctx)
+	} else {
+		var role *models.Role
+		if role, err = services.NewRoleService(db).GetRoleByName(ctx, roleName); err == nil {
+			users, err = userService.GetUsersByRoleID(ctx, role.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	results := make([]userResult, 0, len(users))
+	for i := range users {
+		results = append(results, newUserResult(&users[i]))
+	}
+	return printUsers(inv, results)
+}
+
+// runUserDisable implements "user disable"
+func runUserDisable(args []string) error {
+	return setUserDisabled("user disable", args, true)
+}
+
+// runUserEnable implements "user enable"
+func runUserEnable(args []string) error {
+	return setUserDisabled("user enable", args, false)
+}
+
+func setUserDisabled(name string, args []string, disabled bool) error {
+	inv, err := parseCommand(name, args, nil)
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(1, 1, "USERNAME"); err != nil {
+		return err
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	ctx := context.Background()
+	userService := services.NewUserService(db)
+
+	if err := userService.SetDisabled(ctx, inv.args[0], disabled); err != nil {
+		return err
+	}
+	user, err := userService.GetUserByUsername(ctx, inv.args[0])
+	if err != nil {
+		return err
+	}
+	return printUsers(inv, []userResult{newUserResult(user)})
+}
+
+// runUserResetPassword implements "user reset-password". Tokens issued with the old
+// password are revoked, so a leaked password can't be used through them either.
+func runUserResetPassword(args []string) error {
+	var password string
+	inv, err := parseCommand("user reset-password", args, func(flags *flag.FlagSet) {
+		flags.StringVar(&password, "password", "", "new password, generated and printed when empty")
+	})
+	if err != nil {
+		return err
+	}
+	if err := inv.expectArgs(1, 1, "USERNAME"); err != nil {
+		return err
+	}
+
+	generated := password == ""
+	if generated {
+		if password, err = generatePassword(); err != nil {
+			return err
+		}
+	} else if len(password) < 8 || len(password) > 72 {
+		return usageErrorf("-password must be 8 to 72 characters")
+	}
+
+	db, closeDB, err := connect(inv.cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	ctx := context.Background()
+	userService := services.NewUserService(db)
+
+	user, err := userService.GetUserByUsername(ctx, inv.args[0])
+	if err != nil {
+		return err
+	}
+	if user.AuthSource != models.AuthSourceLocal {
+		return fmt.Errorf("%s authenticates with %s, its password can't be set here", user.Username, user.AuthSource)
+	}
+	if err := userService.UpdateUserPassword(ctx, user.Username, password); err != nil {
+		return err
+	}
+	if err := userService.RevokeTokens(ctx, user.Username); err != nil {
+		return err
+	}
+
+	result := newUserResult(user)
+	if generated {
+		result.Password = password
+	}
+	return printUsers(inv, []userResult{result})
+}
+
+// generatePassword returns a random password of 24 URL-safe characters
+func generatePassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go_api/src/internal/logging"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+	"go_api/src/internal/tracing"
+)
+
+// minJWTKeyLength is the smallest HS256 key accepted, matching the hash size
+const minJWTKeyLength = 32
+
+// Config is the complete application configuration. Build it with Load in main
+// or start from Default in tests; nothing else in the application reads the environment.
+type Config struct {
+	Env      string // APP_ENV; "development" (the default) relaxes CORS and disables HSTS
+	Server   ServerConfig
+	Database DatabaseConfig
+	JWTKey   string
+	// JWTPreviousKeys are still accepted when verifying tokens, so a rotated key doesn't
+	// log everyone out at once; drop them once the tokens they signed have expired
+	JWTPreviousKeys []string
+	Swagger         SwaggerConfig
+	Auth            AuthConfig
+	Registration    services.RegistrationConfig
+	Invitation      services.InvitationConfig
+	Bootstrap       services.BootstrapConfig
+	SMTP            services.SMTPMailer
+	MagicLink       services.MagicLinkConfig
+	WebAuthn        services.WebAuthnConfig
+	HTTP            HTTPConfig
+	Health          HealthConfig
+	Metrics         MetricsConfig
+	Tracing         tracing.Config
+	Log             logging.Config
+}
+
+// ServerConfig is where the API listens, how it is addressed from outside and the HTTP server limits
+type ServerConfig struct {
+	Port    string
+	APIHost string // host:port shown in the Swagger UI
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	MaxBodyBytes      int64
+
+	// ShutdownDelay is how long readiness fails before the listener closes
+	ShutdownDelay time.Duration
+	// ShutdownTimeout bounds draining in-flight requests
+	ShutdownTimeout time.Duration
+}
+
+// DatabaseConfig holds the Postgres connection settings
+type DatabaseConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	// MigrateOnStart applies pending migrations before serving; turn it off to run
+	// "migrate up" as a separate deployment step
+	MigrateOnStart bool
+}
+
+// DSN returns the connection string for the postgres driver
+func (d DatabaseConfig) DSN() string {
+	return "host=" + d.Host +
+		" user=" + d.User +
+		" password=" + d.Password +
+		" dbname=" + d.Name +
+		" port=" + d.Port +
+		" sslmode=" + d.SSLMode + " client_encoding=UTF8"
+}
+
+// SwaggerConfig locates the generated specification files served under /docs
+type SwaggerConfig struct {
+	JSONPath string
+	YAMLPath string
+}
+
+// AuthConfig selects the password authentication backends
+type AuthConfig struct {
+	Backends []string // tried in order, "local" and "ldap"
+	LDAP     services.LDAPConfig
+	// PolicyPath is the YAML policy file with the access rules checked before the
+	// permissions of routes on single users; empty for none
+	PolicyPath string
+}
+
+// HTTPConfig holds the router level settings
+type HTTPConfig struct {
+	TrustedProxies []string
+	CORS           middleware.CORSConfig
+	HSTSMaxAge     time.Duration
+	RateLimits     RateLimits
+}
+
+// HealthConfig tunes the health endpoints
+type HealthConfig struct {
+	CacheTTL time.Duration // how long check results are reused
+}
+
+// MetricsConfig protects the Prometheus endpoint. With Addr set, /metrics is served only on
+// that separate address; otherwise it is mounted on the API and requires Token. With neither
+// the endpoint is disabled.
+type MetricsConfig struct {
+	Addr  string
+	Token string
+}
+
+// Enabled reports whether /metrics is exposed anywhere
+func (m MetricsConfig) Enabled() bool {
+	return m.Addr != "" || m.Token != ""
+}
+
+// RateLimits are the limits of the route table's rate limit scopes
+type RateLimits struct {
+	Auth     middleware.RateLimit
+	Register middleware.RateLimit
+	API      middleware.RateLimit
+}
+
+// Default returns the built-in defaults. The JWT key and database credentials are left empty.
+func Default() *Config {
+	return &Config{
+		Env: "development",
+		Server: ServerConfig{
+			Port:              "8080",
+			APIHost:           "localhost:8081",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Port:           "5432",
+			SSLMode:        "disable",
+			MigrateOnStart: true,
+		},
+		Auth: AuthConfig{
+			Backends: []string{models.AuthSourceLocal},
+			LDAP:     services.DefaultLDAPConfig(),
+		},
+		Registration: services.RegistrationConfig{DefaultRole: "guest", Mode: services.RegistrationOpen, Organization: "default"},
+		Invitation: services.InvitationConfig{
+			TTL: 7 * 24 * time.Hour,
+			URL: "http://localhost:8081/register",
+		},
+		Bootstrap: services.BootstrapConfig{
+			Roles: []services.RoleSeed{
+				{Name: "guest", Permissions: []string{"users.read", "groups.read"}},
+				{Name: "admin", Parents: []string{"guest"}, Permissions: []string{
+					"users.create", "users.update", "users.delete",
+					"roles.read", "roles.create", "roles.update",
+					"groups.create", "groups.update", "groups.delete",
+					"invitations.read", "invitations.create", "invitations.delete",
+					"health.read",
+				}},
+			},
+			AdminRole:     "admin",
+			SetupTokenTTL: 24 * time.Hour,
+		},
+		SMTP: services.SMTPMailer{From: "no-reply@localhost"},
+		MagicLink: services.MagicLinkConfig{
+			TTL:          15 * time.Minute,
+			URL:          "http://localhost:8081/login/magic",
+			MaxPerWindow: 3,
+			Window:       15 * time.Minute,
+		},
+		WebAuthn: services.WebAuthnConfig{
+			RPID:      "localhost",
+			RPName:    "GO API",
+			RPOrigins: []string{"http://localhost:8081"},
+		},
+		HTTP: HTTPConfig{
+			CORS: middleware.DefaultCORSConfig(),
+			RateLimits: RateLimits{
+				Auth:     middleware.RateLimit{Requests: 10, Per: time.Minute},
+				Register: middleware.RateLimit{Requests: 5, Per: 10 * time.Minute},
+				API:      middleware.RateLimit{Requests: 120, Per: time.Minute, Burst: 30},
+			},
+		},
+		Health:  HealthConfig{CacheTTL: 5 * time.Second},
+		Tracing: tracing.DefaultConfig(),
+		Log:     logging.DefaultConfig(),
+	}
+}
+
+// JWTVerificationKeys returns the keys tokens are verified with, the signing key first
+func (c *Config) JWTVerificationKeys() [][]byte {
+	keys := [][]byte{[]byte(c.JWTKey)}
+	for _, key := range c.JWTPreviousKeys {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
+
+// IsDevelopment reports whether the development defaults apply
+func (c *Config) IsDevelopment() bool {
+	return c.Env == "" || c.Env == "development"
+}
+
+// Validate reports every missing or inconsistent setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	required := func(key, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+
+	required("APP_PORT", c.Server.Port)
+	if c.Server.ReadTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("SERVER_*_TIMEOUT values must not be negative"))
+	}
+	if c.Server.MaxHeaderBytes <= 0 || c.Server.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("SERVER_MAX_HEADER_BYTES and SERVER_MAX_BODY_BYTES must be positive"))
+	}
+	if c.Server.ShutdownDelay < 0 || c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SERVER_SHUTDOWN_DELAY must not be negative and SERVER_SHUTDOWN_TIMEOUT must be positive"))
+	}
+	required("PG_HOST", c.Database.Host)
+	required("PG_PORT", c.Database.Port)
+	required("PG_USER", c.Database.User)
+	required("PG_DATABASE", c.Database.Name)
+	required("JWT_KEY", c.JWTKey)
+	if c.JWTKey != "" && len(c.JWTKey) < minJWTKeyLength {
+		errs = append(errs, fmt.Errorf("JWT_KEY must be at least %d bytes", minJWTKeyLength))
+	}
+	for _, key := range c.JWTPreviousKeys {
+		if len(key) < minJWTKeyLength {
+			errs = append(errs, fmt.Errorf("JWT_PREVIOUS_KEYS must each be at least %d bytes", minJWTKeyLength))
+			break
+		}
+	}
+
+	for _, backend := range c.Auth.Backends {
+		switch backend {
+		case models.AuthSourceLocal:
+		case models.AuthSourceLDAP:
+			required("LDAP_URL", c.Auth.LDAP.URL)
+			required("LDAP_BASE_DN", c.Auth.LDAP.BaseDN)
+		default:
+			errs = append(errs, fmt.Errorf("AUTH_BACKENDS: unknown backend %q", backend))
+		}
+	}
+
+	required("REGISTRATION_DEFAULT_ROLE", c.Registration.DefaultRole)
+	switch c.Registration.Mode {
+	case services.RegistrationOpen, services.RegistrationInvite, services.RegistrationClosed:
+	case services.RegistrationDomain:
+		if len(c.Registration.AllowedDomains) == 0 {
+			errs = append(errs, errors.New("REGISTRATION_ALLOWED_DOMAINS is required when REGISTRATION_MODE is domain"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("REGISTRATION_MODE: unknown mode %q, expected open, invite, domain or closed", c.Registration.Mode))
+	}
+	if c.Invitation.TTL <= 0 {
+		errs = append(errs, errors.New("INVITATION_TTL must be positive"))
+	}
+	required("INVITATION_URL", c.Invitation.URL)
+	required("BOOTSTRAP_ADMIN_ROLE", c.Bootstrap.AdminRole)
+	if c.Bootstrap.AdminUsername != "" {
+		required("BOOTSTRAP_ADMIN_EMAIL", c.Bootstrap.AdminEmail)
+		if len(c.Bootstrap.AdminPassword) < 8 {
+			errs = append(errs, errors.New("BOOTSTRAP_ADMIN_PASSWORD must be at least 8 characters"))
+		}
+	}
+	if c.Bootstrap.SetupTokenTTL <= 0 {
+		errs = append(errs, errors.New("SETUP_TOKEN_TTL must be positive"))
+	}
+
+	if len(c.MagicLink.Key) == 0 {
+		errs = append(errs, errors.New("MAGIC_LINK_KEY or JWT_KEY is required"))
+	}
+	if c.MagicLink.TTL <= 0 || c.MagicLink.Window <= 0 || c.MagicLink.MaxPerWindow <= 0 {
+		errs = append(errs, errors.New("MAGIC_LINK_TTL, MAGIC_LINK_WINDOW and MAGIC_LINK_MAX_PER_WINDOW must be positive"))
+	}
+	required("WEBAUTHN_RP_ID", c.WebAuthn.RPID)
+	if len(c.WebAuthn.RPOrigins) == 0 {
+		errs = append(errs, errors.New("WEBAUTHN_RP_ORIGINS is required"))
+	}
+
+	if err := c.HTTP.CORS.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
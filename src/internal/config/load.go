@@ -0,0 +1,399 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/services"
+)
+
+// fileSuffix marks a setting whose value is read from the named file
+const fileSuffix = "_FILE"
+
+// Load builds the configuration from these sources, each overriding the ones before it:
+//
+//  1. the built-in defaults (Default)
+//  2. a YAML or TOML file named by -config or CONFIG_FILE, chosen by extension
+//  3. a .env file named by -env-file or ENV_FILE, ".env" by default, which may be missing
+//  4. the process environment, read through lookupEnv
+//  5. command-line flags, one per setting: -pg-host sets PG_HOST
+//
+// In every source but the flags, NAME_FILE may replace NAME and holds the path of a file
+// containing the value, which is how Docker and Kubernetes mount secrets. Settings in the
+// config file use the same names in any case and may be nested: pg: {host: db} sets PG_HOST.
+// Empty values are ignored, so a blank variable keeps the value of the sources before it.
+// The result is validated before it is returned.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	cfg, err := LoadFlags(flags, args, lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+	return cfg, nil
+}
+
+// LoadFlags is Load with the setting flags added to flags, so a command can define flags of
+// its own next to them. Arguments after the flags are left in flags.Args().
+func LoadFlags(flags *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	configFile := flags.String("config", "", "YAML or TOML configuration file (CONFIG_FILE)")
+	envFile := flags.String("env-file", "", "dotenv file, .env by default (ENV_FILE)")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		key := s.key
+		flags.Func(flagName(key), s.usage+" ("+key+")", func(value string) error {
+			flagValues[key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var sources []map[string]string
+
+	if *configFile == "" {
+		*configFile, _ = lookupEnv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, values)
+	}
+
+	envFileRequired := true
+	if *envFile == "" {
+		*envFile, envFileRequired = lookupEnv("ENV_FILE")
+		if !envFileRequired {
+			*envFile = ".env"
+		}
+	}
+	dotenv, err := godotenv.Read(*envFile)
+	if err != nil && (envFileRequired || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, fmt.Errorf("reading %s: %w", *envFile, err)
+	}
+	sources = append(sources, dotenv)
+
+	environment := make(map[string]string)
+	for _, s := range settings {
+		for _, key := range []string{s.key, s.key + fileSuffix} {
+			if value, ok := lookupEnv(key); ok {
+				environment[key] = value
+			}
+		}
+	}
+	sources = append(sources, environment, flagValues)
+
+	values := make(map[string]string)
+	for _, source := range sources {
+		if err := resolveFiles(source); err != nil {
+			return nil, err
+		}
+		for key, value := range source {
+			if value != "" {
+				values[key] = value
+			}
+		}
+	}
+
+	cfg := Default()
+	var errs []error
+	for _, s := range settings {
+		if value, ok := values[s.key]; ok {
+			if err := s.apply(cfg, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// Defaults that depend on other settings
+	if len(cfg.MagicLink.Key) == 0 {
+		cfg.MagicLink.Key = []byte(cfg.JWTKey)
+	}
+	if _, ok := values["CORS_ALLOW_ORIGINS"]; !ok && cfg.IsDevelopment() {
+		cfg.HTTP.CORS.AllowOrigins = []string{"http://localhost:3000", "http://localhost:5173", "http://localhost:8081"}
+	}
+	if _, ok := values["SECURITY_HSTS_MAX_AGE"]; !ok && !cfg.IsDevelopment() {
+		cfg.HTTP.HSTSMaxAge = 365 * 24 * time.Hour
+	}
+	if _, ok := values["SERVER_SHUTDOWN_DELAY"]; !ok && !cfg.IsDevelopment() {
+		cfg.Server.ShutdownDelay = 5 * time.Second
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolveFiles replaces NAME_FILE entries with NAME set to the file contents
+func resolveFiles(values map[string]string) error {
+	for key, path := range values {
+		name, ok := strings.CutSuffix(key, fileSuffix)
+		if !ok || !isSetting(name) {
+			continue
+		}
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("both %s and %s are set", name, key)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		values[name] = strings.TrimRight(string(content), "\r\n")
+		delete(values, key)
+	}
+	return nil
+}
+
+// readConfigFile reads a YAML or TOML file into setting names
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &document)
+	case ".toml":
+		err = toml.Unmarshal(content, &document)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file type, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", document, values)
+	for key := range values {
+		if !isSetting(strings.TrimSuffix(key, fileSuffix)) {
+			return nil, fmt.Errorf("%s: unknown setting %s", path, key)
+		}
+	}
+	return values, nil
+}
+
+// flatten joins nested keys with "_" and lists with ","
+func flatten(prefix string, value any, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			key = strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			flatten(key, item, out)
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	case nil:
+		out[prefix] = ""
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
+
+func isSetting(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// setting is one configuration value, named after its environment variable
+type setting struct {
+	key   string
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+// field builds a setting that parses its value into the field returned by target
+func field[T any](key, usage string, parse func(string) (T, error), target func(*Config) *T) setting {
+	return setting{key: key, usage: usage, apply: func(cfg *Config, value string) error {
+		parsed, err := parse(value)
+		if err != nil {
+			return err
+		}
+		*target(cfg) = parsed
+		return nil
+	}}
+}
+
+func parseString(value string) (string, error) {
+	return value, nil
+}
+
+func parseLower(value string) (string, error) {
+	return strings.ToLower(value), nil
+}
+
+func parseBytes(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func parseInt64(value string) (int64, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseList splits a comma separated list, dropping empty items
+func parseList(value string) ([]string, error) {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// parseLowerList is parseList for case-insensitive names
+func parseLowerList(value string) ([]string, error) {
+	items, _ := parseList(strings.ToLower(value))
+	return items, nil
+}
+
+// parseSeconds accepts a duration ("8760h") or a number of seconds
+func parseSeconds(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func parseGroupRoles(value string) ([]services.LDAPGroupRole, error) {
+	return services.ParseLDAPGroupRoles(value), nil
+}
+
+// settings lists every configuration value in the order they are applied
+var settings = []setting{
+	field("APP_ENV", "environment name, development relaxes CORS and disables HSTS", parseString, func(c *Config) *string { return &c.Env }),
+	field("APP_PORT", "port to listen on", parseString, func(c *Config) *string { return &c.Server.Port }),
+	field("API_HOST", "public host:port shown in Swagger", parseString, func(c *Config) *string { return &c.Server.APIHost }),
+	field("SERVER_READ_TIMEOUT", "maximum time to read a request", time.ParseDuration, func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	field("SERVER_READ_HEADER_TIMEOUT", "maximum time to read request headers", time.ParseDuration, func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout }),
+	field("SERVER_WRITE_TIMEOUT", "maximum time to write a response", time.ParseDuration, func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	field("SERVER_IDLE_TIMEOUT", "keep-alive idle timeout", time.ParseDuration, func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	field("SERVER_MAX_HEADER_BYTES", "maximum request header size", strconv.Atoi, func(c *Config) *int { return &c.Server.MaxHeaderBytes }),
+	field("SERVER_MAX_BODY_BYTES", "maximum request body size", parseInt64, func(c *Config) *int64 { return &c.Server.MaxBodyBytes }),
+	field("SERVER_SHUTDOWN_DELAY", "time readiness fails before the listener closes, 5s outside development", time.ParseDuration, func(c *Config) *time.Duration { return &c.Server.ShutdownDelay }),
+	field("SERVER_SHUTDOWN_TIMEOUT", "deadline for draining in-flight requests", time.ParseDuration, func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+
+	field("PG_HOST", "Postgres host", parseString, func(c *Config) *string { return &c.Database.Host }),
+	field("PG_PORT", "Postgres port", parseString, func(c *Config) *string { return &c.Database.Port }),
+	field("PG_USER", "Postgres user", parseString, func(c *Config) *string { return &c.Database.User }),
+	field("PG_PASSWORD", "Postgres password", parseString, func(c *Config) *string { return &c.Database.Password }),
+	field("PG_DATABASE", "Postgres database name", parseString, func(c *Config) *string { return &c.Database.Name }),
+	field("PG_SSLMODE", "Postgres sslmode", parseString, func(c *Config) *string { return &c.Database.SSLMode }),
+	field("MIGRATE_ON_START", "apply pending database migrations at startup", strconv.ParseBool, func(c *Config) *bool { return &c.Database.MigrateOnStart }),
+
+	field("JWT_KEY", "HS256 signing key, at least 32 bytes", parseString, func(c *Config) *string { return &c.JWTKey }),
+	field("JWT_PREVIOUS_KEYS", "comma separated signing keys still accepted after a rotation", parseList, func(c *Config) *[]string { return &c.JWTPreviousKeys }),
+	field("SWAGGER_JSON_DIR", "path of swagger.json", parseString, func(c *Config) *string { return &c.Swagger.JSONPath }),
+	field("SWAGGER_YAML_DIR", "path of swagger.yaml", parseString, func(c *Config) *string { return &c.Swagger.YAMLPath }),
+
+	field("AUTH_BACKENDS", "comma separated authentication backends: local, ldap", parseLowerList, func(c *Config) *[]string { return &c.Auth.Backends }),
+	field("AUTH_POLICY_PATH", "YAML policy file with access rules", parseString, func(c *Config) *string { return &c.Auth.PolicyPath }),
+	field("LDAP_URL", "LDAP server URL", parseString, func(c *Config) *string { return &c.Auth.LDAP.URL }),
+	field("LDAP_START_TLS", "upgrade ldap:// connections with StartTLS", strconv.ParseBool, func(c *Config) *bool { return &c.Auth.LDAP.StartTLS }),
+	field("LDAP_INSECURE_SKIP_VERIFY", "skip LDAP certificate verification", strconv.ParseBool, func(c *Config) *bool { return &c.Auth.LDAP.InsecureSkipVerify }),
+	field("LDAP_BIND_DN", "service account DN", parseString, func(c *Config) *string { return &c.Auth.LDAP.BindDN }),
+	field("LDAP_BIND_PASSWORD", "service account password", parseString, func(c *Config) *string { return &c.Auth.LDAP.BindPassword }),
+	field("LDAP_BASE_DN", "user search base", parseString, func(c *Config) *string { return &c.Auth.LDAP.BaseDN }),
+	field("LDAP_USER_FILTER", "user search filter with one %s", parseString, func(c *Config) *string { return &c.Auth.LDAP.UserFilter }),
+	field("LDAP_GROUP_ATTRIBUTE", "group membership attribute", parseString, func(c *Config) *string { return &c.Auth.LDAP.GroupAttribute }),
+	field("LDAP_EMAIL_ATTRIBUTE", "email attribute", parseString, func(c *Config) *string { return &c.Auth.LDAP.EmailAttribute }),
+	field("LDAP_FIRST_ATTRIBUTE", "first name attribute", parseString, func(c *Config) *string { return &c.Auth.LDAP.FirstAttribute }),
+	field("LDAP_LAST_ATTRIBUTE", "last name attribute", parseString, func(c *Config) *string { return &c.Auth.LDAP.LastAttribute }),
+	field("LDAP_PHONE_ATTRIBUTE", "phone attribute", parseString, func(c *Config) *string { return &c.Auth.LDAP.PhoneAttribute }),
+	field("LDAP_GROUP_ROLE_MAP", "';' separated group:role pairs", parseGroupRoles, func(c *Config) *[]services.LDAPGroupRole { return &c.Auth.LDAP.GroupRoles }),
+	field("LDAP_DEFAULT_ROLE", "role for directory users without a mapped group", parseString, func(c *Config) *string { return &c.Auth.LDAP.DefaultRole }),
+	field("LDAP_ORGANIZATION", "organization directory users join with their mapped role", parseString, func(c *Config) *string { return &c.Auth.LDAP.Organization }),
+
+	field("REGISTRATION_DEFAULT_ROLE", "role given to self-registered users", parseString, func(c *Config) *string { return &c.Registration.DefaultRole }),
+	field("REGISTRATION_ORGANIZATION", "organization registered users join, created at startup; empty leaves them outside organizations", parseString, func(c *Config) *string { return &c.Registration.Organization }),
+	field("REGISTRATION_MODE", "who may register: open, invite, domain or closed", parseString, func(c *Config) *string { return &c.Registration.Mode }),
+	field("REGISTRATION_ALLOWED_DOMAINS", "comma separated email domains that may register in domain mode", parseLowerList, func(c *Config) *[]string { return &c.Registration.AllowedDomains }),
+	field("INVITATION_TTL", "lifetime of an invitation", time.ParseDuration, func(c *Config) *time.Duration { return &c.Invitation.TTL }),
+	field("INVITATION_URL", "page accepting invitations, the mailed link appends ?invite=TOKEN", parseString, func(c *Config) *string { return &c.Invitation.URL }),
+	field("BOOTSTRAP_ROLES", "';' separated role(parent,parent):permission,permission entries ensured at startup", services.ParseRoleSeeds, func(c *Config) *[]services.RoleSeed { return &c.Bootstrap.Roles }),
+	field("BOOTSTRAP_ADMIN_ROLE", "role of the first administrator", parseString, func(c *Config) *string { return &c.Bootstrap.AdminRole }),
+	field("BOOTSTRAP_ADMIN_USERNAME", "first administrator, created at startup when no administrator exists", parseString, func(c *Config) *string { return &c.Bootstrap.AdminUsername }),
+	field("BOOTSTRAP_ADMIN_EMAIL", "email of the first administrator", parseString, func(c *Config) *string { return &c.Bootstrap.AdminEmail }),
+	field("BOOTSTRAP_ADMIN_PASSWORD", "password of the first administrator", parseString, func(c *Config) *string { return &c.Bootstrap.AdminPassword }),
+	field("SETUP_TOKEN_TTL", "lifetime of the setup token logged while no administrator exists", time.ParseDuration, func(c *Config) *time.Duration { return &c.Bootstrap.SetupTokenTTL }),
+
+	field("SMTP_ADDR", "SMTP relay host:port, mail is logged when empty", parseString, func(c *Config) *string { return &c.SMTP.Addr }),
+	field("SMTP_FROM", "sender address", parseString, func(c *Config) *string { return &c.SMTP.From }),
+	field("SMTP_USERNAME", "SMTP user", parseString, func(c *Config) *string { return &c.SMTP.Username }),
+	field("SMTP_PASSWORD", "SMTP password", parseString, func(c *Config) *string { return &c.SMTP.Password }),
+
+	field("MAGIC_LINK_KEY", "login link signing key, JWT_KEY by default", parseBytes, func(c *Config) *[]byte { return &c.MagicLink.Key }),
+	field("MAGIC_LINK_TTL", "login link lifetime", time.ParseDuration, func(c *Config) *time.Duration { return &c.MagicLink.TTL }),
+	field("MAGIC_LINK_URL", "login page the link token is appended to", parseString, func(c *Config) *string { return &c.MagicLink.URL }),
+	field("MAGIC_LINK_MAX_PER_WINDOW", "login links per address and window", strconv.Atoi, func(c *Config) *int { return &c.MagicLink.MaxPerWindow }),
+	field("MAGIC_LINK_WINDOW", "login link rate limit window", time.ParseDuration, func(c *Config) *time.Duration { return &c.MagicLink.Window }),
+
+	field("WEBAUTHN_RP_ID", "WebAuthn relying party id", parseString, func(c *Config) *string { return &c.WebAuthn.RPID }),
+	field("WEBAUTHN_RP_NAME", "WebAuthn relying party name", parseString, func(c *Config) *string { return &c.WebAuthn.RPName }),
+	field("WEBAUTHN_RP_ORIGINS", "comma separated WebAuthn origins", parseList, func(c *Config) *[]string { return &c.WebAuthn.RPOrigins }),
+
+	field("TRUSTED_PROXIES", "comma separated proxy IPs or CIDRs", parseList, func(c *Config) *[]string { return &c.HTTP.TrustedProxies }),
+	field("RATE_LIMIT_AUTH", "login rate limit per IP, requests/duration[:burst]", middleware.ParseRateLimit, func(c *Config) *middleware.RateLimit { return &c.HTTP.RateLimits.Auth }),
+	field("RATE_LIMIT_REGISTER", "registration rate limit per IP", middleware.ParseRateLimit, func(c *Config) *middleware.RateLimit { return &c.HTTP.RateLimits.Register }),
+	field("RATE_LIMIT_API", "API rate limit per user", middleware.ParseRateLimit, func(c *Config) *middleware.RateLimit { return &c.HTTP.RateLimits.API }),
+	field("CORS_ALLOW_ORIGINS", "comma separated allowed origins", parseList, func(c *Config) *[]string { return &c.HTTP.CORS.AllowOrigins }),
+	field("CORS_ALLOW_METHODS", "comma separated allowed methods", parseList, func(c *Config) *[]string { return &c.HTTP.CORS.AllowMethods }),
+	field("CORS_ALLOW_HEADERS", "comma separated allowed headers", parseList, func(c *Config) *[]string { return &c.HTTP.CORS.AllowHeaders }),
+	field("CORS_ALLOW_CREDENTIALS", "allow credentialed requests", strconv.ParseBool, func(c *Config) *bool { return &c.HTTP.CORS.AllowCredentials }),
+	field("CORS_MAX_AGE", "preflight cache duration", time.ParseDuration, func(c *Config) *time.Duration { return &c.HTTP.CORS.MaxAge }),
+	field("HEALTH_CACHE_TTL", "how long health check results are reused", time.ParseDuration, func(c *Config) *time.Duration { return &c.Health.CacheTTL }),
+	field("METRICS_ADDR", "separate listen address for /metrics, e.g. 127.0.0.1:9090", parseString, func(c *Config) *string { return &c.Metrics.Addr }),
+	field("METRICS_TOKEN", "bearer token for /metrics", parseString, func(c *Config) *string { return &c.Metrics.Token }),
+	field("LOG_LEVEL", "minimum log level: debug, info, warn or error", parseLower, func(c *Config) *string { return &c.Log.Level }),
+	field("LOG_FORMAT", "log output format: json or text", parseLower, func(c *Config) *string { return &c.Log.Format }),
+	field("TRACING_EXPORTER", "span exporter: none, stdout or otlp", parseString, func(c *Config) *string { return &c.Tracing.Exporter }),
+	field("TRACING_OTLP_ENDPOINT", "OTLP/HTTP endpoint URL, e.g. http://localhost:4318", parseString, func(c *Config) *string { return &c.Tracing.Endpoint }),
+	field("TRACING_SERVICE_NAME", "service.name reported with every span", parseString, func(c *Config) *string { return &c.Tracing.ServiceName }),
+	field("TRACING_SAMPLE_RATIO", "fraction of new traces that are sampled, 0 to 1", parseFloat, func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+	field("SECURITY_HSTS_MAX_AGE", "HSTS max-age, 0 disables", parseSeconds, func(c *Config) *time.Duration { return &c.HTTP.HSTSMaxAge }),
+}
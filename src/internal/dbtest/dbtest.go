@@ -0,0 +1,84 @@
+// Package dbtest opens in-memory databases and inserts fixtures for tests. It is kept
+// apart from the packages under test so the server binary doesn't link SQLite.
+package dbtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go_api/src/internal/models"
+)
+
+// New returns an empty in-memory database with the tables of the models and foreign
+// keys enforced. It stands in for Postgres, so tests using it can't cover advisory locks.
+func New(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared&_foreign_keys=1", name)), &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	// One connection, so transactions and the queries around them see the same data
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// CreateRole inserts a role, owned by organization orgID unless it is 0
+func CreateRole(t *testing.T, db *gorm.DB, name string, orgID uint, permissions ...string) *models.Role {
+	t.Helper()
+	role := &models.Role{Name: name, Permissions: permissions}
+	if orgID != 0 {
+		role.OrganizationID = &orgID
+	}
+	if err := db.Create(role).Error; err != nil {
+		t.Fatalf("create role %s: %v", name, err)
+	}
+	return role
+}
+
+// CreateUser inserts a user with role roleID, the email <username>@example.com and the
+// password "password"
+func CreateUser(t *testing.T, db *gorm.DB, username string, roleID uint) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: username + "@example.com", Password: "password", RoleID: roleID}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// CreateOrganization inserts an organization
+func CreateOrganization(t *testing.T, db *gorm.DB, name string) *models.Organization {
+	t.Helper()
+	organization := &models.Organization{Name: name}
+	if err := db.Create(organization).Error; err != nil {
+		t.Fatalf("create organization %s: %v", name, err)
+	}
+	return organization
+}
+
+// AddMember makes user a member of organization with role roleID
+func AddMember(t *testing.T, db *gorm.DB, organization *models.Organization, user *models.User, roleID uint) {
+	t.Helper()
+	membership := &models.Membership{UserID: user.ID, OrganizationID: organization.ID, RoleID: roleID}
+	if err := db.Create(membership).Error; err != nil {
+		t.Fatalf("add %s to %s: %v", user.Username, organization.Name, err)
+	}
+}
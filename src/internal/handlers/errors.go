@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"go_api/src/internal/services"
+	"go_api/src/internal/validation"
+)
+
+// Request errors of the handlers. Like the service errors they are attached with
+// c.Error and written as problem details by middleware.ErrorMiddleware.
+var (
+	errInvalidRequest = services.Validation("invalid_request", "Invalid request body")
+	errInvalidID      = services.Validation("invalid_id", "Invalid ID")
+	errInvalidPolicy  = services.Validation("invalid_policy", "Invalid policy")
+
+	errNotAuthenticated    = services.Unauthenticated("not_authenticated", "User not authenticated")
+	errInvalidTicket       = services.Unauthenticated("invalid_ticket", "Invalid or expired ticket")
+	errPasskeyRegistration = services.Validation("passkey_registration_failed", "Passkey registration failed")
+)
+
+// invalidRequest turns a binding error into a field report when rules failed, and
+// otherwise wraps it so it is logged but not sent to the client
+func invalidRequest(err error) error {
+	if fields, ok := validation.Fields(err); ok {
+		return services.InvalidFields(fields).Wrap(err)
+	}
+	return errInvalidRequest.Wrap(err)
+}
+
+// invalidID reports a malformed numeric path parameter, e.g. "Invalid user ID"
+func invalidID(message string) error {
+	return &services.Error{Kind: services.ErrValidation, Code: errInvalidID.Code, Message: message}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+	"go_api/src/internal/validation"
+)
+
+func TestInvalidRequestProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dbtest.New(t)
+	validation.RegisterPermissions("users.read")
+
+	r := gin.New()
+	r.Use(middleware.ErrorMiddleware())
+	r.POST("/api/users", NewUserHandler(services.NewUserService(db), services.NewPermissionService(db)).CreateUser)
+	r.POST("/api/roles", NewRoleHandler(services.NewRoleService(db)).CreateRole)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+		errors []models.FieldError
+	}{
+		{
+			name:   "failed rules",
+			path:   "/api/users",
+			body:   `{"username": "-ada", "email": "ada", "password": "long-enough"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			errors: []models.FieldError{
+				{Field: "email", Rule: "email", Message: "must be a valid email address"},
+				{Field: "username", Rule: "username", Message: "may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"},
+			},
+		},
+		{
+			name:   "unknown permission",
+			path:   "/api/roles",
+			body:   `{"name": "editor", "permissions": ["users.read", "nuke"]}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			errors: []models.FieldError{{Field: "permissions[1]", Rule: "permission", Message: `unknown permission "nuke"`}},
+		},
+		{
+			name:   "wrong type",
+			path:   "/api/users",
+			body:   `{"username": "ada", "email": "ada@example.com", "password": "long-enough", "role_id": "admin"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			errors: []models.FieldError{{Field: "role_id", Rule: "type", Message: "must be of type uint"}},
+		},
+		{
+			name:   "unknown role",
+			path:   "/api/users",
+			body:   `{"username": "ada", "email": "ada@example.com", "password": "long-enough", "role_id": 99}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			errors: []models.FieldError{{Field: "role_id", Rule: "known_role", Message: "role does not exist"}},
+		},
+		{
+			name:   "missing role",
+			path:   "/api/users",
+			body:   `{"username": "ada", "email": "ada@example.com", "password": "long-enough"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			errors: []models.FieldError{{Field: "role_id", Rule: "known_role", Message: "role does not exist"}},
+		},
+		{
+			name:   "malformed JSON",
+			path:   "/api/users",
+			body:   `{"username": `,
+			status: http.StatusBadRequest,
+			code:   "invalid_request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, middleware.ProblemContentType) {
+				t.Errorf("Content-Type = %q, want %s", ct, middleware.ProblemContentType)
+			}
+
+			var problem models.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != tt.status || problem.Code != tt.code || problem.Instance != tt.path {
+				t.Errorf("problem = %+v, want status %d and code %s for %s", problem, tt.status, tt.code, tt.path)
+			}
+			if !reflect.DeepEqual(problem.Errors, tt.errors) {
+				t.Errorf("errors = %+v, want %+v", problem.Errors, tt.errors)
+			}
+			// The decoder's message names Go types; it must not reach the client
+			if strings.Contains(problem.Detail, "json:") || strings.Contains(problem.Detail, "unexpected") {
+				t.Errorf("detail leaks the decoding error: %q", problem.Detail)
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("invalid requests created %d users", count)
+	}
+}
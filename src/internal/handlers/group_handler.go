@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// GroupHandler manages groups, their members and their roles
+type GroupHandler struct {
+	groupService *services.GroupService
+}
+
+func NewGroupHandler(groupService *services.GroupService) *GroupHandler {
+	return &GroupHandler{groupService: groupService}
+}
+
+// GetGroups godoc
+// @Summary List groups
+// @Description List the groups with their roles
+// @Tags groups
+// @Produce json,application/problem+json
+// @Success 200 {array} models.Group
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups [get]
+func (h *GroupHandler) GetGroups(c *gin.Context) {
+	groups, err := h.groupService.ListGroups(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetGroupByID godoc
+// @Summary Get a group by ID
+// @Description Get a group with its roles
+// @Tags groups
+// @Produce json,application/problem+json
+// @Param id path int true "Group ID"
+// @Success 200 {object} models.Group
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id} [get]
+func (h *GroupHandler) GetGroupByID(c *gin.Context) {
+	id, ok := groupID(c)
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.GetGroup(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// CreateGroup godoc
+// @Summary Create a group
+// @Description Create a group without members or roles in the active organization
+// @Tags groups
+// @Accept json
+// @Produce json,application/problem+json
+// @Param group body models.Group true "Group"
+// @Success 201 {object} models.Group
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups [post]
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var group models.Group
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	group.ID = 0
+
+	if err := h.groupService.CreateGroup(c.Request.Context(), &group); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup godoc
+// @Summary Rename a group
+// @Description Change the name of a group; members and roles are managed separately
+// @Tags groups
+// @Accept json
+// @Produce json,application/problem+json
+// @Param id path int true "Group ID"
+// @Param group body models.Group true "Group"
+// @Success 200 {object} models.Group
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id} [put]
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	id, ok := groupID(c)
+	if !ok {
+		return
+	}
+
+	var req models.Group
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	group, err := h.groupService.RenameGroup(c.Request.Context(), id, req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup godoc
+// @Summary Delete a group
+// @Description Delete a group; its members lose the permissions of its roles
+// @Tags groups
+// @Produce application/problem+json
+// @Param id path int true "Group ID"
+// @Success 204
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id} [delete]
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	id, ok := groupID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.DeleteGroup(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetGroupMembers godoc
+// @Summary List the members of a group
+// @Description List the users in a group
+// @Tags groups
+// @Produce json,application/problem+json
+// @Param id path int true "Group ID"
+// @Success 200 {array} models.User
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id}/members [get]
+func (h *GroupHandler) GetGroupMembers(c *gin.Context) {
+	id, ok := groupID(c)
+	if !ok {
+		return
+	}
+
+	users, err := h.groupService.GetMembers(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// AddGroupMember godoc
+// @Summary Add a user to a group
+// @Description Add a user to a group. Adding a member twice has no effect.
+// @Tags groups
+// @Produce application/problem+json
+// @Param id path int true "Group ID"
+// @Param user_id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id}/members/{user_id} [put]
+func (h *GroupHandler) AddGroupMember(c *gin.Context) {
+	id, userID, ok := groupAndID(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.AddMember(c.Request.Context(), id, userID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveGroupMember godoc
+// @Summary Remove a user from a group
+// @Description Remove a user from a group
+// @Tags groups
+// @Produce application/problem+json
+// @Param id path int true "Group ID"
+// @Param user_id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id}/members/{user_id} [delete]
+func (h *GroupHandler) RemoveGroupMember(c *gin.Context) {
+	id, userID, ok := groupAndID(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.RemoveMember(c.Request.Context(), id, userID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AddGroupRole godoc
+// @Summary Attach a role to a group
+// @Description Give the members of a group the permissions of a role. The role must be shared or belong to the group's organization, and grant no permission the caller lacks unless the caller is a super-admin.
+// @Tags groups
+// @Produce json,application/problem+json
+// @Param id path int true "Group ID"
+// @Param role_id path int true "Role ID"
+// @Success 200 {object} models.Group
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id}/roles/{role_id} [put]
+func (h *GroupHandler) AddGroupRole(c *gin.Context) {
+	id, roleID, ok := groupAndID(c, "role_id", "Invalid role ID")
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.AddRole(c.Request.Context(), id, roleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// RemoveGroupRole godoc
+// @Summary Detach a role from a group
+// @Description Remove a role from a group
+// @Tags groups
+// @Produce json,application/problem+json
+// @Param id path int true "Group ID"
+// @Param role_id path int true "Role ID"
+// @Success 200 {object} models.Group
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/groups/{id}/roles/{role_id} [delete]
+func (h *GroupHandler) RemoveGroupRole(c *gin.Context) {
+	id, roleID, ok := groupAndID(c, "role_id", "Invalid role ID")
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.RemoveRole(c.Request.Context(), id, roleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// groupID parses the :id parameter, attaching an error when it isn't an ID
+func groupID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid group ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// groupAndID parses the :id parameter and the ID parameter param
+func groupAndID(c *gin.Context, param, message string) (uint, uint, bool) {
+	id, ok := groupID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	other, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.Error(invalidID(message))
+		return 0, 0, false
+	}
+	return id, uint(other), true
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go_api/src/internal/health"
+)
+
+// HealthHandler serves the liveness, readiness and detailed health endpoints
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler creates a new HealthHandler
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Liveness godoc
+// @Summary     Liveness probe
+// @Description Succeeds while the process is able to serve requests. It does not check dependencies.
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} map[string]string
+// @Router      /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readiness godoc
+// @Summary     Readiness probe
+// @Description Checks the database, the migration state and the signing keys. Fails during shutdown. Results are cached briefly; error details are only shown by /api/admin/health.
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} health.Report
+// @Failure     503 {object} health.Report
+// @Router      /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.checker.Report(c.Request.Context())
+
+	checks := make([]health.Result, len(report.Checks))
+	for i, result := range report.Checks {
+		result.Error = ""
+		checks[i] = result
+	}
+	report.Checks = checks
+
+	c.JSON(reportStatus(report), report)
+}
+
+// Detailed godoc
+// @Summary     Detailed health report
+// @Description Reports every dependency check with its latency and error. Results are cached briefly.
+// @Tags        health
+// @Produce     json
+// @Success     200 {object} health.Report
+// @Failure     503 {object} health.Report
+// @Security    BearerAuth
+// @Router      /api/admin/health [get]
+func (h *HealthHandler) Detailed(c *gin.Context) {
+	report := h.checker.Report(c.Request.Context())
+	c.JSON(reportStatus(report), report)
+}
+
+func reportStatus(report health.Report) int {
+	if !report.Healthy() {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/health"
+)
+
+// The public readiness probe reports which check failed but not why; the detailed
+// report of administrators does
+func TestHealthEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	failing := health.Check{Name: "database", Run: func(context.Context) error { return errors.New("dial tcp 10.0.0.5:5432: connection refused") }}
+	h := NewHealthHandler(health.NewChecker(nil, time.Minute, failing))
+	r := gin.New()
+	r.GET("/healthz", h.Liveness)
+	r.GET("/readyz", h.Readiness)
+	r.GET("/api/admin/health", h.Detailed)
+
+	tests := []struct {
+		path      string
+		status    int
+		wantError bool
+	}{
+		{path: "/healthz", status: http.StatusOK},
+		{path: "/readyz", status: http.StatusServiceUnavailable},
+		{path: "/api/admin/health", status: http.StatusServiceUnavailable, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var report health.Report
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if tt.path == "/healthz" {
+				if report.Status != health.StatusOK {
+					t.Errorf("liveness = %s, want %s", report.Status, health.StatusOK)
+				}
+				return
+			}
+			if len(report.Checks) != 1 || report.Checks[0].Status != health.StatusFail || (report.Checks[0].Error != "") != tt.wantError {
+				t.Errorf("checks = %+v, want the failed database check with error %v", report.Checks, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// InvitationHandler lets administrators invite users into privileged roles
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+}
+
+func NewInvitationHandler(invitationService *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitationService: invitationService}
+}
+
+// CreateInvitation godoc
+// @Summary Invite a user
+// @Description Invite an email address into a role and mail the invitee a single-use link. The invitee chooses a username and password through /api/invitations/accept. The token is only returned here and on resend.
+// @Tags invitations
+// @Accept json
+// @Produce json,application/problem+json
+// @Param invitation body models.InvitationRequest true "Email address and role"
+// @Success 201 {object} models.InvitationCreated "Created invitation"
+// @Failure 400 {object} models.Problem "Bad request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 422 {object} models.Problem "Validation failed"
+// @Failure 500 {object} models.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/invitations [post]
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req models.InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(c.Request.Context(), &req, c.GetString("username"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// GetInvitations godoc
+// @Summary List pending invitations
+// @Description List the invitations that haven't been accepted, newest first, expired ones included
+// @Tags invitations
+// @Produce json,application/problem+json
+// @Success 200 {array} models.Invitation
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 500 {object} models.Problem "Internal server error"
+// @Security BearerAuth
+// @Router /api/invitations [get]
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	invitations, err := h.invitationService.ListInvitations(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, invitations)
+}
+
+// GetInvitationByID godoc
+// @Summary Get an invitation by ID
+// @Description Get an invitation with its role and, once accepted, the user who accepted it
+// @Tags invitations
+// @Produce json,application/problem+json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} models.Invitation
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/invitations/{id} [get]
+func (h *InvitationHandler) GetInvitationByID(c *gin.Context) {
+	id, ok := invitationID(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitationService.GetInvitation(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, invitation)
+}
+
+// ResendInvitation godoc
+// @Summary Resend an invitation
+// @Description Mail a pending invitation again with a new token and expiry; the previous link stops working
+// @Tags invitations
+// @Produce json,application/problem+json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} models.InvitationCreated
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Invitation already accepted"
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/invitations/{id}/resend [post]
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	id, ok := invitationID(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitationService.ResendInvitation(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, invitation)
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke an invitation
+// @Description Delete a pending invitation so its link can't be used
+// @Tags invitations
+// @Produce application/problem+json
+// @Param id path int true "Invitation ID"
+// @Success 204 "Revoked"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Invitation already accepted"
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/invitations/{id} [delete]
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, ok := invitationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.invitationService.RevokeInvitation(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AcceptInvitation godoc
+// @Summary Accept an invitation
+// @Description Create the invited user with the role and email address of the invitation and the chosen username and password
+// @Tags auth
+// @Accept json
+// @Produce json,application/problem+json
+// @Param invitation body models.InvitationAcceptRequest true "Invitation token and user details"
+// @Success 201 {object} models.User "Created user"
+// @Failure 400 {object} models.Problem "Bad request"
+// @Failure 403 {object} models.Problem "Invalid or expired invitation, or registration closed"
+// @Failure 409 {object} models.Problem "Username or email already exists"
+// @Failure 422 {object} models.Problem "Validation failed"
+// @Failure 500 {object} models.Problem "Internal server error"
+// @Router /api/invitations/accept [post]
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.InvitationAcceptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	user, err := h.invitationService.AcceptInvitation(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+func invitationID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid invitation ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
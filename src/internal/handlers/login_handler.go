@@ -3,12 +3,13 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"go_api/internal/models"
-	"go_api/internal/services"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
 )
 
 // LoginHandler handles login requests
@@ -26,27 +27,34 @@ func NewLoginHandler(service *services.LoginService) *LoginHandler {
 // @Description Validates user credentials and returns a JWT token upon successful authentication
 // @Tags        auth
 // @Accept      json
-// @Produce     json
+// @Produce     json,application/problem+json
 // @Param       credentials body models.LoginRequest true "User credentials"
 // @Success     200 {object} models.TokenResponse
-// @Failure     401 {object} models.ErrorResponse
+// @Success     202 {object} models.SecondFactorResponse "Password accepted, passkey assertion required"
+// @Failure     401 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Failure     503 {object} models.Problem "No authentication backend could be reached"
 // @Router      /api/login [post]
 func (h *LoginHandler) Login(c *gin.Context) {
 	var creds models.LoginRequest
 	if err := c.ShouldBindJSON(&creds); err != nil {
-		log.Println("Error binding credentials:", err) // Log any binding errors
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Code: http.StatusBadRequest, Message: "Invalid request"})
+		slog.InfoContext(c.Request.Context(), "Error binding credentials", "err", err)
+		c.Error(invalidRequest(err))
 		return
 	}
 
 	// Attempt authentication with the service
-	token, err := h.service.Authenticate(creds.Username, creds.Password)
+	token, err := h.service.Authenticate(c.Request.Context(), creds.Username, creds.Password)
+	if errors.Is(err, services.ErrSecondFactorRequired) {
+		c.JSON(http.StatusAccepted, models.SecondFactorResponse{SecondFactorRequired: true, Ticket: token})
+		return
+	}
 	if err != nil {
-		log.Println("Authentication failed for username:", creds.Username) // Log authentication failure
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Code: http.StatusUnauthorized, Message: "Invalid credentials"})
+		slog.InfoContext(c.Request.Context(), "Authentication failed", "username", creds.Username)
+		c.Error(err)
 		return
 	}
 
-	log.Println("Authentication successful for username:", creds.Username) // Log successful authentication
+	slog.InfoContext(c.Request.Context(), "Authentication successful", "username", creds.Username)
 	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// downAuthenticator is a backend that can't be reached
+type downAuthenticator struct{}
+
+func (downAuthenticator) Name() string { return "ldap" }
+
+func (downAuthenticator) Authenticate(context.Context, string, string) (*models.User, error) {
+	return nil, services.ErrBackendUnavailable.Wrap(errors.New("connection refused"))
+}
+
+func TestLoginBackendUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	dbtest.CreateUser(t, db, "alice", guest.ID)
+
+	r := gin.New()
+	r.Use(middleware.ErrorMiddleware())
+	chain := []services.Authenticator{downAuthenticator{}, services.NewLocalAuthenticator(db)}
+	r.POST("/api/login", NewLoginHandler(services.NewLoginService(db, []byte("test-key"), chain...)).Login)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		status   int
+		code     string
+	}{
+		{name: "local user", username: "alice", password: "password", status: http.StatusOK},
+		{name: "wrong local password", username: "alice", password: "wrong-password", status: http.StatusUnauthorized, code: "invalid_credentials"},
+		{name: "user only the directory may know", username: "ada", password: "password", status: http.StatusServiceUnavailable, code: "authentication_unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"username": "` + tt.username + `", "password": "` + tt.password + `"}`
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body)))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code == "" {
+				return
+			}
+			var problem models.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != tt.code {
+				t.Errorf("problem = %+v, %v, want code %s", problem, err, tt.code)
+			}
+		})
+	}
+
+	var login models.Login
+	db.Where("username = ?", "ada").First(&login)
+	if login.Outcome != models.LoginOutcomeRejected || login.Detail != "backend_unavailable" {
+		t.Errorf("login of ada recorded as %s %s, want rejected backend_unavailable", login.Outcome, login.Detail)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// MagicLinkHandler handles passwordless login link requests
+type MagicLinkHandler struct {
+	magicLinkService *services.MagicLinkService
+	roleService      *services.RoleService
+}
+
+// NewMagicLinkHandler creates a new MagicLinkHandler
+func NewMagicLinkHandler(magicLinkService *services.MagicLinkService, roleService *services.RoleService) *MagicLinkHandler {
+	return &MagicLinkHandler{magicLinkService: magicLinkService, roleService: roleService}
+}
+
+// RequestLink godoc
+// @Summary     Request a magic login link
+// @Description Emails a single-use, short-lived login link. The response is the same whether or not the address is known.
+// @Tags        auth
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       request body models.MagicLinkRequest true "Email address"
+// @Success     202 {object} map[string]string
+// @Failure     400 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Failure     429 {object} models.Problem
+// @Failure     500 {object} models.Problem
+// @Router      /api/login/magic-link [post]
+func (h *MagicLinkHandler) RequestLink(c *gin.Context) {
+	var req models.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	if err := h.magicLinkService.RequestLink(c.Request.Context(), req.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an account, a login link has been sent"})
+}
+
+// ConsumeLink godoc
+// @Summary     Log in with a magic link
+// @Description Exchanges a login link token for a JWT. Each link can be used once.
+// @Tags        auth
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       request body models.MagicLinkConsumeRequest true "Login link token"
+// @Success     200 {object} models.TokenResponse
+// @Success     202 {object} models.SecondFactorResponse "Link accepted, passkey assertion required"
+// @Failure     400 {object} models.Problem
+// @Failure     401 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Router      /api/login/magic-link/verify [post]
+func (h *MagicLinkHandler) ConsumeLink(c *gin.Context) {
+	var req models.MagicLinkConsumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	token, err := h.magicLinkService.ConsumeLink(c.Request.Context(), req.Token)
+	if errors.Is(err, services.ErrSecondFactorRequired) {
+		c.JSON(http.StatusAccepted, models.SecondFactorResponse{SecondFactorRequired: true, Ticket: token})
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidMagicLink) {
+			slog.ErrorContext(c.Request.Context(), "Error consuming magic link", "err", err)
+			err = services.ErrInvalidMagicLink.Wrap(err)
+		}
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
+}
+
+// SetRoleMagicLink godoc
+// @Summary     Enable or disable magic-link login for a role
+// @Tags        roles
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       id path int true "Role ID"
+// @Param       request body models.RoleMagicLinkRequest true "Magic-link setting"
+// @Success     200 {object} models.Role
+// @Failure     400 {object} models.Problem
+// @Failure     404 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Failure     500 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/roles/{id}/magic-link [put]
+func (h *MagicLinkHandler) SetRoleMagicLink(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid role ID"))
+		return
+	}
+
+	var req models.RoleMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	role, err := h.roleService.SetMagicLinkEnabled(c.Request.Context(), uint(id), req.Enabled)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("connection refused")
+}
+
+// A link that can't be sent is answered like an unknown address, so the status doesn't
+// tell which addresses have accounts
+func TestRequestLinkResponseIsTheSameForEveryAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dbtest.New(t)
+	role := &models.Role{Name: "guest", MagicLinkEnabled: true}
+	db.Create(role)
+	db.Create(&models.User{Username: "alice", Email: "alice@example.com", Password: "secret-password", RoleID: role.ID})
+
+	magicLinks := services.NewMagicLinkService(db, services.NewLoginService(db, []byte("test-key")), failingMailer{}, services.MagicLinkConfig{
+		Key:          []byte("link-key"),
+		TTL:          time.Minute,
+		MaxPerWindow: 10,
+		Window:       time.Hour,
+	})
+	r := gin.New()
+	r.Use(middleware.ErrorMiddleware())
+	r.POST("/api/login/magic-link", NewMagicLinkHandler(magicLinks, services.NewRoleService(db)).RequestLink)
+
+	request := func(email string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login/magic-link", strings.NewReader(`{"email": "`+email+`"}`)))
+		return w
+	}
+	known, unknown := request("alice@example.com"), request("nobody@example.com")
+	if known.Code != http.StatusAccepted || unknown.Code != http.StatusAccepted {
+		t.Fatalf("status = %d for a known and %d for an unknown address, want 202 for both", known.Code, unknown.Code)
+	}
+	if known.Body.String() != unknown.Body.String() {
+		t.Errorf("bodies differ: %s and %s", known.Body, unknown.Body)
+	}
+}
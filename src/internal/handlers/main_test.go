@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"go_api/src/internal/validation"
+)
+
+func TestMain(m *testing.M) {
+	if err := validation.Setup(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
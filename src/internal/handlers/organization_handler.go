@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// OrganizationHandler manages organizations and switches the active organization of a token
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+	loginService        *services.LoginService
+}
+
+func NewOrganizationHandler(organizationService *services.OrganizationService, loginService *services.LoginService) *OrganizationHandler {
+	return &OrganizationHandler{organizationService: organizationService, loginService: loginService}
+}
+
+// GetOrganizations godoc
+// @Summary List organizations
+// @Description List the organizations of the current user; super-admins see every organization
+// @Tags organizations
+// @Produce json,application/problem+json
+// @Success 200 {array} models.Organization
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/organizations [get]
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	organizations, err := h.organizationService.ListOrganizations(c.Request.Context(), c.GetString("username"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, organizations)
+}
+
+// CreateOrganization godoc
+// @Summary Create an organization
+// @Description Create an organization. Super-admins only.
+// @Tags organizations
+// @Accept json
+// @Produce json,application/problem+json
+// @Param organization body models.Organization true "Organization"
+// @Success 201 {object} models.Organization
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/organizations [post]
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var organization models.Organization
+	if err := c.ShouldBindJSON(&organization); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	organization.ID = 0
+
+	if err := h.organizationService.CreateOrganization(c.Request.Context(), &organization); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, organization)
+}
+
+// SetMember godoc
+// @Summary Add a member to an organization
+// @Description Add a user to an organization with a role, or change their role in it. The role must be shared or owned by the organization. Super-admins only.
+// @Tags organizations
+// @Accept json
+// @Produce json,application/problem+json
+// @Param id path int true "Organization ID"
+// @Param membership body models.MembershipRequest true "User and role"
+// @Success 200 {object} models.Membership
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/organizations/{id}/members [put]
+func (h *OrganizationHandler) SetMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid organization ID"))
+		return
+	}
+
+	var req models.MembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	membership, err := h.organizationService.SetMember(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, membership)
+}
+
+// RemoveMember godoc
+// @Summary Remove a member from an organization
+// @Description Remove a user from an organization; the account is kept. Super-admins only.
+// @Tags organizations
+// @Produce application/problem+json
+// @Param id path int true "Organization ID"
+// @Param user_id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/organizations/{id}/members/{user_id} [delete]
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid organization ID"))
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid user ID"))
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(c.Request.Context(), uint(id), uint(userID)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SwitchOrganization godoc
+// @Summary Switch the active organization
+// @Description Issue a new token acting in the organization. Members only; super-admins can switch to any organization, or to none with ID 0.
+// @Tags organizations
+// @Produce json,application/problem+json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem "Not a member of the organization"
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/organizations/{id}/switch [post]
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid organization ID"))
+		return
+	}
+
+	token, err := h.loginService.SwitchOrganization(c.Request.Context(), c.GetString("username"), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+)
+
+// PermissionHandler lists the RBAC permissions of the route table
+type PermissionHandler struct {
+	permissions []models.Permission
+}
+
+func NewPermissionHandler(permissions []models.Permission) *PermissionHandler {
+	return &PermissionHandler{permissions: permissions}
+}
+
+// GetPermissions godoc
+// @Summary List permissions
+// @Description List the permissions roles can grant, with what they allow and the routes requiring them
+// @Tags permissions
+// @Produce json
+// @Success 200 {array} models.Permission
+// @Security BearerAuth
+// @Router /api/permissions [get]
+func (h *PermissionHandler) GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, h.permissions)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/policy"
+	"go_api/src/internal/services"
+)
+
+// PolicyHandler tests the access policy
+type PolicyHandler struct {
+	policy      *policy.Policy
+	userService *services.UserService
+}
+
+func NewPolicyHandler(p *policy.Policy, userService *services.UserService) *PolicyHandler {
+	return &PolicyHandler{policy: p, userService: userService}
+}
+
+// EvaluatePolicy godoc
+// @Summary Dry-run the access policy
+// @Description Decide an action for the given subject and resource with the loaded policy, or the policy in the request, without performing it. With resource_id the resource is that user as the policy sees it.
+// @Tags policies
+// @Accept json
+// @Produce json,application/problem+json
+// @Param request body models.PolicyEvaluationRequest true "Subject, action and resource"
+// @Success 200 {object} models.PolicyDecision
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/policies/evaluate [post]
+func (h *PolicyHandler) EvaluatePolicy(c *gin.Context) {
+	var req models.PolicyEvaluationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	p := h.policy
+	if req.Policy != "" {
+		var err error
+		if p, err = policy.Parse([]byte(req.Policy)); err != nil {
+			c.Error(&services.Error{Kind: services.ErrValidation, Code: errInvalidPolicy.Code, Message: err.Error()})
+			return
+		}
+	}
+
+	resource := map[string]any{}
+	if req.ResourceID != 0 {
+		attributes, err := h.userService.PolicyAttributes(c.Request.Context(), req.ResourceID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		maps.Copy(resource, attributes)
+	}
+	maps.Copy(resource, req.Resource)
+
+	decision := p.Evaluate(policy.Request{Subject: req.Subject, Action: req.Action, Resource: resource})
+	matched := decision.Matched
+	if matched == nil {
+		matched = []string{}
+	}
+	c.JSON(http.StatusOK, models.PolicyDecision{Decision: decision.Effect, Rule: decision.Rule, Matched: matched, Resource: resource})
+}
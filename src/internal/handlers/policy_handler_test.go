@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/policy"
+	"go_api/src/internal/services"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dbtest.New(t)
+	admin := &models.Role{Name: "admin"}
+	db.Create(admin)
+	root := &models.User{Username: "root", Email: "root@example.com", RoleID: admin.ID}
+	db.Create(root)
+
+	loaded, err := policy.Parse([]byte(`
+rules:
+  - name: support-never-admins
+    effect: deny
+    actions: ["*"]
+    conditions:
+      - subject.role == "support"
+      - resource.role == "admin"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(middleware.ErrorMiddleware())
+	r.POST("/api/policies/evaluate", NewPolicyHandler(loaded, services.NewUserService(db)).EvaluatePolicy)
+
+	tests := []struct {
+		name     string
+		body     any
+		status   int
+		decision string
+		rule     string
+		matched  []string
+		code     string
+	}{
+		{
+			name:     "loaded policy with a loaded resource",
+			body:     gin.H{"subject": gin.H{"username": "bob", "role": "support"}, "action": "user.update", "resource_id": root.ID},
+			status:   http.StatusOK,
+			decision: policy.Deny, rule: "support-never-admins", matched: []string{"support-never-admins"},
+		},
+		{
+			name:     "resource attributes override the loaded ones",
+			body:     gin.H{"subject": gin.H{"role": "support"}, "action": "user.update", "resource_id": root.ID, "resource": gin.H{"role": "guest"}},
+			status:   http.StatusOK,
+			decision: policy.NotApplicable, matched: []string{},
+		},
+		{
+			name: "policy in the request",
+			body: gin.H{
+				"subject":  gin.H{"username": "alice"},
+				"action":   "user.update",
+				"resource": gin.H{"username": "alice"},
+				"policy":   "rules:\n  - name: self\n    effect: allow\n    actions: [user.update]\n    conditions: [subject.username == resource.username]\n",
+			},
+			status:   http.StatusOK,
+			decision: policy.Allow, rule: "self", matched: []string{"self"},
+		},
+		{
+			name:   "invalid policy",
+			body:   gin.H{"subject": gin.H{}, "action": "user.read", "policy": "rules:\n  - name: broken\n    effect: perhaps\n"},
+			status: http.StatusBadRequest,
+			code:   "invalid_policy",
+		},
+		{
+			name:   "unknown resource",
+			body:   gin.H{"subject": gin.H{}, "action": "user.read", "resource_id": 999},
+			status: http.StatusNotFound,
+			code:   "user_not_found",
+		},
+		{
+			name:   "missing action",
+			body:   gin.H{"subject": gin.H{}},
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/policies/evaluate", bytes.NewReader(body)))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code != "" {
+				var problem models.Problem
+				json.Unmarshal(w.Body.Bytes(), &problem)
+				if problem.Code != tt.code {
+					t.Errorf("code = %q, want %q", problem.Code, tt.code)
+				}
+				return
+			}
+			var decision models.PolicyDecision
+			if err := json.Unmarshal(w.Body.Bytes(), &decision); err != nil {
+				t.Fatal(err)
+			}
+			if decision.Decision != tt.decision || decision.Rule != tt.rule || !slices.Equal(decision.Matched, tt.matched) {
+				t.Errorf("decision = %+v, want %s by %q, matched %v", decision, tt.decision, tt.rule, tt.matched)
+			}
+		})
+	}
+}
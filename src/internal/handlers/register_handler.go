@@ -4,8 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"go_api/internal/models"
-	"go_api/internal/services"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
 )
 
 // RegisterHandler handles user registration requests
@@ -19,25 +19,28 @@ func NewRegisterHandler(registerService *services.RegisterService) *RegisterHand
 
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user with the provided details
+// @Description Register a new user with the provided details. The user gets the configured default role, or the role of the invitation given as invite_token. The registration mode may require an invitation or an allowed email domain.
 // @Tags auth
 // @Accept json
-// @Produce json
-// @Param user body models.UserCreateRequest true "User registration info"
+// @Produce json,application/problem+json
+// @Param user body models.RegisterRequest true "User registration info"
 // @Success 201 {object} map[string]interface{} "Created user"
-// @Failure 400 {object} map[string]string "Bad request"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} models.Problem "Bad request"
+// @Failure 403 {object} models.Problem "Registration closed, invitation required or invalid, or email domain not allowed"
+// @Failure 409 {object} models.Problem "Username or email already exists"
+// @Failure 422 {object} models.Problem "Validation failed"
+// @Failure 500 {object} models.Problem "Internal server error"
 // @Router /api/register [post]
 func (h *RegisterHandler) Register(c *gin.Context) {
-	var userCreateRequest models.UserCreateRequest
-	if err := c.ShouldBindJSON(&userCreateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var registerRequest models.RegisterRequest
+	if err := c.ShouldBindJSON(&registerRequest); err != nil {
+		c.Error(invalidRequest(err))
 		return
 	}
 
-	createdUser, err := h.registerService.RegisterUser(&userCreateRequest)
+	createdUser, err := h.registerService.RegisterUser(c.Request.Context(), &registerRequest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
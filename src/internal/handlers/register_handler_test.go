@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// The role in a registration body is ignored: everyone registering gets the default role
+func TestRegisterIgnoresRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	admin := dbtest.CreateRole(t, db, "admin", 0, "users.delete")
+
+	r := gin.New()
+	r.Use(middleware.ErrorMiddleware())
+	register := services.NewRegisterService(db, services.RegistrationConfig{DefaultRole: "guest", Mode: services.RegistrationOpen})
+	r.POST("/api/register", NewRegisterHandler(register).Register)
+
+	body, _ := json.Marshal(gin.H{"username": "mallory", "email": "mallory@example.com", "password": "password", "role_id": admin.ID})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var user models.User
+	db.Where("username = ?", "mallory").First(&user)
+	if user.RoleID != guest.ID {
+		t.Errorf("registered with role %d, want guest %d", user.RoleID, guest.ID)
+	}
+}
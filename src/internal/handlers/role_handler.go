@@ -5,8 +5,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"go_api/internal/models"
-	"go_api/internal/services"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
 )
 
 type RoleHandler struct {
@@ -21,15 +21,15 @@ func NewRoleHandler(roleService *services.RoleService) *RoleHandler {
 // @Summary Get all roles
 // @Description Get all roles
 // @Tags roles
-// @Produce json
+// @Produce json,application/problem+json
 // @Success 200 {array} models.Role
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/roles [get]
 func (h *RoleHandler) GetRoles(c *gin.Context) {
-	roles, err := h.roleService.GetAllRoles()
+	roles, err := h.roleService.GetAllRoles(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, roles)
@@ -39,28 +39,24 @@ func (h *RoleHandler) GetRoles(c *gin.Context) {
 // @Summary Get a role by ID
 // @Description Get a role by ID
 // @Tags roles
-// @Produce json
+// @Produce json,application/problem+json
 // @Param id path int true "Role ID"
 // @Success 200 {object} models.Role
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/roles/{id} [get]
 func (h *RoleHandler) GetRoleByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		c.Error(invalidID("Invalid role ID"))
 		return
 	}
 
-	role, err := h.roleService.GetRoleByID(uint(id))
+	role, err := h.roleService.GetRoleByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if role == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, role)
@@ -70,24 +66,20 @@ func (h *RoleHandler) GetRoleByID(c *gin.Context) {
 // @Summary Get a role by name
 // @Description Get a role by name
 // @Tags roles
-// @Produce json
+// @Produce json,application/problem+json
 // @Param name path string true "Role Name"
 // @Success 200 {object} models.Role
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/roles/name/{name} [get]
 func (h *RoleHandler) GetRoleByName(c *gin.Context) {
 	name := c.Param("name")
 
-	role, err := h.roleService.GetRoleByName(name)
+	role, err := h.roleService.GetRoleByName(c.Request.Context(), name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if role == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, role)
@@ -98,25 +90,63 @@ func (h *RoleHandler) GetRoleByName(c *gin.Context) {
 // @Description Create a new role with the provided details
 // @Tags roles
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param role body models.Role true "Role information"
 // @Success 201 {object} models.Role
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem "Validation failed or unknown parent role"
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/roles [post]
 func (h *RoleHandler) CreateRole(c *gin.Context) {
 	var role models.Role
 	if err := c.ShouldBindJSON(&role); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
-	err := h.roleService.CreateRole(&role)
+	err := h.roleService.CreateRole(c.Request.Context(), &role)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, role)
 }
 
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Replace the permissions and parent roles of a role. Roles inherit the permissions of their parents; a role can't become its own ancestor.
+// @Tags roles
+// @Accept json
+// @Produce json,application/problem+json
+// @Param id path int true "Role ID"
+// @Param role body models.RoleUpdateRequest true "Permissions and parent roles"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 422 {object} models.Problem "Validation failed or unknown parent role"
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/roles/{id} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid role ID"))
+		return
+	}
+
+	var req models.RoleUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
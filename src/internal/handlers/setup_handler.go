@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// SetupHandler creates the first administrator of a fresh installation
+type SetupHandler struct {
+	bootstrapService *services.BootstrapService
+}
+
+func NewSetupHandler(bootstrapService *services.BootstrapService) *SetupHandler {
+	return &SetupHandler{bootstrapService: bootstrapService}
+}
+
+// Setup godoc
+// @Summary Create the first administrator
+// @Description Create the first administrator with the one-time setup token logged at startup. Fails once an administrator exists.
+// @Tags auth
+// @Accept json
+// @Produce json,application/problem+json
+// @Param setup body models.SetupRequest true "Setup token and administrator details"
+// @Success 201 {object} models.User "Created administrator"
+// @Failure 400 {object} models.Problem "Bad request"
+// @Failure 401 {object} models.Problem "Invalid or expired setup token"
+// @Failure 409 {object} models.Problem "An administrator already exists"
+// @Failure 422 {object} models.Problem "Validation failed"
+// @Failure 500 {object} models.Problem "Internal server error"
+// @Router /api/setup [post]
+func (h *SetupHandler) Setup(c *gin.Context) {
+	var req models.SetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	admin, err := h.bootstrapService.CompleteSetup(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, admin)
+}
@@ -5,31 +5,32 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"go_api/internal/models"
-	"go_api/internal/services"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService       *services.UserService
+	permissionService *services.PermissionService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *services.UserService, permissionService *services.PermissionService) *UserHandler {
+	return &UserHandler{userService: userService, permissionService: permissionService}
 }
 
 // GetAllUsers godoc
 // @Summary Get all users
 // @Description Get all users
 // @Tags users
-// @Produce json
+// @Produce json,application/problem+json
 // @Success 200 {array} models.User
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users [get]
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	users, err := h.userService.GetAllUsers()
+	users, err := h.userService.GetAllUsers(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, users)
@@ -39,28 +40,24 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 // @Summary Get a user by ID
 // @Description Get a user by ID
 // @Tags users
-// @Produce json
+// @Produce json,application/problem+json
 // @Param id path int true "User ID"
 // @Success 200 {object} models.User
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/{id} [get]
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Error(invalidID("Invalid user ID"))
 		return
 	}
 
-	user, err := h.userService.GetUserByID(uint(id))
+	user, err := h.userService.GetUserByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, user)
@@ -71,23 +68,26 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 // @Description Create a new user with the provided details
 // @Tags users
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param user body models.UserCreateRequest true "User information"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users [post]
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var userCreateRequest models.UserCreateRequest
 	if err := c.ShouldBindJSON(&userCreateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
-	createdUser, err := h.userService.CreateUser(&userCreateRequest)
+	createdUser, err := h.userService.CreateUser(c.Request.Context(), &userCreateRequest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -105,34 +105,43 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 // UpdateUser godoc
 // @Summary Update an existing user
-// @Description Update an existing user
+// @Description Update the profile, role and status of a user. Passwords, super-admin status and token revocations can't be changed here. Callers let through by a policy rule rather than the update permission keep the user's role and status. Within an organization only super-admins change the profile and status of users who are members of other organizations too, and only super-admins change super-admin accounts.
 // @Tags users
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param id path int true "User ID"
-// @Param user body models.User true "User object to be updated"
+// @Param user body models.UserUpdateRequest true "User fields to update"
 // @Success 200 {object} models.User
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Error(invalidID("Invalid user ID"))
 		return
 	}
 
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req models.UserUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
 		return
 	}
-	user.ID = uint(id)
 
-	err = h.userService.UpdateUser(&user)
+	// A policy rule letting a user without the update permission through, e.g. for their
+	// own record, covers the profile but not the role or status of the account
+	if c.GetString("policy_rule") != "" {
+		req.RoleID, req.Disabled = 0, nil
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, user)
@@ -142,23 +151,24 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Summary Delete a user
 // @Description Delete a user
 // @Tags users
-// @Produce json
+// @Produce json,application/problem+json
 // @Param id path int true "User ID"
 // @Success 204
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Error(invalidID("Invalid user ID"))
 		return
 	}
 
-	err = h.userService.DeleteUser(uint(id))
+	err = h.userService.DeleteUser(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -168,24 +178,20 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 // @Summary Get a user by email
 // @Description Get a user by email
 // @Tags users
-// @Produce json
+// @Produce json,application/problem+json
 // @Param email path string true "User Email"
 // @Success 200 {object} models.User
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/email/{email} [get]
 func (h *UserHandler) GetUserByEmail(c *gin.Context) {
 	email := c.Param("email")
 
-	user, err := h.userService.GetUserByEmail(email)
+	user, err := h.userService.GetUserByEmail(c.Request.Context(), email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, user)
@@ -195,24 +201,20 @@ func (h *UserHandler) GetUserByEmail(c *gin.Context) {
 // @Summary Get a user by username
 // @Description Get a user by username
 // @Tags users
-// @Produce json
+// @Produce json,application/problem+json
 // @Param username path string true "User Username"
 // @Success 200 {object} models.User
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/username/{username} [get]
 func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 	username := c.Param("username")
 
-	user, err := h.userService.GetUserByUsername(username)
+	user, err := h.userService.GetUserByUsername(c.Request.Context(), username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, user)
@@ -222,31 +224,58 @@ func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 // @Summary Get users by role ID
 // @Description Get all users that have the specified role ID
 // @Tags users
-// @Produce json
+// @Produce json,application/problem+json
 // @Param role_id path int true "Role ID"
 // @Success 200 {array} models.User
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/role/{role_id} [get]
 func (h *UserHandler) GetUsersByRoleID(c *gin.Context) {
 	roleID, err := strconv.ParseUint(c.Param("role_id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		c.Error(invalidID("Invalid role ID"))
 		return
 	}
 
-	users, err := h.userService.GetUsersByRoleID(uint(roleID))
+	users, err := h.userService.GetUsersByRoleID(c.Request.Context(), uint(roleID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, users)
 }
 
+// GetEffectivePermissions godoc
+// @Summary Get the effective permissions of a user
+// @Description Get the permissions a user holds through their role and the roles of their groups, with the roles and groups granting each
+// @Tags users
+// @Produce json,application/problem+json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.EffectivePermissions
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /api/users/{id}/effective-permissions [get]
+func (h *UserHandler) GetEffectivePermissions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid user ID"))
+		return
+	}
+
+	permissions, err := h.permissionService.EffectivePermissions(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, permissions)
+}
+
 type ChangePasswordRequest struct {
-	OldPassword string `json:"oldPassword" binding:"required"`
-	NewPassword string `json:"newPassword" binding:"required"`
+	OldPassword string `json:"oldPassword" binding:"required,max=1024"`
+	NewPassword string `json:"newPassword" binding:"required,min=8,max=72"`
 }
 
 // ChangePassword godoc
@@ -254,34 +283,31 @@ type ChangePasswordRequest struct {
 // @Description Change the current user's password
 // @Tags users
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param body body ChangePasswordRequest true "Old and new passwords"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 401 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Security BearerAuth
 // @Router /api/users/password [post]
 func (h *UserHandler) ChangePassword(c *gin.Context) {
 	username, exists := c.Get("username")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(errNotAuthenticated)
 		return
 	}
 
 	var req ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
-	err := h.userService.ChangeUserPassword(username.(string), req.OldPassword, req.NewPassword)
+	err := h.userService.ChangeUserPassword(c.Request.Context(), username.(string), req.OldPassword, req.NewPassword)
 	if err != nil {
-		if err.Error() == "incorrect old password" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect old password"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
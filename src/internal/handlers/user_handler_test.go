@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+func TestUpdateUserAllowedByPolicyRule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dbtest.New(t)
+	guest := &models.Role{Name: "guest"}
+	admin := &models.Role{Name: "admin"}
+	db.Create(guest)
+	db.Create(admin)
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: "secret-password", RoleID: guest.ID}
+	db.Create(alice)
+	revokedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	db.Model(alice).Update("tokens_revoked_at", revokedAt)
+	var before models.User
+	db.First(&before, alice.ID)
+
+	r := gin.New()
+	r.Use(middleware.ErrorMiddleware())
+	allowedByRule := func(c *gin.Context) { c.Set("policy_rule", "users-manage-themselves") }
+	r.PUT("/api/users/:id", allowedByRule, NewUserHandler(services.NewUserService(db), services.NewPermissionService(db)).UpdateUser)
+
+	body, _ := json.Marshal(gin.H{"email": "alice@example.org", "username": "alice", "first": "Alice", "role_id": admin.ID, "disabled": true})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/users/1", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var after models.User
+	db.First(&after, alice.ID)
+	if after.Email != "alice@example.org" || after.First != "Alice" {
+		t.Errorf("profile not updated: %+v", after)
+	}
+	if after.RoleID != guest.ID || after.Disabled {
+		t.Errorf("a policy rule let the user change role to %d and disabled to %v", after.RoleID, after.Disabled)
+	}
+	if after.Password != before.Password || after.TokensRevokedAt == nil || !after.TokensRevokedAt.Equal(*before.TokensRevokedAt) {
+		t.Error("updating the profile changed the password or the token revocation")
+	}
+}
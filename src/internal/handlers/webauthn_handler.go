@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go_api/src/internal/models"
+	"go_api/src/internal/services"
+)
+
+// WebAuthnHandler handles passkey registration, login and management requests
+type WebAuthnHandler struct {
+	webAuthnService *services.WebAuthnService
+}
+
+// NewWebAuthnHandler creates a new WebAuthnHandler
+func NewWebAuthnHandler(webAuthnService *services.WebAuthnService) *WebAuthnHandler {
+	return &WebAuthnHandler{webAuthnService: webAuthnService}
+}
+
+// BeginRegistration godoc
+// @Summary     Begin passkey registration
+// @Description Returns PublicKeyCredentialCreationOptions for navigator.credentials.create()
+// @Tags        webauthn
+// @Produce     json,application/problem+json
+// @Success     200 {object} models.WebAuthnCeremonyResponse
+// @Failure     401 {object} models.Problem
+// @Failure     500 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/webauthn/register/begin [post]
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	username := c.GetString("username")
+
+	creation, sessionID, err := h.webAuthnService.BeginRegistration(username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.WebAuthnCeremonyResponse{SessionID: sessionID, Options: creation})
+}
+
+// FinishRegistration godoc
+// @Summary     Finish passkey registration
+// @Description Verifies the attestation returned by navigator.credentials.create() and stores the passkey
+// @Tags        webauthn
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       session_id query string true "Session id from the begin step"
+// @Param       name query string false "Display name for the passkey"
+// @Success     201 {object} models.WebAuthnCredential
+// @Failure     400 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/webauthn/register/finish [post]
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	username := c.GetString("username")
+
+	credential, err := h.webAuthnService.FinishRegistration(username, c.Query("session_id"), c.Query("name"), c.Request.Body)
+	if err != nil {
+		slog.InfoContext(c.Request.Context(), "Passkey registration failed", "username", username, "err", err)
+		c.Error(errPasskeyRegistration.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListCredentials godoc
+// @Summary     List my passkeys
+// @Tags        webauthn
+// @Produce     json,application/problem+json
+// @Success     200 {array} models.WebAuthnCredential
+// @Failure     500 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/webauthn/credentials [get]
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	credentials, err := h.webAuthnService.ListCredentials(c.GetString("username"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, credentials)
+}
+
+// RenameCredential godoc
+// @Summary     Rename one of my passkeys
+// @Tags        webauthn
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       id path int true "Passkey ID"
+// @Param       request body models.WebAuthnCredentialRenameRequest true "New name"
+// @Success     200 {object} models.WebAuthnCredential
+// @Failure     400 {object} models.Problem
+// @Failure     404 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/webauthn/credentials/{id} [put]
+func (h *WebAuthnHandler) RenameCredential(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid passkey ID"))
+		return
+	}
+
+	var req models.WebAuthnCredentialRenameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	credential, err := h.webAuthnService.RenameCredential(c.GetString("username"), uint(id), req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, credential)
+}
+
+// DeleteCredential godoc
+// @Summary     Delete one of my passkeys
+// @Tags        webauthn
+// @Produce     application/problem+json
+// @Param       id path int true "Passkey ID"
+// @Success     204
+// @Failure     400 {object} models.Problem
+// @Failure     404 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/webauthn/credentials/{id} [delete]
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidID("Invalid passkey ID"))
+		return
+	}
+
+	if err := h.webAuthnService.DeleteCredential(c.GetString("username"), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetSecondFactor godoc
+// @Summary     Require a passkey after password login
+// @Description Enables or disables passkeys as a second factor for the current user
+// @Tags        webauthn
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       request body models.PasskeyMFARequest true "Second factor setting"
+// @Success     200 {object} map[string]bool
+// @Failure     400 {object} models.Problem
+// @Security    BearerAuth
+// @Router      /api/webauthn/mfa [put]
+func (h *WebAuthnHandler) SetSecondFactor(c *gin.Context) {
+	var req models.PasskeyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	if err := h.webAuthnService.SetSecondFactor(c.GetString("username"), req.Enabled); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"passkey_mfa": req.Enabled})
+}
+
+// BeginLogin godoc
+// @Summary     Begin passkey login
+// @Description Returns PublicKeyCredentialRequestOptions for navigator.credentials.get(). Omit the username for a discoverable login.
+// @Tags        auth
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       request body models.WebAuthnLoginRequest false "Username"
+// @Success     200 {object} models.WebAuthnCeremonyResponse
+// @Failure     401 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Router      /api/login/webauthn/begin [post]
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	var req models.WebAuthnLoginRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(invalidRequest(err))
+			return
+		}
+	}
+
+	assertion, sessionID, err := h.webAuthnService.BeginLogin(req.Username)
+	if err != nil {
+		slog.InfoContext(c.Request.Context(), "Error beginning passkey login", "err", err)
+		c.Error(services.ErrInvalidCredentials.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, models.WebAuthnCeremonyResponse{SessionID: sessionID, Options: assertion})
+}
+
+// FinishLogin godoc
+// @Summary     Finish passkey login
+// @Description Verifies the assertion returned by navigator.credentials.get() and returns a JWT
+// @Tags        auth
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       session_id query string true "Session id from the begin step"
+// @Success     200 {object} models.TokenResponse
+// @Failure     401 {object} models.Problem
+// @Router      /api/login/webauthn/finish [post]
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	token, err := h.webAuthnService.FinishLogin(c.Request.Context(), c.Query("session_id"), c.Request.Body)
+	if err != nil {
+		slog.InfoContext(c.Request.Context(), "Passkey login failed", "err", err)
+		c.Error(services.ErrInvalidCredentials.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
+}
+
+// BeginSecondFactor godoc
+// @Summary     Begin passkey second factor
+// @Description Exchanges the ticket returned by /api/login for PublicKeyCredentialRequestOptions
+// @Tags        auth
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       request body models.WebAuthnSecondFactorRequest true "Second-factor ticket"
+// @Success     200 {object} models.WebAuthnCeremonyResponse
+// @Failure     401 {object} models.Problem
+// @Failure     422 {object} models.Problem
+// @Router      /api/login/webauthn/second-factor/begin [post]
+func (h *WebAuthnHandler) BeginSecondFactor(c *gin.Context) {
+	var req models.WebAuthnSecondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	assertion, sessionID, err := h.webAuthnService.BeginSecondFactor(req.Ticket)
+	if err != nil {
+		slog.InfoContext(c.Request.Context(), "Error beginning passkey second factor", "err", err)
+		c.Error(errInvalidTicket.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, models.WebAuthnCeremonyResponse{SessionID: sessionID, Options: assertion})
+}
+
+// FinishSecondFactor godoc
+// @Summary     Finish passkey second factor
+// @Description Verifies the passkey assertion and returns the JWT for the password login
+// @Tags        auth
+// @Accept      json
+// @Produce     json,application/problem+json
+// @Param       session_id query string true "Session id from the begin step"
+// @Success     200 {object} models.TokenResponse
+// @Failure     401 {object} models.Problem
+// @Router      /api/login/webauthn/second-factor/finish [post]
+func (h *WebAuthnHandler) FinishSecondFactor(c *gin.Context) {
+	token, err := h.webAuthnService.FinishSecondFactor(c.Request.Context(), c.Query("session_id"), c.Request.Body)
+	if err != nil {
+		slog.InfoContext(c.Request.Context(), "Passkey second factor failed", "err", err)
+		c.Error(services.ErrInvalidCredentials.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/migrate"
+)
+
+// Check statuses
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// checkTimeout bounds a single check so a hanging dependency cannot hang the probe
+const checkTimeout = 2 * time.Second
+
+// Check is a named dependency check
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Status    string    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Healthy reports whether the server is ready and every check passed
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs the checks and caches the report for ttl, so frequent probes
+// hit the database at most once per ttl.
+type Checker struct {
+	readiness *Readiness
+	checks    []Check
+	ttl       time.Duration
+
+	mu     sync.Mutex
+	report *Report
+}
+
+// NewChecker creates a Checker. A nil readiness counts as ready.
+func NewChecker(readiness *Readiness, ttl time.Duration, checks ...Check) *Checker {
+	return &Checker{readiness: readiness, checks: checks, ttl: ttl}
+}
+
+// Report returns the cached report, running the checks when it is older than ttl.
+// The lifecycle state is applied on every call so shutdown is reported immediately.
+func (c *Checker) Report(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.report == nil || time.Since(c.report.CheckedAt) >= c.ttl {
+		report := c.run(ctx)
+		c.report = &report
+	}
+
+	report := *c.report
+	if !c.readiness.Ready() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// run executes all checks in parallel
+func (c *Checker) run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make([]Result, len(c.checks)), CheckedAt: time.Now()}
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// DatabaseCheck pings the database through the gorm connection pool
+func DatabaseCheck(db *gorm.DB) Check {
+	return Check{Name: "database", Run: func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}}
+}
+
+// MigrationCheck verifies that every migration is applied and the tables of models have all their columns
+func MigrationCheck(db *gorm.DB, models ...interface{}) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		migrator, err := migrate.New(sqlDB)
+		if err != nil {
+			return err
+		}
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, the first is %s", len(pending), pending[0])
+		}
+		return migrate.CheckSchema(ctx, db, models...)
+	}}
+}
+
+// SigningKeyCheck verifies that a JWT signing key is loaded
+func SigningKeyCheck(key []byte) Check {
+	return Check{Name: "signing_keys", Run: func(ctx context.Context) error {
+		if len(key) == 0 {
+			return errors.New("no JWT signing key loaded")
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_api/src/internal/dbtest"
+)
+
+// countingCheck counts its runs and fails with err when set
+type countingCheck struct {
+	runs atomic.Int32
+	err  atomic.Value // error
+}
+
+func (c *countingCheck) check(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		c.runs.Add(1)
+		err, _ := c.err.Load().(error)
+		return err
+	}}
+}
+
+// Probes within the TTL get the cached report instead of hitting the dependencies
+func TestReportCaching(t *testing.T) {
+	database := &countingCheck{}
+	checker := NewChecker(nil, 50*time.Millisecond, database.check("database"))
+	ctx := context.Background()
+
+	first := checker.Report(ctx)
+	for range 10 {
+		if report := checker.Report(ctx); !report.CheckedAt.Equal(first.CheckedAt) {
+			t.Fatal("a report within the TTL was not the cached one")
+		}
+	}
+	if runs := database.runs.Load(); runs != 1 {
+		t.Errorf("the check ran %d times within the TTL, want 1", runs)
+	}
+
+	// Once the TTL passed the checks run again, and a failure shows
+	database.err.Store(errors.New("connection refused"))
+	time.Sleep(60 * time.Millisecond)
+	report := checker.Report(ctx)
+	if runs := database.runs.Load(); runs != 2 {
+		t.Errorf("the check ran %d times after the TTL, want 2", runs)
+	}
+	if report.Healthy() || report.Status != StatusFail {
+		t.Errorf("status = %s, want %s", report.Status, StatusFail)
+	}
+	if result := report.Checks[0]; result.Name != "database" || result.Status != StatusFail || result.Error != "connection refused" {
+		t.Errorf("result = %+v, want the failed database check", result)
+	}
+}
+
+// Shutdown shows at once, even while a healthy report is cached
+func TestReportShuttingDown(t *testing.T) {
+	readiness := NewReadiness()
+	readiness.SetReady(true)
+	database := &countingCheck{}
+	checker := NewChecker(readiness, time.Hour, database.check("database"))
+	ctx := context.Background()
+
+	if report := checker.Report(ctx); !report.Healthy() {
+		t.Fatalf("status = %s, want %s", report.Status, StatusOK)
+	}
+	readiness.SetReady(false)
+	if report := checker.Report(ctx); report.Healthy() || report.Status != StatusShuttingDown {
+		t.Errorf("status after shutdown began = %s, want %s", report.Status, StatusShuttingDown)
+	}
+	if runs := database.runs.Load(); runs != 1 {
+		t.Errorf("the check ran %d times, want 1", runs)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	db := dbtest.New(t)
+	tests := []struct {
+		check   Check
+		wantErr bool
+	}{
+		{check: DatabaseCheck(db)},
+		{check: SigningKeyCheck([]byte("key"))},
+		{check: SigningKeyCheck(nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		result := runCheck(context.Background(), tt.check)
+		if (result.Status == StatusFail) != tt.wantErr || (result.Error != "") != tt.wantErr {
+			t.Errorf("%s = %+v, want failure %v", tt.check.Name, result, tt.wantErr)
+		}
+		if result.LatencyMS < 0 {
+			t.Errorf("%s: latency %f", tt.check.Name, result.LatencyMS)
+		}
+	}
+}
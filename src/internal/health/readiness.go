@@ -0,0 +1,25 @@
+package health
+
+import "sync/atomic"
+
+// Readiness is the lifecycle part of readiness: false until the server accepts
+// connections and again once shutdown has begun, so load balancers stop routing
+// new requests before the listener closes.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+// NewReadiness creates a Readiness that starts out not ready
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// SetReady changes the reported state
+func (r *Readiness) SetReady(ready bool) {
+	r.ready.Store(ready)
+}
+
+// Ready reports whether the server accepts new traffic. A nil Readiness is always ready.
+func (r *Readiness) Ready() bool {
+	return r == nil || r.ready.Load()
+}
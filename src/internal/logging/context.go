@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+type fieldsKey struct{}
+
+// fields are the request attributes added to every record logged with the request context.
+// They are shared by pointer so middleware further down the chain can still add to them,
+// e.g. the user once the token is verified.
+type fields struct {
+	mu   sync.Mutex
+	list []slog.Attr
+}
+
+func (f *fields) attrs() []slog.Attr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]slog.Attr(nil), f.list...)
+}
+
+// WithAttrs returns a context whose log records carry attrs in addition to any already present
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	f := &fields{}
+	if parent, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		f.list = parent.attrs()
+	}
+	f.list = append(f.list, attrs...)
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// AddAttrs adds attrs to the request attributes of ctx. It does nothing for a
+// context that did not pass through WithAttrs.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		f.mu.Lock()
+		f.list = append(f.list, attrs...)
+		f.mu.Unlock()
+	}
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is when gorm logs a statement as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger routes gorm's own messages (errors and slow statements) through slog.
+// Statements are logged with placeholders rather than bound values.
+func GormLogger() gormlogger.Interface {
+	return gormlogger.New(gormWriter{}, gormlogger.Config{
+		SlowThreshold:             slowQueryThreshold,
+		LogLevel:                  gormlogger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})
+}
+
+type gormWriter struct{}
+
+func (gormWriter) Printf(format string, args ...interface{}) {
+	slog.Warn(fmt.Sprintf(format, args...), "component", "gorm")
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config selects the log level and output format
+type Config struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text
+}
+
+// DefaultConfig logs JSON at info level
+func DefaultConfig() Config {
+	return Config{Level: "info", Format: FormatJSON}
+}
+
+// Validate reports an unknown level or format
+func (c Config) Validate() error {
+	if _, err := parseLevel(c.Level); err != nil {
+		return err
+	}
+	switch c.Format {
+	case FormatJSON, FormatText:
+		return nil
+	default:
+		return fmt.Errorf("LOG_FORMAT: unknown format %q", c.Format)
+	}
+}
+
+// New builds a logger writing to w. Sensitive attributes are redacted and the
+// request attributes stored in the context are added to every record.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	if cfg.Format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// Setup makes a logger for cfg the slog default. Output of the standard log
+// package, including from dependencies, goes through it as well.
+func Setup(cfg Config) {
+	slog.SetDefault(New(os.Stdout, cfg))
+}
+
+// Fatal logs msg at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func parseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("LOG_LEVEL: unknown level %q", value)
+	}
+	return level, nil
+}
+
+// contextHandler adds the request attributes and the trace id from the context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if fields, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		r.AddAttrs(fields.attrs()...)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// lower-case key fragments whose values are never logged
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "hash", "key", "dsn", "authorization", "cookie", "ticket"}
+
+// IsSensitive reports whether an attribute named key is redacted
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range sensitiveKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+)
+
+// Redacted replaces sensitive values
+const Redacted = "[REDACTED]"
+
+// secretPatterns catch secrets inside otherwise harmless values, such as an error
+// message quoting a DSN, with the replacement keeping the surrounding text readable
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]*`), Redacted},                   // JWT
+	{regexp.MustCompile(`\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}`), Redacted},        // bcrypt hash
+	{regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`), "${1}" + Redacted}, // key=value DSN
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`), "${1}" + Redacted + "@"},      // URL user info
+	{regexp.MustCompile(`(?i)(bearer\s+)\S+`), "${1}" + Redacted},                 // Authorization header
+	{regexp.MustCompile(`(?i)([?&](?:token|ticket)=)[^&\s]+`), "${1}" + Redacted}, // tokens in links
+}
+
+// redact is the slog ReplaceAttr function: sensitive keys lose their value and
+// string and error values are scrubbed of anything that looks like a secret.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Scrub(err.Error()))
+		}
+	}
+	return a
+}
+
+// Scrub replaces anything in s that looks like a secret
+func Scrub(s string) string {
+	for _, p := range secretPatterns {
+		s = p.pattern.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Request labels come from route templates and known methods only, so clients can't
+// create series with arbitrary paths, ids or methods
+func TestMiddlewareBoundsLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	HTTPRequests.Reset()
+	HTTPDuration.Reset()
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	requests := []struct{ method, path string }{
+		{http.MethodGet, "/api/users/1"},
+		{http.MethodGet, "/api/users/2"},
+		{http.MethodGet, "/api/users/3?expand=role"},
+		{http.MethodGet, "/wp-admin/setup.php"},
+		{http.MethodGet, "/.env"},
+		{"PROPFIND", "/api/users/1"},
+		{"X-SCAN-1", "/anything"},
+	}
+	for _, req := range requests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	want := []struct {
+		route, method, status string
+		count                 float64
+	}{
+		{"/api/users/:id", http.MethodGet, "200", 3},
+		{unmatchedRoute, http.MethodGet, "404", 2},
+		{unmatchedRoute, otherMethod, "404", 2},
+	}
+	for _, w := range want {
+		if got := testutil.ToFloat64(HTTPRequests.WithLabelValues(w.route, w.method, w.status)); got != w.count {
+			t.Errorf("requests{%s %s %s} = %v, want %v", w.route, w.method, w.status, got, w.count)
+		}
+	}
+	if series := testutil.CollectAndCount(HTTPRequests); series != len(want) {
+		t.Errorf("%d request series, want %d", series, len(want))
+	}
+	if series := testutil.CollectAndCount(HTTPDuration); series != len(want) {
+		t.Errorf("%d duration series, want %d", series, len(want))
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	tests := []struct {
+		name, token, header string
+		status              int
+	}{
+		{name: "open", status: http.StatusOK},
+		{name: "token", token: "scrape-secret", header: "Bearer scrape-secret", status: http.StatusOK},
+		{name: "missing token", token: "scrape-secret", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "scrape-secret", header: "Bearer guess", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			Handler(tt.token).ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/logging"
+)
+
+// CORSConfig is the cross-origin policy for the API.
+// AllowOrigins entries are exact origins ("https://app.example.com"), wildcard
+// subdomains ("https://*.example.com") or "*" for any origin without credentials.
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// DefaultCORSConfig allows the usual methods and headers but no origins
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-API-Key", "X-Request-ID"},
+		ExposeHeaders: []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", "X-Request-ID"},
+		MaxAge:        12 * time.Hour,
+	}
+}
+
+// Validate rejects policies that browsers refuse or that are unsafe
+func (cfg CORSConfig) Validate() error {
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				return fmt.Errorf("CORS origin \"*\" cannot be combined with credentials")
+			}
+			continue
+		}
+		if _, err := parseOriginPattern(origin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CORSMiddleware applies cfg using gin-contrib/cors. Requests without an Origin header are unaffected.
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	if err := cfg.Validate(); err != nil {
+		logging.Fatal("Invalid CORS configuration", "err", err)
+	}
+
+	corsConfig := cors.Config{
+		AllowMethods:     cfg.AllowMethods,
+		AllowHeaders:     cfg.AllowHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+
+	var patterns []originPattern
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			corsConfig.AllowAllOrigins = true
+			break
+		}
+		pattern, _ := parseOriginPattern(origin)
+		patterns = append(patterns, pattern)
+	}
+	if !corsConfig.AllowAllOrigins {
+		corsConfig.AllowOriginFunc = func(origin string) bool {
+			for _, pattern := range patterns {
+				if pattern.matches(origin) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	return cors.New(corsConfig)
+}
+
+// originPattern is a parsed AllowOrigins entry
+type originPattern struct {
+	scheme   string
+	host     string // for wildcards, the suffix after "*."
+	port     string
+	wildcard bool
+}
+
+func parseOriginPattern(origin string) (originPattern, error) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return originPattern{}, fmt.Errorf("invalid CORS origin %q: expected scheme://host[:port]", origin)
+	}
+
+	pattern := originPattern{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+	if strings.HasPrefix(pattern.host, "*.") {
+		pattern.wildcard = true
+		pattern.host = strings.TrimPrefix(pattern.host, "*.")
+	}
+	if strings.Contains(pattern.host, "*") || pattern.host == "" {
+		return originPattern{}, fmt.Errorf("invalid CORS origin %q: only a leading \"*.\" wildcard is supported", origin)
+	}
+	return pattern, nil
+}
+
+// matches compares an Origin header against the pattern. A wildcard matches
+// any subdomain depth but never the bare parent domain.
+func (p originPattern) matches(origin string) bool {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme != p.scheme || u.Port() != p.port {
+		return false
+	}
+	host := u.Hostname()
+	if p.wildcard {
+		return strings.HasSuffix(host, "."+p.host) && len(host) > len(p.host)+1
+	}
+	return host == p.host
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOriginPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "https://APP.example.com", true},
+		{"https://app.example.com", "http://app.example.com", false},
+		{"https://app.example.com", "https://app.example.com:8443", false},
+		{"https://app.example.com", "https://app.example.com.evil", false},
+		{"http://localhost:3000", "http://localhost:3000", true},
+		{"http://localhost:3000", "http://localhost:3001", false},
+
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evilexample.com", false},
+		{"https://*.example.com", "https://app.evilexample.com", false},
+		{"https://*.example.com", "https://example.com.evil", false},
+		{"https://*.example.com", "https://app.example.com.evil", false},
+		{"https://*.example.com", "https://.example.com", false},
+		{"https://*.example.com", "http://app.example.com", false},
+		{"https://*.example.com", "https://app.example.com:8443", false},
+		{"https://*.example.com:8443", "https://app.example.com:8443", true},
+		{"https://*.example.com", "null", false},
+	}
+	for _, tt := range tests {
+		pattern, err := parseOriginPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseOriginPattern(%q): %v", tt.pattern, err)
+		}
+		if got := pattern.matches(tt.origin); got != tt.want {
+			t.Errorf("%s matches %s = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSConfigValidate(t *testing.T) {
+	tests := []struct {
+		origins     []string
+		credentials bool
+		wantErr     bool
+	}{
+		{origins: []string{"https://app.example.com", "https://*.example.com"}, credentials: true},
+		{origins: []string{"*"}},
+		{origins: []string{"*"}, credentials: true, wantErr: true},
+		{origins: []string{"app.example.com"}, wantErr: true},
+		{origins: []string{"https://app.example.com/path"}, wantErr: true},
+		{origins: []string{"https://app.*.example.com"}, wantErr: true},
+		{origins: []string{"https://*"}, wantErr: true},
+		{origins: []string{"https://*example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := DefaultCORSConfig()
+		cfg.AllowOrigins = tt.origins
+		cfg.AllowCredentials = tt.credentials
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%v, credentials %v) = %v, want error %v", tt.origins, tt.credentials, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultCORSConfig()
+	cfg.AllowOrigins = []string{"https://*.example.com"}
+	cfg.AllowCredentials = true
+	r := gin.New()
+	r.Use(CORSMiddleware(cfg))
+	r.GET("/api/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		status     int
+		wantOrigin string
+	}{
+		{name: "allowed subdomain", method: http.MethodGet, origin: "https://app.example.com", status: http.StatusOK, wantOrigin: "https://app.example.com"},
+		{name: "allowed preflight", method: http.MethodOptions, origin: "https://app.example.com", status: http.StatusNoContent, wantOrigin: "https://app.example.com"},
+		{name: "lookalike domain", method: http.MethodGet, origin: "https://evilexample.com", status: http.StatusForbidden},
+		{name: "suffixed domain", method: http.MethodOptions, origin: "https://example.com.evil", status: http.StatusForbidden},
+		{name: "parent domain", method: http.MethodGet, origin: "https://example.com", status: http.StatusForbidden},
+		{name: "same origin request", method: http.MethodGet, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "https://api.example.org/api/users", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			wantCredentials := ""
+			if tt.wantOrigin != "" {
+				wantCredentials = "true"
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, wantCredentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/requestid"
+	"go_api/src/internal/services"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies
+const ProblemContentType = "application/problem+json"
+
+// kindStatus maps each domain error kind to its HTTP status
+var kindStatus = []struct {
+	kind   error
+	status int
+}{
+	{services.ErrNotFound, http.StatusNotFound},
+	{services.ErrConflict, http.StatusConflict},
+	{services.ErrValidation, http.StatusBadRequest},
+	{services.ErrForbidden, http.StatusForbidden},
+	{services.ErrUnauthenticated, http.StatusUnauthorized},
+	{services.ErrRateLimited, http.StatusTooManyRequests},
+	{services.ErrUnavailable, http.StatusServiceUnavailable},
+}
+
+// WriteProblem writes a problem details body with the stable error code and the request id
+func WriteProblem(c *gin.Context, status int, code, detail string) {
+	writeProblem(c, status, code, detail, nil)
+}
+
+func writeProblem(c *gin.Context, status int, code, detail string, fields []models.FieldError) {
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(status, models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: requestid.FromContext(c.Request.Context()),
+		Errors:    fields,
+	})
+}
+
+// abortWithError stops the chain with a problem details body
+func abortWithError(c *gin.Context, status int, code, detail string) {
+	c.Abort()
+	WriteProblem(c, status, code, detail)
+}
+
+// ErrorMiddleware writes the response for errors handlers attached with c.Error. Domain
+// errors map to a status by kind and keep their code and message, validation errors
+// with field reports become 422; anything else is logged and answered with a generic
+// 500, so internal details never reach the client.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteProblem(c, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+
+		var domainErr *services.Error
+		if errors.As(err, &domainErr) {
+			for _, ks := range kindStatus {
+				if errors.Is(domainErr.Kind, ks.kind) {
+					if domainErr.Err != nil {
+						slog.DebugContext(c.Request.Context(), "Request failed", "code", domainErr.Code, "err", domainErr.Err)
+					}
+					if len(domainErr.Fields) > 0 {
+						writeProblem(c, http.StatusUnprocessableEntity, domainErr.Code, domainErr.Message, domainErr.Fields)
+						return
+					}
+					WriteProblem(c, ks.status, domainErr.Code, domainErr.Message)
+					return
+				}
+			}
+		}
+
+		slog.ErrorContext(c.Request.Context(), "Request failed", "err", err)
+		WriteProblem(c, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+// RecoveryMiddleware turns a panic into a 500 with the request id and logs it with the request context
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Recovered from panic", "panic", recovered)
+		abortWithError(c, http.StatusInternalServerError, "internal_error", "Internal server error")
+	})
+}
+
+// NotFoundHandler answers unknown routes with a problem details 404
+func NotFoundHandler(c *gin.Context) {
+	abortWithError(c, http.StatusNotFound, "route_not_found", "Not found")
+}
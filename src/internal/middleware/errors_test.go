@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/requestid"
+	"go_api/src/internal/services"
+)
+
+// Error bodies carry the request id, so a reported error can be matched to its log lines
+func TestErrorMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestid.Middleware(), ErrorMiddleware())
+	r.GET("/api/users/:id", func(c *gin.Context) { c.Error(services.ErrUserNotFound) })
+	r.GET("/api/boom", func(c *gin.Context) { c.Error(errors.New("pq: connection reset by peer")) })
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+		detail string
+	}{
+		{path: "/api/users/42", status: http.StatusNotFound, code: "user_not_found", detail: "User not found"},
+		{path: "/api/boom", status: http.StatusInternalServerError, code: "internal_error", detail: "Internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(requestid.Header, "req-42")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var problem models.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.RequestID != "req-42" || problem.Code != tt.code || problem.Detail != tt.detail || problem.Instance != tt.path {
+				t.Errorf("problem = %+v, want code %s, detail %q and request id req-42", problem, tt.code, tt.detail)
+			}
+		})
+	}
+}
@@ -1,54 +1,51 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
+
+	"go_api/src/internal/logging"
+	"go_api/src/internal/metrics"
+	"go_api/src/internal/policy"
+	"go_api/src/internal/services"
 )
 
+// Claims are the claims of the access tokens signed by services.LoginService
 type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	OrganizationID uint   `json:"org,omitempty"`
+	SuperAdmin     bool   `json:"super_admin,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func JwtAuthMiddleware() gin.HandlerFunc {
-	// Resolve path to root relative to current file
-	rootPath, err := filepath.Abs(filepath.Join("./"))
-	if err != nil {
-		log.Fatal("Unable to resolve root path:", err)
-	}
-
-	envPath := filepath.Join(rootPath, ".env")
-
-	// Load .env file
-	if err := godotenv.Load(envPath); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-
-	jwtKey := os.Getenv("JWT_KEY")
-	if jwtKey == "" {
-		log.Fatal("JWT_KEY is not set in the environment variables")
+// TokenCheck rejects valid tokens that must no longer be accepted, e.g. of a disabled user.
+// It returns services.ErrTokenRevoked for those and any other error when the check failed.
+type TokenCheck func(ctx context.Context, claims *Claims) error
+
+// JwtAuthMiddleware validates bearer tokens signed with one of keys, the current signing
+// key first and then the previous ones still accepted during a key rotation. A non-nil
+// check runs after the signature and expiry were verified.
+func JwtAuthMiddleware(keys [][]byte, check TokenCheck) gin.HandlerFunc {
+	keySet := jwt.VerificationKeySet{}
+	for _, key := range keys {
+		keySet.Keys = append(keySet.Keys, key)
 	}
 
 	return func(c *gin.Context) {
-		// Skip authentication for /api/login
-		if c.Request.URL.Path == "/api/login" {
-			c.Next()
-			return
-		}
-
 		// Get the token from the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			metrics.JWTValidationFailures.WithLabelValues("missing_header").Inc()
+			abortWithError(c, http.StatusUnauthorized, "missing_token", "Authorization header is required")
 			return
 		}
 
@@ -56,7 +53,8 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 
 		if tokenString == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			metrics.JWTValidationFailures.WithLabelValues("malformed_header").Inc()
+			abortWithError(c, http.StatusUnauthorized, "invalid_token", "Invalid authorization header format")
 			return
 		}
 
@@ -66,79 +64,177 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(jwtKey), nil
+			return keySet, nil
 		})
 
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
+			metrics.JWTValidationFailures.WithLabelValues(jwtFailureCause(err)).Inc()
+			// The parse error tells which check failed, which is of no use to the client
+			slog.InfoContext(c.Request.Context(), "Invalid token", "err", err)
+			abortWithError(c, http.StatusUnauthorized, "invalid_token", "Invalid or expired token")
 			return
 		}
 
 		// Get the claims
 		claims, ok := token.Claims.(*Claims)
 		if !ok || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			metrics.JWTValidationFailures.WithLabelValues("invalid_claims").Inc()
+			abortWithError(c, http.StatusUnauthorized, "invalid_token", "Invalid token claims")
 			return
 		}
 
+		if check != nil {
+			if err := check(c.Request.Context(), claims); err != nil {
+				if errors.Is(err, services.ErrTokenRevoked) {
+					metrics.JWTValidationFailures.WithLabelValues("revoked").Inc()
+					abortWithError(c, http.StatusUnauthorized, services.ErrTokenRevoked.Code, services.ErrTokenRevoked.Message)
+					return
+				}
+				slog.ErrorContext(c.Request.Context(), "Token check failed", "err", err)
+				abortWithError(c, http.StatusInternalServerError, "internal_error", "Internal server error")
+				return
+			}
+		}
+
 		// Set the user's username and role in the context.
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role) // Set the role in the context
+		c.Set("organization_id", claims.OrganizationID)
+		c.Set("super_admin", claims.SuperAdmin)
+		logging.AddAttrs(c.Request.Context(), slog.String("user", claims.Username))
+
+		// Limit the services to the active organization of the token
+		tenant := services.Tenant{OrganizationID: claims.OrganizationID, Username: claims.Username, SuperAdmin: claims.SuperAdmin}
+		c.Request = c.Request.WithContext(services.WithTenant(c.Request.Context(), tenant))
 
 		c.Next()
 	}
 }
 
-func RoleAuthMiddleware(requiredRole string) gin.HandlerFunc {
+// jwtFailureCause maps a parse error to a bounded metrics label
+func jwtFailureCause(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "not_yet_valid"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "invalid_signature"
+	case errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "unexpected_algorithm"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	default:
+		return "invalid"
+	}
+}
+
+// PermissionLookup returns the effective permissions of username, those of their role and
+// of their groups' roles including the permissions these inherit
+type PermissionLookup func(ctx context.Context, username string) ([]string, error)
+
+// PermissionAuthMiddleware is the authorization check for protected routes: super-admins
+// hold every permission, other users need requiredPermission among the permissions
+// returned by lookup. Built-in roles such as admin get their permissions from the roles
+// table like any other role.
+func PermissionAuthMiddleware(requiredPermission string, lookup PermissionLookup) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Role not found in context"})
+		if c.GetBool("super_admin") {
+			c.Next()
 			return
 		}
 
-		userRole, ok := role.(string)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Invalid role type"})
+		permissions, err := lookup(c.Request.Context(), c.GetString("username"))
+		if err != nil && !errors.Is(err, services.ErrUserNotFound) {
+			slog.ErrorContext(c.Request.Context(), "Permission lookup failed", "err", err)
+			abortWithError(c, http.StatusInternalServerError, "internal_error", "Internal server error")
 			return
 		}
 
-		if userRole != requiredRole && requiredRole != "any" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		if !slices.Contains(permissions, requiredPermission) {
+			abortWithError(c, http.StatusForbidden, "insufficient_permissions", "Insufficient permissions")
 			return
 		}
+
 		c.Next()
 	}
 }
 
-func PermissionAuthMiddleware(requiredPermission string) gin.HandlerFunc {
+// SuperAdminMiddleware only lets super-admins through
+func SuperAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("super_admin") {
+			abortWithError(c, http.StatusForbidden, "insufficient_permissions", "Super-admin required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// ResourceLoader loads the attributes of the resource a request acts on, for policy rules
+type ResourceLoader func(c *gin.Context) (map[string]any, error)
+
+// PolicyMiddleware authorizes a request acting on a single resource. Super-admins pass;
+// for other users the rules of p for action decide first, a matching deny rule winning
+// over a matching allow rule, and without a matching rule they need requiredPermission
+// like in PermissionAuthMiddleware. The subject of the rules is the caller: username, role,
+// organization_id, super_admin and permissions. Requests allowed by a rule alone carry
+// "policy_rule", the name of that rule, in the context.
+func PolicyMiddleware(p *policy.Policy, action, requiredPermission string, lookup PermissionLookup, load ResourceLoader) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleName, exists := c.Get("role")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Role not found in context"})
+		if c.GetBool("super_admin") {
+			c.Next()
 			return
 		}
 
-		userRole, ok := roleName.(string)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Invalid role type"})
+		permissions, err := lookup(c.Request.Context(), c.GetString("username"))
+		if err != nil && !errors.Is(err, services.ErrUserNotFound) {
+			slog.ErrorContext(c.Request.Context(), "Permission lookup failed", "err", err)
+			abortWithError(c, http.StatusInternalServerError, "internal_error", "Internal server error")
+			return
+		}
+		permitted := slices.Contains(permissions, requiredPermission)
+		if !p.Applies(action) {
+			if !permitted {
+				abortWithError(c, http.StatusForbidden, "insufficient_permissions", "Insufficient permissions")
+				return
+			}
+			c.Next()
 			return
 		}
 
-		var hasPermission bool
-		switch userRole {
-		case "admin":
-			hasPermission = true // Admin has all permissions
-		case "guest":
-			if requiredPermission == "read" {
-				hasPermission = true
+		resource, err := load(c)
+		if err != nil {
+			// Without the permission the caller learns nothing about the resource
+			var domainErr *services.Error
+			if !permitted && errors.As(err, &domainErr) {
+				abortWithError(c, http.StatusForbidden, "insufficient_permissions", "Insufficient permissions")
+				return
 			}
-		default:
-			hasPermission = false
+			c.Error(err)
+			c.Abort()
+			return
 		}
 
-		if !hasPermission {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		decision := p.Evaluate(policy.Request{
+			Subject: map[string]any{
+				"username":        c.GetString("username"),
+				"role":            c.GetString("role"),
+				"organization_id": c.GetUint("organization_id"),
+				"super_admin":     false,
+				"permissions":     permissions,
+			},
+			Action:   action,
+			Resource: resource,
+		})
+		switch {
+		case decision.Effect == policy.Deny:
+			abortWithError(c, http.StatusForbidden, "policy_denied", "Denied by policy rule "+decision.Rule)
+			return
+		case decision.Effect == policy.Allow && !permitted:
+			c.Set("policy_rule", decision.Rule)
+		case decision.Effect == policy.NotApplicable && !permitted:
+			abortWithError(c, http.StatusForbidden, "insufficient_permissions", "Insufficient permissions")
 			return
 		}
 
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/logging"
+)
+
+// RateLimit describes a token bucket: Requests tokens are added every Per, up to Burst tokens
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// ratePerSecond returns the refill rate of the bucket
+func (l RateLimit) ratePerSecond() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// capacity returns the bucket size, defaulting Burst to Requests
+func (l RateLimit) capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// RateLimitResult is the outcome of taking one token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, only set when not allowed
+}
+
+// RateLimitStore holds token buckets. The in-memory store is per process;
+// implement this interface on a shared backend to limit across instances.
+type RateLimitStore interface {
+	Take(key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc derives the bucket key for a request
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP keys buckets by client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUsername keys buckets by the authenticated username, falling back to the client IP.
+// It must run after JwtAuthMiddleware.
+func KeyByUsername(c *gin.Context) string {
+	if username := c.GetString("username"); username != "" {
+		return "user:" + username
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey keys buckets by the X-API-Key header, falling back to the client IP
+func KeyByAPIKey(c *gin.Context) string {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return "key:" + apiKey
+	}
+	return KeyByIP(c)
+}
+
+// RateLimitMiddleware limits requests per key within a named scope, usually a route group.
+// It sets the RateLimit-* headers on every response and Retry-After on 429 responses.
+// If the store fails the request is let through so a broken backend cannot take the API down.
+func RateLimitMiddleware(scope string, limit RateLimit, keyFunc RateLimitKeyFunc, store RateLimitStore) gin.HandlerFunc {
+	if limit.Requests <= 0 || limit.Per <= 0 {
+		logging.Fatal("Invalid rate limit", "scope", scope, "requests", limit.Requests, "per", limit.Per.String())
+	}
+	policy := fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, int(limit.Per.Seconds()), limit.capacity())
+
+	return func(c *gin.Context) {
+		result, err := store.Take(scope+"|"+keyFunc(c), limit)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Rate limit store failed, allowing request", "scope", scope, "err", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			abortWithError(c, http.StatusTooManyRequests, "rate_limited", "Rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+// ParseRateLimit parses the "<requests>/<duration>[:<burst>]" format
+func ParseRateLimit(value string) (RateLimit, error) {
+	var limit RateLimit
+
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	requests, per, ok := strings.Cut(rate, "/")
+	if !ok {
+		return limit, fmt.Errorf("expected <requests>/<duration>, got %q", value)
+	}
+
+	var err error
+	if limit.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || limit.Requests <= 0 {
+		return limit, fmt.Errorf("invalid request count in %q", value)
+	}
+	if limit.Per, err = time.ParseDuration(strings.TrimSpace(per)); err != nil || limit.Per <= 0 {
+		return limit, fmt.Errorf("invalid duration in %q", value)
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || limit.Burst <= 0 {
+			return limit, fmt.Errorf("invalid burst in %q", value)
+		}
+	}
+	return limit, nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// tokenBucket is the state of one key in MemoryRateLimitStore
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	idleTTL time.Duration
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take refills the bucket for key and removes one token if available
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	capacity := float64(limit.capacity())
+	rate := limit.ratePerSecond()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now, idleTTL: time.Duration(capacity / rate * float64(time.Second))}
+		s.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.updated).Seconds()
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*rate)
+		bucket.updated = now
+	}
+
+	result := RateLimitResult{Limit: limit.capacity()}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(math.Floor(bucket.tokens))
+	result.Reset = time.Duration((capacity - bucket.tokens) / rate * float64(time.Second))
+
+	s.sweep(now)
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again, at most once a minute
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) > bucket.idleTTL {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_api/src/internal/models"
+)
+
+// fakeClock is a clock the tests move by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestRateLimitStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.Now
+	store.lastSweep = clock.now
+	return store, clock
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	limit := RateLimit{Requests: 2, Per: time.Second, Burst: 3}
+
+	take := func(wantAllowed bool, wantRemaining int, wantReset, wantRetryAfter time.Duration) {
+		t.Helper()
+		result, err := store.Take("k", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := RateLimitResult{Allowed: wantAllowed, Limit: 3, Remaining: wantRemaining, Reset: wantReset, RetryAfter: wantRetryAfter}
+		if result != want {
+			t.Errorf("Take = %+v, want %+v", result, want)
+		}
+	}
+
+	// A new bucket starts full, so the burst goes through at once
+	take(true, 2, 500*time.Millisecond, 0)
+	take(true, 1, time.Second, 0)
+	take(true, 0, 1500*time.Millisecond, 0)
+	take(false, 0, 1500*time.Millisecond, 500*time.Millisecond)
+
+	// Tokens come back at the rate, not all at once
+	clock.Advance(250 * time.Millisecond)
+	take(false, 0, 1250*time.Millisecond, 250*time.Millisecond)
+	clock.Advance(250 * time.Millisecond)
+	take(true, 0, 1500*time.Millisecond, 0)
+
+	// An idle bucket refills up to the burst and no further
+	clock.Advance(time.Hour)
+	take(true, 2, 500*time.Millisecond, 0)
+
+	// Keys have their own buckets
+	if result, _ := store.Take("other", limit); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("another key shared the bucket: %+v", result)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	limit := RateLimit{Requests: 1, Per: time.Second}
+	store.Take("idle", limit)
+	clock.Advance(30 * time.Second)
+	store.Take("busy", limit)
+
+	clock.Advance(31 * time.Second)
+	store.Take("busy", limit)
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("the idle bucket was not swept")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Error("the busy bucket was swept")
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(string, RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, clock := newTestRateLimitStore()
+	r := gin.New()
+	r.Use(RateLimitMiddleware("login", RateLimit{Requests: 1, Per: time.Minute, Burst: 2}, KeyByIP, store))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	wantHeaders := func(w *httptest.ResponseRecorder, want map[string]string) {
+		t.Helper()
+		for name, value := range want {
+			if got := w.Header().Get(name); got != value {
+				t.Errorf("%s = %q, want %q", name, got, value)
+			}
+		}
+	}
+
+	w := request("192.0.2.1:1234")
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d", w.Code)
+	}
+	wantHeaders(w, map[string]string{
+		"RateLimit-Policy":    "1;w=60;burst=2",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "60",
+		"Retry-After":         "",
+	})
+
+	request("192.0.2.1:1234")
+	w = request("192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status = %d, want 429", w.Code)
+	}
+	wantHeaders(w, map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "120", "Retry-After": "60"})
+	var problem models.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != "rate_limited" {
+		t.Errorf("body = %s, want a rate_limited problem", w.Body)
+	}
+
+	// Retry-After rounds up, so a client waiting that long is let through
+	clock.Advance(59*time.Second + 500*time.Millisecond)
+	wantHeaders(request("192.0.2.1:1234"), map[string]string{"Retry-After": "1"})
+	clock.Advance(time.Second)
+	if w := request("192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("after Retry-After: status = %d, want 200", w.Code)
+	}
+
+	if w := request("192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("another client was limited: status = %d", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareStoreFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimitMiddleware("login", RateLimit{Requests: 1, Per: time.Minute}, KeyByIP, failingRateLimitStore{}))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want the request let through", w.Code)
+	}
+	if w.Header().Get("RateLimit-Limit") != "" {
+		t.Error("headers were set without a result")
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		keyFunc  RateLimitKeyFunc
+		username string
+		apiKey   string
+		want     string
+	}{
+		{name: "ip", keyFunc: KeyByIP, username: "alice", apiKey: "k1", want: "ip:192.0.2.1"},
+		{name: "username", keyFunc: KeyByUsername, username: "alice", want: "user:alice"},
+		{name: "username before authentication", keyFunc: KeyByUsername, want: "ip:192.0.2.1"},
+		{name: "api key", keyFunc: KeyByAPIKey, apiKey: "k1", want: "key:k1"},
+		{name: "no api key", keyFunc: KeyByAPIKey, username: "alice", want: "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+			if tt.username != "" {
+				c.Set("username", tt.username)
+			}
+			if tt.apiKey != "" {
+				c.Request.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if got := tt.keyFunc(c); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{value: "5/1m", want: RateLimit{Requests: 5, Per: time.Minute}},
+		{value: "100/1s:200", want: RateLimit{Requests: 100, Per: time.Second, Burst: 200}},
+		{value: " 10 / 1h : 3 ", want: RateLimit{Requests: 10, Per: time.Hour, Burst: 3}},
+		{value: "5", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "5/soon", wantErr: true},
+		{value: "5/-1m", wantErr: true},
+		{value: "5/1m:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimit(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseRateLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrator, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations := migrator.Migrations()
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	// Versions start at 1 without gaps, so a missing file is noticed
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d is %s, want version %d", i, m, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("%s lacks an up or down script", m)
+		}
+	}
+	if latest := migrator.Latest(); latest != migrations[len(migrations)-1].Version {
+		t.Errorf("Latest = %d, want %d", latest, migrations[len(migrations)-1].Version)
+	}
+
+	// Every file of the directory is a migration script
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !fileName.MatchString(entry.Name()) {
+			t.Errorf("%s is not named <version>_<name>.(up|down).sql and is ignored", entry.Name())
+		}
+	}
+	if len(entries) != 2*len(migrations) {
+		t.Errorf("%d files for %d migrations", len(entries), len(migrations))
+	}
+}
+
+func TestNewFromFSOrder(t *testing.T) {
+	migrator, err := NewFromFS(nil, fstest.MapFS{
+		"10_tenth.up.sql":    {Data: []byte("SELECT 10;")},
+		"2_second.up.sql":    {Data: []byte("SELECT 2;")},
+		"2_second.down.sql":  {Data: []byte("SELECT -2;")},
+		"0001_first.up.sql":  {Data: []byte("SELECT 1;")},
+		"README.md":          {Data: []byte("not a migration")},
+		"0003_third.sql":     {Data: []byte("no direction")},
+		"old/0004_x.up.sql":  {Data: []byte("in a subdirectory")},
+		"0005_fifth.up.sql~": {Data: []byte("editor backup")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, m := range migrator.Migrations() {
+		got = append(got, m.String())
+	}
+	// Numeric order, not the lexical order of the file names
+	if want := "0001_first 0002_second 0010_tenth"; strings.Join(got, " ") != want {
+		t.Errorf("migrations = %v, want %s", got, want)
+	}
+	if migrator.Latest() != 10 {
+		t.Errorf("Latest = %d, want 10", migrator.Latest())
+	}
+	if second := migrator.Migrations()[1]; second.Up != "SELECT 2;" || second.Down != "SELECT -2;" {
+		t.Errorf("0002 = %+v, want both scripts", second)
+	}
+
+	// Migrations returns a copy
+	migrator.Migrations()[0].Up = "DROP TABLE users;"
+	if migrator.Migrations()[0].Up != "SELECT 1;" {
+		t.Error("changing the result of Migrations changed the migrator")
+	}
+}
+
+func TestNewFromFSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{
+			name: "two names for a version",
+			fsys: fstest.MapFS{"0001_users.up.sql": {Data: []byte("SELECT 1;")}, "0001_accounts.down.sql": {Data: []byte("SELECT 1;")}},
+			want: "two names",
+		},
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{"0001_users.down.sql": {Data: []byte("SELECT 1;")}},
+			want: "no up script",
+		},
+		{
+			name: "empty up script",
+			fsys: fstest.MapFS{"0001_users.up.sql": {Data: nil}},
+			want: "no up script",
+		},
+	}
+	for _, tt := range tests {
+		_, err := NewFromFS(nil, tt.fsys)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	m := Migration{Version: 1, Name: "users", Up: "CREATE TABLE users (id bigint);", Down: "DROP TABLE users;"}
+	sum := sha256.Sum256([]byte(m.Up))
+	if got := m.Checksum(); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("Checksum = %s, want the SHA-256 of the up script", got)
+	}
+
+	// Only the up script counts: fixing a down script doesn't fail applied databases
+	edited := m
+	edited.Down = "DROP TABLE IF EXISTS users;"
+	edited.Name = "accounts"
+	if edited.Checksum() != m.Checksum() {
+		t.Error("changing the down script or the name changed the checksum")
+	}
+	edited.Up = "CREATE TABLE users (id bigint); "
+	if edited.Checksum() == m.Checksum() {
+		t.Error("changing the up script kept the checksum")
+	}
+}
+
+// checksums are the up scripts of the released migrations as recorded in migrated
+// databases. Add the checksum of each new migration; never change an existing one, write
+// a new migration instead.
+var checksums = map[string]string{
+	"0001_initial_schema":       "c9e651be9ae6c2a58c7da624f9a5423c8f0055f4ed77fe7f8ff0a3964f0d9ed2",
+	"0002_user_constraints":     "f65f0017d357697c390fdfb4fbef1b9a3d6eefd13cc3be299b5e801a9405c2f3",
+	"0003_user_status":          "cedb3620b78cd8b369b4ae11b957ebf57b756c0bd8292605bc40678cac7a89ae",
+	"0004_setup_tokens":         "9e9fa684d80b1fd673d317e5d52566bd40eaa3381945da917c237f1d92f56771",
+	"0005_invitations":          "23057a67c4751ddae0e30c33d23be7fe3bc105dbed80b508e6e55db32212cef1",
+	"0006_organizations":        "78fb60255356a9080d520be174c95c4a1ea982c669a0293e369782490ff13aa4",
+	"0007_groups":               "31728614413037921602411f1bf1609f119c6978fb64bfcb98d5cbc18716f8c9",
+	"0008_role_parents":         "163d540a3dfba081ee09d35b33833cfa7bfdf6f772d901e524abd0c5cad93937",
+	"0009_resource_permissions": "01194d56e70535ef8f6679e4179ad26910099b078a12873845a1aa7d43c487b7",
+}
+
+// Editing an applied up script makes every database that ran it refuse to migrate
+func TestEmbeddedChecksums(t *testing.T) {
+	migrator, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrator.Migrations() {
+		want, ok := checksums[m.String()]
+		if !ok {
+			t.Errorf("%s: add its checksum %s to the released ones", m, m.Checksum())
+			continue
+		}
+		if got := m.Checksum(); got != want {
+			t.Errorf("%s: checksum = %s, want %s; the up script of a released migration was edited", m, got, want)
+		}
+	}
+	if len(migrator.Migrations()) < len(checksums) {
+		t.Errorf("%d migrations for %d released ones; a released migration was removed", len(migrator.Migrations()), len(checksums))
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS web_authn_credentials;
+DROP TABLE IF EXISTS magic_links;
+DROP TABLE IF EXISTS logins;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS roles;
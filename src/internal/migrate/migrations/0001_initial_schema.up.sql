@@ -0,0 +1,74 @@
+-- Tables as previously created by gorm's AutoMigrate. IF NOT EXISTS lets databases
+-- created that way adopt the migrations. Tables created by releases before a column
+-- existed get it from ADD COLUMN IF NOT EXISTS.
+
+CREATE TABLE IF NOT EXISTS roles (
+    id                 bigserial PRIMARY KEY,
+    name               text NOT NULL CONSTRAINT uni_roles_name UNIQUE,
+    permissions        text[],
+    magic_link_enabled boolean NOT NULL DEFAULT false,
+    created_at         timestamptz
+);
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS magic_link_enabled boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS users (
+    id          bigserial PRIMARY KEY,
+    first       text,
+    last        text,
+    email       text,
+    phone       text,
+    username    text,
+    password    text,
+    role_id     bigint,
+    auth_source text NOT NULL DEFAULT 'local',
+    passkey_mfa boolean NOT NULL DEFAULT false,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_source text NOT NULL DEFAULT 'local';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passkey_mfa boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS logins (
+    id         bigserial PRIMARY KEY,
+    username   text NOT NULL,
+    password   text NOT NULL,
+    method     text NOT NULL DEFAULT 'password',
+    outcome    text NOT NULL DEFAULT 'success',
+    detail     text,
+    request_id text,
+    login_time timestamptz DEFAULT current_timestamp
+);
+ALTER TABLE logins ADD COLUMN IF NOT EXISTS method text NOT NULL DEFAULT 'password';
+ALTER TABLE logins ADD COLUMN IF NOT EXISTS outcome text NOT NULL DEFAULT 'success';
+ALTER TABLE logins ADD COLUMN IF NOT EXISTS detail text;
+ALTER TABLE logins ADD COLUMN IF NOT EXISTS request_id text;
+CREATE INDEX IF NOT EXISTS idx_logins_request_id ON logins (request_id);
+
+CREATE TABLE IF NOT EXISTS magic_links (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_id ON magic_links (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_magic_links_token_hash ON magic_links (token_hash);
+
+CREATE TABLE IF NOT EXISTS web_authn_credentials (
+    id               bigserial PRIMARY KEY,
+    user_id          bigint NOT NULL,
+    name             text NOT NULL,
+    credential_id    bytea NOT NULL,
+    public_key       bytea NOT NULL,
+    attestation_type text,
+    aa_guid          bytea,
+    sign_count       bigint,
+    transports       text[],
+    backup_eligible  boolean,
+    backup_state     boolean,
+    last_used_at     timestamptz,
+    created_at       timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_web_authn_credentials_user_id ON web_authn_credentials (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_web_authn_credentials_credential_id ON web_authn_credentials (credential_id);
@@ -0,0 +1,4 @@
+ALTER TABLE web_authn_credentials DROP CONSTRAINT IF EXISTS fk_web_authn_credentials_user;
+ALTER TABLE magic_links DROP CONSTRAINT IF EXISTS fk_magic_links_user;
+DROP INDEX IF EXISTS uni_users_email;
+DROP INDEX IF EXISTS uni_users_username;
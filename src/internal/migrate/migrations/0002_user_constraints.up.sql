@@ -0,0 +1,15 @@
+-- Usernames and email addresses are unique, which lets the services report duplicates
+-- as conflicts instead of relying on a check before the insert. Directory users may
+-- have no email address.
+CREATE UNIQUE INDEX uni_users_username ON users (username);
+CREATE UNIQUE INDEX uni_users_email ON users (email) WHERE email <> '';
+
+-- Login links and passkeys belong to a user and go away with it. Rows left behind by
+-- users deleted before these keys existed can never be used and are removed first.
+DELETE FROM magic_links WHERE user_id NOT IN (SELECT id FROM users);
+ALTER TABLE magic_links
+    ADD CONSTRAINT fk_magic_links_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+DELETE FROM web_authn_credentials WHERE user_id NOT IN (SELECT id FROM users);
+ALTER TABLE web_authn_credentials
+    ADD CONSTRAINT fk_web_authn_credentials_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+-- Administrators can disable accounts and revoke the tokens issued to a user; tokens
+-- issued before tokens_revoked_at are rejected.
+ALTER TABLE users ADD COLUMN disabled boolean NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN tokens_revoked_at timestamptz;
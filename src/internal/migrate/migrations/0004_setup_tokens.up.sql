@@ -0,0 +1,8 @@
+-- One-time tokens for creating the first administrator of a fresh installation
+CREATE TABLE setup_tokens (
+    id         bigserial PRIMARY KEY,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_setup_tokens_token_hash ON setup_tokens (token_hash);
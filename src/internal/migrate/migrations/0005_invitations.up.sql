@@ -0,0 +1,14 @@
+-- Invitations let administrators onboard users into a role unless registration is closed
+CREATE TABLE invitations (
+    id          bigserial PRIMARY KEY,
+    email       text NOT NULL,
+    role_id     bigint NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    token_hash  text NOT NULL,
+    invited_by  text NOT NULL DEFAULT '',
+    expires_at  timestamptz NOT NULL,
+    accepted_at timestamptz,
+    user_id     bigint REFERENCES users (id) ON DELETE SET NULL,
+    created_at  timestamptz
+);
+CREATE INDEX idx_invitations_email ON invitations (email);
+CREATE UNIQUE INDEX idx_invitations_token_hash ON invitations (token_hash);
@@ -0,0 +1,13 @@
+ALTER TABLE invitations DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS memberships;
+ALTER TABLE users DROP COLUMN IF EXISTS super_admin;
+
+-- Roles owned by an organization can't keep their names unique without it
+DROP INDEX IF EXISTS idx_roles_organization_name;
+DROP INDEX IF EXISTS idx_roles_shared_name;
+UPDATE users SET role_id = NULL WHERE role_id IN (SELECT id FROM roles WHERE organization_id IS NOT NULL);
+DELETE FROM roles WHERE organization_id IS NOT NULL;
+ALTER TABLE roles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE roles ADD CONSTRAINT uni_roles_name UNIQUE (name);
+
+DROP TABLE IF EXISTS organizations;
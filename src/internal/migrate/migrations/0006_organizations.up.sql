@@ -0,0 +1,37 @@
+-- Organizations are tenants. Users belong to them through memberships with a role in
+-- each; roles are shared by every organization or owned by one.
+CREATE TABLE organizations (
+    id         bigserial PRIMARY KEY,
+    name       text NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_organizations_name ON organizations (name);
+
+CREATE TABLE memberships (
+    user_id         bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    organization_id bigint NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    role_id         bigint NOT NULL REFERENCES roles (id),
+    created_at      timestamptz,
+    PRIMARY KEY (user_id, organization_id)
+);
+CREATE INDEX idx_memberships_organization_id ON memberships (organization_id);
+
+ALTER TABLE users ADD COLUMN super_admin boolean NOT NULL DEFAULT false;
+
+-- Role names are unique among the shared roles and within each organization
+ALTER TABLE roles ADD COLUMN organization_id bigint REFERENCES organizations (id) ON DELETE CASCADE;
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS uni_roles_name;
+CREATE UNIQUE INDEX idx_roles_shared_name ON roles (name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX idx_roles_organization_name ON roles (organization_id, name) WHERE organization_id IS NOT NULL;
+
+ALTER TABLE invitations ADD COLUMN organization_id bigint REFERENCES organizations (id) ON DELETE CASCADE;
+
+-- An existing installation becomes the organization "default": every user joins it with
+-- their role, and administrators become super-admins so they keep seeing everything
+INSERT INTO organizations (name, created_at) VALUES ('default', now());
+INSERT INTO memberships (user_id, organization_id, role_id, created_at)
+SELECT u.id, o.id, u.role_id, now()
+FROM users u
+JOIN roles r ON r.id = u.role_id
+CROSS JOIN organizations o;
+UPDATE users SET super_admin = true WHERE role_id IN (SELECT id FROM roles WHERE name = 'admin');
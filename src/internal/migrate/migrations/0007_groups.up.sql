@@ -0,0 +1,24 @@
+-- Groups give their members the permissions of the group's roles on top of their own role.
+-- Names are unique among the groups outside organizations and within each organization.
+CREATE TABLE groups (
+    id              bigserial PRIMARY KEY,
+    name            text NOT NULL,
+    organization_id bigint REFERENCES organizations (id) ON DELETE CASCADE,
+    created_at      timestamptz
+);
+CREATE UNIQUE INDEX idx_groups_shared_name ON groups (name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX idx_groups_organization_name ON groups (organization_id, name) WHERE organization_id IS NOT NULL;
+
+CREATE TABLE group_members (
+    group_id   bigint NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at timestamptz,
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX idx_group_members_user_id ON group_members (user_id);
+
+CREATE TABLE group_roles (
+    group_id bigint NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    role_id  bigint NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, role_id)
+);
@@ -0,0 +1,2 @@
+-- Roles inherit the permissions of their parent roles
+ALTER TABLE roles ADD COLUMN parent_ids bigint[];
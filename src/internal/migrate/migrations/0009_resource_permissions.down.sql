@@ -0,0 +1,13 @@
+-- Back to the bare verbs: any users, roles, groups or invitations permission grants the
+-- verb on all of them
+UPDATE roles
+SET permissions = ARRAY(
+    SELECT DISTINCT CASE
+        WHEN name = 'health.read' THEN 'health'
+        WHEN split_part(name, '.', 1) IN ('users', 'roles', 'groups', 'invitations') THEN split_part(name, '.', 2)
+        ELSE name
+    END AS verb
+    FROM unnest(roles.permissions) AS granted(name)
+    ORDER BY verb
+)
+WHERE permissions::text LIKE '%.%';
@@ -0,0 +1,20 @@
+-- Permissions name the resource they apply to, e.g. users.create instead of create, so
+-- creating users no longer implies creating roles. Roles keep what they could do before,
+-- except that roles, which list the permissions of everyone, are only read by those who
+-- may create or update them, and invitations, which show who was invited into which role,
+-- only by those who may invite.
+UPDATE roles
+SET permissions = ARRAY(
+    SELECT DISTINCT granted.name
+    FROM unnest(roles.permissions) AS old(name)
+    CROSS JOIN LATERAL unnest(CASE old.name
+        WHEN 'read' THEN ARRAY['users.read', 'groups.read']
+        WHEN 'create' THEN ARRAY['users.create', 'roles.create', 'groups.create', 'invitations.create', 'invitations.read', 'roles.read']
+        WHEN 'update' THEN ARRAY['users.update', 'roles.update', 'groups.update', 'roles.read']
+        WHEN 'delete' THEN ARRAY['users.delete', 'groups.delete', 'invitations.delete']
+        WHEN 'health' THEN ARRAY['health.read']
+        ELSE ARRAY[old.name]
+    END) AS granted(name)
+    ORDER BY granted.name
+)
+WHERE permissions && ARRAY['read', 'create', 'update', 'delete', 'health'];
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go_api/src/internal/models"
+)
+
+// openPostgres returns a connection to an empty schema of the database of TEST_DATABASE_URL,
+// a postgres:// URL, dropped when the test ends. Tests needing it are skipped without it.
+func openPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("TEST_DATABASE_URL: %v", err)
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	db, err := gorm.Open(postgres.Open(u.String()), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to schema %s: %v", schema, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// Databases of the baseline release lack columns added before the migrations existed;
+// migrating them must add those and end with the schema of the models
+func TestUpFromBaselineSchema(t *testing.T) {
+	db := openPostgres(t)
+	baseline, err := os.ReadFile("testdata/baseline_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(string(baseline)).Error; err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+
+	sqlDB, _ := db.DB()
+	migrator, err := New(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) != len(migrator.Migrations()) {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(migrator.Migrations()))
+	}
+	if err := CheckSchema(ctx, db, models.All()...); err != nil {
+		t.Error(err)
+	}
+
+	var user models.User
+	if err := db.Preload("Role").First(&user, "username = ?", "alice").Error; err != nil {
+		t.Fatalf("baseline user: %v", err)
+	}
+	if user.AuthSource != models.AuthSourceLocal || user.PasskeyMFA || user.Role.MagicLinkEnabled {
+		t.Errorf("alice = auth source %q, passkey MFA %v, magic links %v, want the defaults", user.AuthSource, user.PasskeyMFA, user.Role.MagicLinkEnabled)
+	}
+	if !slices.Contains(user.Role.Permissions, "users.read") || slices.Contains(user.Role.Permissions, "read") {
+		t.Errorf("admin permissions = %v, want them named after their resource", user.Role.Permissions)
+	}
+	var login models.Login
+	if err := db.First(&login, "username = ?", "alice").Error; err != nil || login.Method != models.LoginMethodPassword || login.Outcome != models.LoginOutcomeSuccess {
+		t.Errorf("baseline login = %+v, %v, want a successful password login", login, err)
+	}
+}
@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CheckSchema verifies that the table and every column of each model exist, so a
+// model change without a migration is caught at startup rather than by a failing query
+func CheckSchema(ctx context.Context, db *gorm.DB, models ...interface{}) error {
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	var problems []string
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+		if !migrator.HasTable(table) {
+			problems = append(problems, "missing table "+table)
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(table)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(columnTypes))
+		for _, column := range columnTypes {
+			existing[column.Name()] = true
+		}
+		for _, dbName := range stmt.Schema.DBNames {
+			if !existing[dbName] {
+				problems = append(problems, fmt.Sprintf("missing column %s.%s", table, dbName))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("schema does not match the models: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+-- The tables of the baseline release, as its AutoMigrate created them, with a role of
+-- the former bare permissions, a user and a login
+CREATE TABLE roles (
+    id          bigserial PRIMARY KEY,
+    name        text NOT NULL,
+    permissions text[],
+    created_at  timestamptz
+);
+
+CREATE TABLE users (
+    id         bigserial PRIMARY KEY,
+    first      text,
+    last       text,
+    email      text,
+    phone      text,
+    username   text,
+    password   text,
+    role_id    bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_users_role FOREIGN KEY (role_id) REFERENCES roles (id)
+);
+
+CREATE TABLE logins (
+    id         bigserial PRIMARY KEY,
+    username   text NOT NULL,
+    password   text NOT NULL,
+    login_time timestamptz DEFAULT current_timestamp
+);
+
+INSERT INTO roles (name, permissions, created_at) VALUES ('admin', '{read,create,update,delete}', now());
+INSERT INTO users (username, email, password, role_id, created_at, updated_at)
+VALUES ('alice', 'alice@example.com', 'hash', 1, now(), now());
+INSERT INTO logins (username, password) VALUES ('alice', 'hash');
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// Group is a set of users sharing roles. Members hold the permissions of the group's roles
+// on top of their own role.
+type Group struct {
+	ID uint `json:"id" gorm:"primaryKey" example:"1"`
+	// Name may contain lower-case letters, digits, '_' and '-', and starts with a letter
+	Name string `json:"name" gorm:"not null" binding:"required,min=2,max=64,group_name" example:"support"`
+	// OrganizationID is the organization owning the group; groups created outside
+	// organizations have none
+	OrganizationID *uint     `json:"organization_id" binding:"-" example:"1"`
+	Roles          []Role    `json:"roles" gorm:"many2many:group_roles" binding:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GroupMember makes a user a member of a group
+type GroupMember struct {
+	GroupID   uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// GroupRole gives the members of a group a role
+type GroupRole struct {
+	GroupID uint `gorm:"primaryKey"`
+	RoleID  uint `gorm:"primaryKey"`
+}
+
+// EffectivePermissions are the permissions a user holds and where each comes from
+type EffectivePermissions struct {
+	UserID      uint                  `json:"user_id" example:"7"`
+	Username    string                `json:"username" example:"alice"`
+	Permissions []EffectivePermission `json:"permissions"`
+}
+
+// EffectivePermission is a permission with the roles granting it
+type EffectivePermission struct {
+	Permission string             `json:"permission" example:"users.read"`
+	Sources    []PermissionSource `json:"sources"`
+}
+
+// PermissionSource is a role granting a permission, either the user's own role or the role
+// of one of their groups. InheritedFrom names the ancestor of the role declaring the
+// permission, if the role doesn't declare it itself.
+type PermissionSource struct {
+	RoleID        uint   `json:"role_id" example:"2"`
+	Role          string `json:"role" example:"guest"`
+	GroupID       *uint  `json:"group_id,omitempty" example:"1"`
+	Group         string `json:"group,omitempty" example:"support"`
+	InheritedFrom string `json:"inherited_from,omitempty" example:"viewer"`
+}
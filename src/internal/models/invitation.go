@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation lets the holder of its token register with Role in every registration mode but
+// closed. Only the SHA-256 hash of the token is stored.
+type Invitation struct {
+	ID     uint   `json:"id" gorm:"primaryKey" example:"1"`
+	Email  string `json:"email" gorm:"not null;index" example:"new.admin@example.com"`
+	RoleID uint   `json:"role_id" gorm:"not null" example:"1"`
+	// OrganizationID is the organization the invitee joins, the inviter's active one
+	OrganizationID *uint      `json:"organization_id" example:"1"`
+	Role           Role       `json:"role" gorm:"foreignKey:RoleID"`
+	TokenHash      string     `json:"-" gorm:"not null;uniqueIndex"`
+	InvitedBy      string     `json:"invited_by" gorm:"not null;default:''" example:"admin"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	UserID         *uint      `json:"user_id" example:"7"` // the user who registered with it
+	User           *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// InvitationRequest represents the payload for inviting someone with a role
+type InvitationRequest struct {
+	Email  string `json:"email" binding:"required,email,max=254" format:"email" example:"new.admin@example.com"`
+	RoleID uint   `json:"role_id" binding:"required" example:"1"`
+}
+
+// InvitationAcceptRequest represents the payload for accepting an invitation. The email
+// address and role come from the invitation.
+type InvitationAcceptRequest struct {
+	Token string `json:"token" binding:"required" example:"Jx0w5v...8Qk"`
+	// Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit
+	Username string `json:"username" binding:"required,min=3,max=32,username" example:"johndoe"`
+	Password string `json:"password" binding:"required,min=8,max=72" example:"strongpassword123"`
+	First    string `json:"first" binding:"max=100" example:"John"`
+	Last     string `json:"last" binding:"max=100" example:"Doe"`
+	// Phone is a number in E.164 format, e.g. +14155550123
+	Phone string `json:"phone" binding:"omitempty,e164" example:"+1234567890"`
+}
+
+// InvitationCreated is an invitation with the token that was mailed, which is only
+// returned when the invitation is created or resent
+type InvitationCreated struct {
+	Invitation
+	Token string `json:"token" example:"Jx0w5v...8Qk"`
+	URL   string `json:"url" example:"http://localhost:8081/register?invite=Jx0w5v...8Qk"`
+}
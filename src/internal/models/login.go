@@ -4,11 +4,30 @@ import (
 	"time"
 )
 
+// Login methods recorded in the logins audit table
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodMagicLink = "magic_link"
+	LoginMethodPasskey   = "passkey"
+	LoginMethodMFA       = "password+passkey"
+)
+
+// Login outcomes recorded in the logins audit table
+const (
+	LoginOutcomeSuccess   = "success"
+	LoginOutcomeRequested = "requested"
+	LoginOutcomeRejected  = "rejected"
+)
+
 // Login represents a user's login attempt
 type Login struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Username  string    `json:"username" gorm:"not null"`
 	Password  string    `json:"password" gorm:"not null"`
+	Method    string    `json:"method" gorm:"not null;default:password"`
+	Outcome   string    `json:"outcome" gorm:"not null;default:success"`
+	Detail    string    `json:"detail"`
+	RequestID string    `json:"request_id" gorm:"index"`
 	LoginTime time.Time `json:"login_time" gorm:"default:current_timestamp"`
 }
 
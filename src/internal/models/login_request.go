@@ -4,6 +4,6 @@ package models
 // LoginRequest represents the payload for user login
 // swagger:model
 type LoginRequest struct {
-    Username string `json:"username" example:"admin"`
-    Password string `json:"password" example:"password123"`
+    Username string `json:"username" binding:"required,max=254" example:"admin"`
+    Password string `json:"password" binding:"required,max=1024" example:"password123"`
 }
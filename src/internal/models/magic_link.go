@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// MagicLink is a single-use passwordless login token. Only the SHA-256 hash of the token is stored.
+type MagicLink struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MagicLinkRequest represents the payload for requesting a login link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email,max=254" format:"email" example:"user@example.com"`
+}
+
+// MagicLinkConsumeRequest represents the payload for exchanging a login link token for a JWT
+type MagicLinkConsumeRequest struct {
+	Token string `json:"token" binding:"required" example:"q3ZfV1...Zk2w"`
+}
+
+// RoleMagicLinkRequest represents the payload for enabling or disabling magic-link login for a role
+type RoleMagicLinkRequest struct {
+	Enabled bool `json:"enabled" example:"true"`
+}
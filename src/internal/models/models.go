@@ -0,0 +1,6 @@
+package models
+
+// All returns every model with a table, in migration order
+func All() []interface{} {
+	return []interface{}{&User{}, &Role{}, &Login{}, &MagicLink{}, &WebAuthnCredential{}, &SetupToken{}, &Invitation{}, &Organization{}, &Membership{}, &Group{}, &GroupMember{}, &GroupRole{}}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Organization is a tenant. Users belong to organizations through memberships, with a role
+// in each; roles are either shared by every organization or owned by one.
+type Organization struct {
+	ID uint `json:"id" gorm:"primaryKey" example:"1"`
+	// Name may contain lower-case letters, digits, '_' and '-', and starts with a letter
+	Name      string    `json:"name" gorm:"not null;uniqueIndex" binding:"required,min=2,max=64,organization_name" example:"acme"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Membership is a user's role in an organization
+type Membership struct {
+	UserID         uint         `json:"user_id" gorm:"primaryKey" example:"7"`
+	OrganizationID uint         `json:"organization_id" gorm:"primaryKey" example:"1"`
+	Organization   Organization `json:"organization" gorm:"foreignKey:OrganizationID"`
+	RoleID         uint         `json:"role_id" gorm:"not null" example:"2"`
+	Role           Role         `json:"role" gorm:"foreignKey:RoleID"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// MembershipRequest represents the payload for adding a user to an organization, or
+// changing their role in it
+type MembershipRequest struct {
+	UserID uint `json:"user_id" binding:"required" example:"7"`
+	RoleID uint `json:"role_id" binding:"required" example:"2"`
+}
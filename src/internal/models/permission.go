@@ -0,0 +1,8 @@
+package models
+
+// Permission is an RBAC permission the API checks, with the routes requiring it
+type Permission struct {
+	Name        string   `json:"name" example:"users.read"`
+	Description string   `json:"description" example:"Read users and their effective permissions"`
+	Routes      []string `json:"routes" example:"GET /api/users"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// PolicyEvaluationRequest asks how the access policy decides an action, without performing it
+type PolicyEvaluationRequest struct {
+	// Subject are the attributes of the caller: username, role, organization_id,
+	// super_admin and permissions
+	Subject map[string]any `json:"subject" binding:"required"`
+	Action  string         `json:"action" binding:"required,max=64" example:"user.update"`
+	// ResourceID loads the attributes of that user in the active organization; Resource
+	// adds to or overrides them
+	ResourceID uint           `json:"resource_id" example:"7"`
+	Resource   map[string]any `json:"resource"`
+	// Policy is a YAML policy to evaluate instead of the loaded one
+	Policy string `json:"policy" binding:"max=65536"`
+}
+
+// PolicyDecision is the outcome of a policy evaluation
+type PolicyDecision struct {
+	// Decision is allow, deny or not_applicable, when the route's permission decides
+	Decision string `json:"decision" example:"allow"`
+	// Rule is the rule deciding, empty when none matched
+	Rule string `json:"rule,omitempty" example:"users-update-themselves"`
+	// Matched lists every matching rule in policy order
+	Matched  []string       `json:"matched"`
+	Resource map[string]any `json:"resource"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// Problem is an RFC 7807 problem details body, served as application/problem+json.
+// Code is a stable identifier clients can switch on; Title and Detail are for humans.
+type Problem struct {
+	Type     string `json:"type" example:"about:blank"`
+	Title    string `json:"title" example:"Not Found"`
+	Status   int    `json:"status" example:"404"`
+	Detail   string `json:"detail,omitempty" example:"User not found"`
+	Instance string `json:"instance,omitempty" example:"/api/users/42"`
+	// Code is one of the enums listed for the schema. Codes may be added, so clients
+	// should fall back on Status for a code they don't know
+	Code      string `json:"code" example:"user_not_found" enums:"authentication_unavailable,body_too_large,email_domain_not_allowed,group_exists,group_member_not_found,group_not_found,group_role_not_found,incorrect_password,insufficient_permissions,internal_error,invalid_credentials,invalid_id,invalid_invitation,invalid_magic_link,invalid_policy,invalid_request,invalid_setup_token,invalid_ticket,invalid_token,invitation_accepted,invitation_not_found,invitation_required,magic_link_rate_limited,membership_not_found,missing_token,no_passkeys,not_authenticated,not_member,organization_exists,organization_not_found,parent_role_not_found,passkey_not_found,passkey_registration_failed,policy_denied,rate_limited,registration_closed,role_cycle,role_escalation,role_exists,role_not_found,route_not_found,setup_complete,shared_account,shared_role,super_admin_user,token_revoked,user_exists,user_not_found,validation_failed,webauthn_session_invalid"`
+	RequestID string `json:"request_id,omitempty" example:"8c1f0b6e2d4a4f7e9b3a5c7d9e1f2a3b"`
+	// Errors lists the failed rules of a 422 validation_failed problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one failed validation rule of a request field
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Rule    string `json:"rule" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// RegisterRequest represents the payload for self-registration. There is no role: new
+// users get the configured default role, or the role of their invitation.
+type RegisterRequest struct {
+	InviteToken string `json:"invite_token" example:"Jx0w5v...8Qk"`
+	Email       string `json:"email" binding:"required,email,max=254" format:"email" example:"user@example.com"`
+	// Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit
+	Username string `json:"username" binding:"required,min=3,max=32,username" example:"johndoe"`
+	Password string `json:"password" binding:"required,min=8,max=72" example:"strongpassword123"`
+	First    string `json:"first" binding:"max=100" example:"John"`
+	Last     string `json:"last" binding:"max=100" example:"Doe"`
+	// Phone is a number in E.164 format, e.g. +14155550123
+	Phone string `json:"phone" binding:"omitempty,e164" example:"+1234567890"`
+}
@@ -10,7 +10,21 @@ import (
 // swagger:model
 type Role struct {
     ID          uint           `json:"id" gorm:"primaryKey" example:"1"`
-    Name        string         `json:"name" gorm:"not null" example:"admin"`
-    Permissions pq.StringArray `json:"permissions" gorm:"type:text[]" swaggertype:"array,string" example:"[\"read\",\"write\",\"delete\"]"`
+    // Name may contain lower-case letters, digits, '_' and '-', and starts with a letter
+    Name        string         `json:"name" gorm:"not null" binding:"required,min=2,max=64,role_name" example:"admin"`
+    // Permissions are names listed by GET /api/permissions
+    Permissions pq.StringArray `json:"permissions" gorm:"type:text[]" binding:"dive,permission" swaggertype:"array,string" example:"users.read,users.create,users.update"`
+    // ParentIDs are the roles this role inherits the permissions of
+    ParentIDs   pq.Int64Array  `json:"parent_ids" gorm:"type:bigint[]" swaggertype:"array,integer" example:"2"`
+    MagicLinkEnabled bool      `json:"magic_link_enabled" gorm:"not null;default:false" example:"false"`
+    // OrganizationID is the organization owning the role; shared roles have none
+    OrganizationID *uint       `json:"organization_id" example:"1"`
     CreatedAt   time.Time      `json:"created_at" example:"2023-04-01T12:00:00Z"`
 }
+
+// RoleUpdateRequest replaces the permissions and parent roles of a role
+type RoleUpdateRequest struct {
+    // Permissions are names listed by GET /api/permissions
+    Permissions []string `json:"permissions" binding:"dive,permission" example:"users.read,users.update"`
+    ParentIDs   []uint   `json:"parent_ids" example:"2"`
+}
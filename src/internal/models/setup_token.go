@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// SetupToken is a one-time token for creating the first administrator, logged at startup
+// while no administrator exists. Only the SHA-256 hash of the token is stored.
+type SetupToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetupRequest represents the payload for creating the first administrator with a setup token
+type SetupRequest struct {
+	Token string `json:"token" binding:"required" example:"Jx0w5v...8Qk"`
+	Email string `json:"email" binding:"required,email,max=254" format:"email" example:"admin@example.com"`
+	// Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit
+	Username string `json:"username" binding:"required,min=3,max=32,username" example:"admin"`
+	Password string `json:"password" binding:"required,min=8,max=72" example:"strongpassword123"`
+	First    string `json:"first" binding:"max=100" example:"Ada"`
+	Last     string `json:"last" binding:"max=100" example:"Admin"`
+}
@@ -7,17 +7,34 @@ import (
 	"gorm.io/gorm"
 )
 
+// Authentication backends a user account can originate from
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
 // User represents a user in the system
 type User struct {
 	ID        uint      `json:"id"`
-	First     string    `json:"first"`
-	Last      string    `json:"last"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	Username  string    `json:"username"`
+	First     string    `json:"first" binding:"max=100"`
+	Last      string    `json:"last" binding:"max=100"`
+	Email     string    `json:"email" gorm:"uniqueIndex:uni_users_email,where:email <> ''" binding:"required,email,max=254" format:"email"`
+	// Phone is a number in E.164 format, e.g. +14155550123
+	Phone     string    `json:"phone" binding:"omitempty,e164"`
+	// Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit
+	Username  string    `json:"username" gorm:"uniqueIndex:uni_users_username" binding:"required,min=3,max=32,username"`
 	Password  string    `json:"-"`
+	// RoleID is the user's role in the active organization; outside organizations it is
+	// the role the account was created with
 	RoleID    uint      `json:"role_id"`
-    Role     Role `gorm:"foreignKey:RoleID"` // Ensure this tag is correct
+    Role     Role `gorm:"foreignKey:RoleID" binding:"-"` // Ensure this tag is correct
+	AuthSource string   `json:"auth_source" gorm:"not null;default:local" example:"local"`
+	PasskeyMFA bool     `json:"passkey_mfa" gorm:"not null;default:false"`
+	Disabled   bool     `json:"disabled" gorm:"not null;default:false"`
+	// SuperAdmin users hold every permission and can act in any organization
+	SuperAdmin bool     `json:"super_admin" gorm:"not null;default:false" binding:"-"`
+	// TokensRevokedAt invalidates every token issued before it
+	TokensRevokedAt *time.Time `json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
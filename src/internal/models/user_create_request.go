@@ -2,11 +2,13 @@ package models
 
 // UserCreateRequest represents the payload for creating a new user
 type UserCreateRequest struct {
-    Email    string `json:"email" example:"user@example.com"`
-    Username string `json:"username" example:"johndoe"`
-    Password string `json:"password" example:"strongpassword123"`
-    First    string `json:"first" example:"John"`
-    Last     string `json:"last" example:"Doe"`
-    Phone    string `json:"phone" example:"+1234567890"`
+    Email    string `json:"email" binding:"required,email,max=254" format:"email" example:"user@example.com"`
+    // Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit
+    Username string `json:"username" binding:"required,min=3,max=32,username" example:"johndoe"`
+    Password string `json:"password" binding:"required,min=8,max=72" example:"strongpassword123"`
+    First    string `json:"first" binding:"max=100" example:"John"`
+    Last     string `json:"last" binding:"max=100" example:"Doe"`
+    // Phone is a number in E.164 format, e.g. +14155550123
+    Phone    string `json:"phone" binding:"omitempty,e164" example:"+1234567890"`
     RoleID   uint   `json:"role_id" example:"1"`
 }
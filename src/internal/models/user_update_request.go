@@ -0,0 +1,18 @@
+package models
+
+// UserUpdateRequest represents the payload for updating a user. Passwords, super-admin
+// status and token revocations have their own endpoints and commands and are never
+// written from it.
+type UserUpdateRequest struct {
+	Email string `json:"email" binding:"required,email,max=254" format:"email" example:"user@example.com"`
+	// Username may contain letters, digits, '.', '_' and '-', and starts with a letter or digit
+	Username string `json:"username" binding:"required,min=3,max=32,username" example:"johndoe"`
+	First    string `json:"first" binding:"max=100" example:"John"`
+	Last     string `json:"last" binding:"max=100" example:"Doe"`
+	// Phone is a number in E.164 format, e.g. +14155550123
+	Phone string `json:"phone" binding:"omitempty,e164" example:"+1234567890"`
+	// RoleID is the user's role in the active organization; 0 keeps the current role
+	RoleID uint `json:"role_id" example:"1"`
+	// Disabled disables or re-enables the account; omitted keeps the current status
+	Disabled *bool `json:"disabled" example:"false"`
+}
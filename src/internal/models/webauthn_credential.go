@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebAuthnCredential is a passkey registered by a user
+type WebAuthnCredential struct {
+	ID              uint           `json:"id" gorm:"primaryKey" example:"1"`
+	UserID          uint           `json:"user_id" gorm:"not null;index" example:"1"`
+	User            User           `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Name            string         `json:"name" gorm:"not null" example:"MacBook Touch ID"`
+	CredentialID    []byte         `json:"-" gorm:"not null;uniqueIndex"`
+	PublicKey       []byte         `json:"-" gorm:"not null"`
+	AttestationType string         `json:"attestation_type" example:"none"`
+	AAGUID          []byte         `json:"-"`
+	SignCount       uint32         `json:"sign_count" example:"0"`
+	Transports      pq.StringArray `json:"transports" gorm:"type:text[]" swaggertype:"array,string" example:"internal,hybrid"`
+	BackupEligible  bool           `json:"backup_eligible"`
+	BackupState     bool           `json:"backup_state"`
+	LastUsedAt      *time.Time     `json:"last_used_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// WebAuthnCredentialRenameRequest represents the payload for renaming a passkey
+type WebAuthnCredentialRenameRequest struct {
+	Name string `json:"name" binding:"required,max=64" example:"YubiKey 5C"`
+}
+
+// WebAuthnLoginRequest starts a passkey login. An empty username asks for a discoverable credential.
+type WebAuthnLoginRequest struct {
+	Username string `json:"username" binding:"max=254" example:"johndoe"`
+}
+
+// WebAuthnSecondFactorRequest starts the passkey step of a password login
+type WebAuthnSecondFactorRequest struct {
+	Ticket string `json:"ticket" binding:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
+
+// PasskeyMFARequest enables or disables passkeys as a second factor for the current user
+type PasskeyMFARequest struct {
+	Enabled bool `json:"enabled" example:"true"`
+}
+
+// WebAuthnCeremonyResponse carries the browser options for a ceremony and the session id to send back on finish
+type WebAuthnCeremonyResponse struct {
+	SessionID string      `json:"session_id" example:"4kq1F9n0S2y..."`
+	Options   interface{} `json:"options"`
+}
+
+// SecondFactorResponse is returned by /api/login when the user must still complete a passkey assertion
+type SecondFactorResponse struct {
+	SecondFactorRequired bool   `json:"second_factor_required" example:"true"`
+	Ticket               string `json:"ticket" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
@@ -0,0 +1,231 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// condition compares two operands: `left op right`, where op is ==, !=, in or not in and
+// the operands are subject.x or resource.x attributes, quoted strings, numbers, true,
+// false or lists of these in brackets
+type condition struct {
+	left, right operand
+	op          string
+}
+
+// operand is an attribute reference or a literal value
+type operand struct {
+	scope, name string // subject or resource, and the attribute
+	value       any
+}
+
+func (o operand) resolve(req Request) any {
+	switch o.scope {
+	case "subject":
+		return normalize(req.Subject[o.name])
+	case "resource":
+		return normalize(req.Resource[o.name])
+	}
+	return o.value
+}
+
+func (c condition) holds(req Request) bool {
+	left, right := c.left.resolve(req), c.right.resolve(req)
+	switch c.op {
+	case "==":
+		return left != nil && reflect.DeepEqual(left, right)
+	case "!=":
+		return !reflect.DeepEqual(left, right)
+	case "in":
+		return contains(right, left)
+	case "not in":
+		return !contains(right, left)
+	}
+	return false
+}
+
+// contains reports whether list holds value. A list attribute compared to a list holds
+// when the two share an element.
+func contains(list, value any) bool {
+	items, ok := list.([]any)
+	if !ok || value == nil {
+		return false
+	}
+	if values, ok := value.([]any); ok {
+		return slices.ContainsFunc(values, func(v any) bool { return contains(items, v) })
+	}
+	return slices.ContainsFunc(items, func(item any) bool { return reflect.DeepEqual(item, value) })
+}
+
+// normalize converts attribute values to the types of parsed literals: float64 for
+// numbers and []any for lists, so JSON input and Go values compare alike
+func normalize(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Slice, reflect.Array:
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = normalize(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	}
+	return v
+}
+
+func parseCondition(source string) (condition, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return condition{}, err
+	}
+	p := &parser{tokens: tokens}
+
+	var c condition
+	if c.left, err = p.operand(); err != nil {
+		return condition{}, err
+	}
+	switch op := p.next(); op {
+	case "==", "!=", "in":
+		c.op = op
+	case "not":
+		if p.next() != "in" {
+			return condition{}, errors.New("expected in after not")
+		}
+		c.op = "not in"
+	default:
+		return condition{}, fmt.Errorf("expected ==, !=, in or not in, got %q", op)
+	}
+	if c.right, err = p.operand(); err != nil {
+		return condition{}, err
+	}
+	if !p.done() {
+		return condition{}, fmt.Errorf("unexpected %q", p.next())
+	}
+	if c.left.scope == "" && c.right.scope == "" {
+		return condition{}, errors.New("compares two literals")
+	}
+	return c, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) next() string {
+	if p.done() {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *parser) operand() (operand, error) {
+	token := p.next()
+	if token == "[" {
+		items := []any{}
+		for {
+			if len(items) == 0 && !p.done() && p.tokens[p.pos] == "]" {
+				p.pos++
+				return operand{value: items}, nil
+			}
+			item, err := p.literal(p.next())
+			if err != nil {
+				return operand{}, err
+			}
+			items = append(items, item)
+			switch p.next() {
+			case ",":
+			case "]":
+				return operand{value: items}, nil
+			default:
+				return operand{}, errors.New("unterminated list")
+			}
+		}
+	}
+	if scope, name, ok := strings.Cut(token, "."); ok && (scope == "subject" || scope == "resource") {
+		if name == "" {
+			return operand{}, fmt.Errorf("missing %s attribute", scope)
+		}
+		return operand{scope: scope, name: name}, nil
+	}
+	value, err := p.literal(token)
+	return operand{value: value}, err
+}
+
+func (p *parser) literal(token string) (any, error) {
+	switch {
+	case token == "":
+		return nil, errors.New("missing operand")
+	case strings.HasPrefix(token, `"`):
+		return strconv.Unquote(token)
+	case token == "true" || token == "false":
+		return token == "true", nil
+	}
+	if n, err := strconv.ParseFloat(token, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("unknown operand %q", token)
+}
+
+// tokenize splits a condition into quoted strings, brackets, commas, operators and words
+func tokenize(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		r := rune(source[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(source) && source[end] != '"' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, source[i:end+1])
+			i = end + 1
+		case r == '[' || r == ']' || r == ',':
+			tokens = append(tokens, string(r))
+			i++
+		case strings.HasPrefix(source[i:], "==") || strings.HasPrefix(source[i:], "!="):
+			tokens = append(tokens, source[i:i+2])
+			i += 2
+		default:
+			end := i
+			for end < len(source) && !strings.ContainsRune(" \t\n\r\"[],=!", rune(source[end])) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected %q", source[i])
+			}
+			tokens = append(tokens, source[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,156 @@
+// Package policy evaluates attribute-based access rules. Rules are declared in a policy
+// file and match a request by its action and conditions on the subject, the caller, and
+// the resource it acts on:
+//
+//	rules:
+//	  - name: users-update-themselves
+//	    effect: allow
+//	    actions: [user.update]
+//	    conditions:
+//	      - subject.username == resource.username
+//	  - name: support-never-admins
+//	    effect: deny
+//	    actions: [user.read, user.update, user.delete]
+//	    conditions:
+//	      - subject.role == "support"
+//	      - resource.role in ["admin", "owner"]
+//
+// A matching deny rule wins over a matching allow rule. When no rule matches the decision
+// is NotApplicable and the caller falls back to its permission check.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Effects of a rule, and the decision when no rule matches
+const (
+	Allow         = "allow"
+	Deny          = "deny"
+	NotApplicable = "not_applicable"
+)
+
+// Rule allows or denies its actions when every condition holds
+type Rule struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Effect      string   `yaml:"effect" json:"effect"`
+	Actions     []string `yaml:"actions" json:"actions"`
+	Conditions  []string `yaml:"conditions" json:"conditions"`
+
+	conditions []condition
+}
+
+// Policy is a parsed policy file
+type Policy struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// Request is what a decision is made about
+type Request struct {
+	Subject  map[string]any
+	Action   string
+	Resource map[string]any
+}
+
+// Decision is the outcome of Evaluate: the effect, the rule deciding it and every rule
+// that matched
+type Decision struct {
+	Effect  string
+	Rule    string
+	Matched []string
+}
+
+// Load reads and parses the policy file at path. An empty path is an empty policy, which
+// decides nothing.
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return &Policy{}, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse parses a YAML policy, reporting every invalid rule
+func Parse(content []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(content, &p); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Effect != Allow && rule.Effect != Deny {
+			errs = append(errs, fmt.Errorf("%s: effect must be %s or %s", rule.Name, Allow, Deny))
+		}
+		if len(rule.Actions) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no actions", rule.Name))
+		}
+		for _, source := range rule.Conditions {
+			c, err := parseCondition(source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q: %w", rule.Name, source, err))
+				continue
+			}
+			rule.conditions = append(rule.conditions, c)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Evaluate decides req: deny when a deny rule matches, allow when an allow rule matches,
+// NotApplicable otherwise
+func (p *Policy) Evaluate(req Request) Decision {
+	decision := Decision{Effect: NotApplicable}
+	for _, rule := range p.Rules {
+		if !rule.matches(req) {
+			continue
+		}
+		decision.Matched = append(decision.Matched, rule.Name)
+		if rule.Effect == Deny && decision.Effect != Deny {
+			decision.Effect, decision.Rule = Deny, rule.Name
+		}
+		if rule.Effect == Allow && decision.Effect == NotApplicable {
+			decision.Effect, decision.Rule = Allow, rule.Name
+		}
+	}
+	return decision
+}
+
+// Applies reports whether a rule of p is about action, so a decision needs the resource
+func (p *Policy) Applies(action string) bool {
+	return slices.ContainsFunc(p.Rules, func(rule Rule) bool {
+		return slices.Contains(rule.Actions, action) || slices.Contains(rule.Actions, "*")
+	})
+}
+
+func (r *Rule) matches(req Request) bool {
+	if !slices.Contains(r.Actions, req.Action) && !slices.Contains(r.Actions, "*") {
+		return false
+	}
+	for _, c := range r.conditions {
+		if !c.holds(req) {
+			return false
+		}
+	}
+	return true
+}
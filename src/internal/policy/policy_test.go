@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+rules:
+  - name: users-manage-themselves
+    effect: allow
+    actions: [user.read, user.update]
+    conditions:
+      - subject.username == resource.username
+  - name: support-reads-organization
+    effect: allow
+    actions: [user.read]
+    conditions:
+      - subject.role == "support"
+      - subject.organization_id in resource.organization_ids
+  - name: support-never-admins
+    effect: deny
+    actions: ["*"]
+    conditions:
+      - subject.role == "support"
+      - resource.role in ["admin", "owner"]
+`
+
+func TestEvaluate(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	support := map[string]any{"username": "bob", "role": "support", "organization_id": uint(1)}
+
+	tests := []struct {
+		name     string
+		subject  map[string]any
+		action   string
+		resource map[string]any
+		effect   string
+		rule     string
+		matched  []string
+	}{
+		{
+			name:     "own record",
+			subject:  map[string]any{"username": "alice", "role": "guest"},
+			action:   "user.update",
+			resource: map[string]any{"username": "alice", "role": "guest"},
+			effect:   Allow, rule: "users-manage-themselves", matched: []string{"users-manage-themselves"},
+		},
+		{
+			name:     "in with a list attribute",
+			subject:  support,
+			action:   "user.read",
+			resource: map[string]any{"username": "carol", "role": "guest", "organization_ids": []uint{2, 1}},
+			effect:   Allow, rule: "support-reads-organization", matched: []string{"support-reads-organization"},
+		},
+		{
+			name:     "in misses",
+			subject:  support,
+			action:   "user.read",
+			resource: map[string]any{"username": "carol", "role": "guest", "organization_ids": []uint{2}},
+			effect:   NotApplicable,
+		},
+		{
+			name:     "deny wins over a matching allow",
+			subject:  support,
+			action:   "user.read",
+			resource: map[string]any{"username": "root", "role": "admin", "organization_ids": []uint{1}},
+			effect:   Deny, rule: "support-never-admins", matched: []string{"support-reads-organization", "support-never-admins"},
+		},
+		{
+			name:     "wildcard action",
+			subject:  support,
+			action:   "user.delete",
+			resource: map[string]any{"username": "root", "role": "owner"},
+			effect:   Deny, rule: "support-never-admins", matched: []string{"support-never-admins"},
+		},
+		{
+			name:     "action no rule is about",
+			subject:  map[string]any{"username": "alice"},
+			action:   "user.delete",
+			resource: map[string]any{"username": "alice"},
+			effect:   NotApplicable,
+		},
+		{
+			name:     "missing attributes never match",
+			subject:  map[string]any{},
+			action:   "user.update",
+			resource: map[string]any{},
+			effect:   NotApplicable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := p.Evaluate(Request{Subject: tt.subject, Action: tt.action, Resource: tt.resource})
+			if decision.Effect != tt.effect || decision.Rule != tt.rule || !slices.Equal(decision.Matched, tt.matched) {
+				t.Errorf("Evaluate = %+v, want effect %s, rule %q, matched %v", decision, tt.effect, tt.rule, tt.matched)
+			}
+		})
+	}
+}
+
+func TestEmptyPolicyIsNotApplicable(t *testing.T) {
+	p, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if p.Applies("user.read") {
+		t.Error("empty policy applies to user.read")
+	}
+	if decision := p.Evaluate(Request{Action: "user.read"}); decision.Effect != NotApplicable {
+		t.Errorf("empty policy decided %s", decision.Effect)
+	}
+}
+
+func TestApplies(t *testing.T) {
+	p, err := Parse([]byte("rules:\n  - name: reads\n    effect: allow\n    actions: [user.read]\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !p.Applies("user.read") || p.Applies("user.update") {
+		t.Error("Applies should only report the actions of the rules")
+	}
+	wildcard, _ := Parse([]byte("rules:\n  - name: all\n    effect: deny\n    actions: [\"*\"]\n"))
+	if !wildcard.Applies("user.delete") {
+		t.Error("a * rule applies to every action")
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		condition string
+		subject   map[string]any
+		want      bool
+	}{
+		{`subject.id == 7`, map[string]any{"id": uint(7)}, true},
+		{`subject.id == 7`, map[string]any{"id": 7.0}, true},
+		{`subject.id != 7`, map[string]any{"id": 8}, true},
+		{`subject.id != 7`, map[string]any{}, true},
+		{`subject.super_admin == true`, map[string]any{"super_admin": true}, true},
+		{`subject.super_admin == false`, map[string]any{}, false},
+		{`subject.role in ["admin", "owner"]`, map[string]any{"role": "owner"}, true},
+		{`subject.role not in ["admin", "owner"]`, map[string]any{"role": "guest"}, true},
+		{`subject.role not in []`, map[string]any{"role": "guest"}, true},
+		{`subject.permissions in ["users.update", "users.delete"]`, map[string]any{"permissions": []string{"users.read", "users.update"}}, true},
+		{`subject.permissions in ["users.update", "users.delete"]`, map[string]any{"permissions": []string{"users.read"}}, false},
+		{`"users.update" in subject.permissions`, map[string]any{"permissions": []string{"users.update"}}, true},
+		{`subject.name == "say \"hi\""`, map[string]any{"name": `say "hi"`}, true},
+	}
+	for _, tt := range tests {
+		c, err := parseCondition(tt.condition)
+		if err != nil {
+			t.Errorf("parseCondition(%s): %v", tt.condition, err)
+			continue
+		}
+		if got := c.holds(Request{Subject: tt.subject}); got != tt.want {
+			t.Errorf("%s with %v = %v, want %v", tt.condition, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{"rules:\n  - name: r\n    effect: maybe\n    actions: [user.read]\n", []string{"r: effect must be allow or deny"}},
+		{"rules:\n  - effect: allow\n", []string{"rule 1: no actions"}},
+		{"rules:\n  - name: r\n    effect: allow\n    actions: [user.read]\n    conditions: [\"subject.a = 1\", \"role == 1\", \"\\\"a\\\" == \\\"b\\\"\", \"subject.a in [1,\"]\n", []string{
+			`"subject.a = 1": unexpected '='`,
+			`"role == 1": unknown operand "role"`,
+			`compares two literals`,
+			`"subject.a in [1,": missing operand`,
+		}},
+		{"rules: [", []string{"yaml"}},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.policy))
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded", tt.policy)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Parse(%q) = %v, want it to mention %s", tt.policy, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package requestid
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormPlugin prefixes every statement run with a request context with a
+// /* request_id=... */ comment, so database logs can be matched to the request.
+// Use db.WithContext(ctx) so the id reaches the statement.
+type GormPlugin struct{}
+
+// Name implements gorm.Plugin
+func (GormPlugin) Name() string {
+	return "request_id"
+}
+
+// Initialize implements gorm.Plugin
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("request_id:create", comment("INSERT")),
+		cb.Query().Before("gorm:query").Register("request_id:query", comment("SELECT")),
+		cb.Update().Before("gorm:update").Register("request_id:update", comment("UPDATE")),
+		cb.Delete().Before("gorm:delete").Register("request_id:delete", comment("DELETE")),
+		cb.Row().Before("gorm:row").Register("request_id:row", comment("SELECT")),
+		cb.Raw().Before("gorm:raw").Register("request_id:raw", comment("")),
+	)
+}
+
+// comment attaches the comment to the first clause of the statement. Raw statements
+// already hold their SQL, so the comment is prepended to it instead.
+func comment(firstClause string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		id := FromContext(db.Statement.Context)
+		if id == "" {
+			return
+		}
+		text := sqlComment{id: id}
+
+		if db.Statement.SQL.Len() > 0 {
+			if !strings.HasPrefix(db.Statement.SQL.String(), "/*") {
+				sql := text.String() + " " + db.Statement.SQL.String()
+				db.Statement.SQL.Reset()
+				db.Statement.SQL.WriteString(sql)
+			}
+			return
+		}
+		if firstClause == "" {
+			return
+		}
+		c := db.Statement.Clauses[firstClause]
+		c.BeforeExpression = text
+		db.Statement.Clauses[firstClause] = c
+	}
+}
+
+// sqlComment is a clause expression writing the request id comment
+type sqlComment struct {
+	id string
+}
+
+func (c sqlComment) String() string {
+	// Ids from clients are checked by Valid, but never let one close the comment
+	return "/* request_id=" + strings.ReplaceAll(c.id, "*/", "") + " */"
+}
+
+// Build implements clause.Expression
+func (c sqlComment) Build(builder clause.Builder) {
+	builder.WriteString(c.String())
+}
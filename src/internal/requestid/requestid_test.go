@@ -0,0 +1,101 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, FromContext(c.Request.Context())) })
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "given", header: "req-42.a:b_c", keep: true},
+		{name: "missing"},
+		{name: "too long", header: strings.Repeat("a", 129)},
+		{name: "closes a SQL comment", header: "x*/ DROP TABLE users; /*"},
+		{name: "spaces", header: "two words"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(Header)
+			if id != w.Body.String() {
+				t.Errorf("response header %q, context %q, want the same id", id, w.Body)
+			}
+			if tt.keep && id != tt.header {
+				t.Errorf("id = %q, want the given %q", id, tt.header)
+			}
+			if !tt.keep && (id == tt.header || len(id) != 32) {
+				t.Errorf("id = %q, want a generated one", id)
+			}
+		})
+	}
+}
+
+// Statements run with a request context carry its id in a comment; others are unchanged
+func TestGormPlugin(t *testing.T) {
+	db := dbtest.New(t)
+	if err := db.Use(GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	var statements []string
+	record := func(db *gorm.DB) { statements = append(statements, db.Statement.SQL.String()) }
+	// Inserts aren't checked: SQLite builds INSERT clauses itself and drops the comment,
+	// unlike the default builder Postgres uses
+	cb := db.Callback()
+	cb.Query().After("gorm:query").Register("test:query", record)
+	cb.Update().After("gorm:update").Register("test:update", record)
+	cb.Delete().After("gorm:delete").Register("test:delete", record)
+	cb.Raw().After("gorm:raw").Register("test:raw", record)
+
+	ctx := WithID(context.Background(), "req-42")
+	tx := db.WithContext(ctx)
+	role := &models.Role{Name: "guest"}
+	tx.Create(role)
+	tx.First(&models.Role{}, role.ID)
+	tx.Model(role).Update("name", "member")
+	tx.Exec("UPDATE roles SET permissions = NULL")
+	tx.Delete(role)
+	if len(statements) != 4 {
+		t.Fatalf("recorded %d statements, want 4: %q", len(statements), statements)
+	}
+	for _, sql := range statements {
+		if !strings.HasPrefix(sql, "/* request_id=req-42 */ ") {
+			t.Errorf("statement without the request id: %s", sql)
+		}
+	}
+
+	statements = nil
+	db.First(&models.Role{})
+	if len(statements) != 1 || strings.Contains(statements[0], "request_id") {
+		t.Errorf("statement outside a request = %q, want no comment", statements)
+	}
+}
+
+func TestSQLCommentCannotBeClosed(t *testing.T) {
+	if got := (sqlComment{id: "a*/b"}).String(); got != "/* request_id=ab */" {
+		t.Errorf("comment = %s", got)
+	}
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go_api/src/internal/models"
+)
+
+// RBAC permissions required by the route table. Roles grant them by name, which is the
+// resource followed by the verb, so granting one verb on a resource grants nothing on the others.
+const (
+	PermissionUsersRead         = "users.read"
+	PermissionUsersCreate       = "users.create"
+	PermissionUsersUpdate       = "users.update"
+	PermissionUsersDelete       = "users.delete"
+	PermissionRolesRead         = "roles.read"
+	PermissionRolesCreate       = "roles.create"
+	PermissionRolesUpdate       = "roles.update"
+	PermissionGroupsRead        = "groups.read"
+	PermissionGroupsCreate      = "groups.create"
+	PermissionGroupsUpdate      = "groups.update"
+	PermissionGroupsDelete      = "groups.delete"
+	PermissionInvitationsRead   = "invitations.read"
+	PermissionInvitationsCreate = "invitations.create"
+	PermissionInvitationsDelete = "invitations.delete"
+	PermissionHealthRead        = "health.read"
+)
+
+// permissionDescriptions is the registry of RBAC permissions: a route may only require a
+// permission described here, and roles may only grant those the routes require
+var permissionDescriptions = map[string]string{
+	PermissionUsersRead:         "Read users and their effective permissions",
+	PermissionUsersCreate:       "Create users",
+	PermissionUsersUpdate:       "Update users, including their role and status",
+	PermissionUsersDelete:       "Delete users",
+	PermissionRolesRead:         "Read roles",
+	PermissionRolesCreate:       "Create roles",
+	PermissionRolesUpdate:       "Update the permissions and parents of roles and configure their magic-link login",
+	PermissionGroupsRead:        "Read groups and their members",
+	PermissionGroupsCreate:      "Create groups",
+	PermissionGroupsUpdate:      "Update groups and manage their members and roles",
+	PermissionGroupsDelete:      "Delete groups",
+	PermissionInvitationsRead:   "Read invitations",
+	PermissionInvitationsCreate: "Create and resend invitations",
+	PermissionInvitationsDelete: "Revoke invitations",
+	PermissionHealthRead:        "Read the detailed health report",
+}
+
+// Permissions returns the RBAC permissions checked by the route table
+func Permissions() []string {
+	return permissionNames(Table(Services{}))
+}
+
+func permissionNames(table []Route) []string {
+	var names []string
+	for _, permission := range registry(table) {
+		names = append(names, permission.Name)
+	}
+	return names
+}
+
+// registry lists the RBAC permissions required by table with their description and the
+// routes requiring them, ordered by name
+func registry(table []Route) []models.Permission {
+	var result []models.Permission
+	for _, route := range table {
+		switch route.Permission {
+		case PermissionPublic, PermissionAuthenticated, PermissionSuperAdmin:
+			continue
+		}
+		i := slices.IndexFunc(result, func(p models.Permission) bool { return p.Name == route.Permission })
+		if i < 0 {
+			result = append(result, models.Permission{Name: route.Permission, Description: permissionDescriptions[route.Permission]})
+			i = len(result) - 1
+		}
+		result[i].Routes = append(result[i].Routes, route.Method+" "+route.Path)
+	}
+	slices.SortFunc(result, func(a, b models.Permission) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return result
+}
+
+// checkTable reports every route without a permission requirement and every permission
+// missing from the registry
+func checkTable(table []Route) error {
+	var errs []error
+	for _, route := range table {
+		switch route.Permission {
+		case PermissionPublic, PermissionAuthenticated, PermissionSuperAdmin:
+		case "":
+			errs = append(errs, fmt.Errorf("%s %s: no permission requirement, use PermissionPublic for routes served without a token", route.Method, route.Path))
+		default:
+			if _, ok := permissionDescriptions[route.Permission]; !ok {
+				errs = append(errs, fmt.Errorf("%s %s: permission %q is not in the registry", route.Method, route.Path, route.Permission))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
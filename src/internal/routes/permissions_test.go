@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableIsValid(t *testing.T) {
+	if err := checkTable(Table(Services{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Every route of a resource requires a permission on that resource, so granting one
+// resource never grants another, e.g. users.create doesn't let a user create roles
+func TestPermissionsNameTheRouteResource(t *testing.T) {
+	resources := map[string]string{
+		"/api/users":        "users.",
+		"/api/roles":        "roles.",
+		"/api/groups":       "groups.",
+		"/api/invitations":  "invitations.",
+		"/api/admin/health": "health.",
+	}
+	for _, route := range Table(Services{}) {
+		switch route.Permission {
+		case PermissionPublic, PermissionAuthenticated, PermissionSuperAdmin:
+			continue
+		}
+		matched := false
+		for prefix, resource := range resources {
+			if route.Path == prefix || strings.HasPrefix(route.Path, prefix+"/") {
+				matched = true
+				if !strings.HasPrefix(route.Permission, resource) {
+					t.Errorf("%s %s requires %s, want a %s permission", route.Method, route.Path, route.Permission, resource+"*")
+				}
+			}
+		}
+		if !matched {
+			t.Errorf("%s %s requires %s but has no resource", route.Method, route.Path, route.Permission)
+		}
+	}
+}
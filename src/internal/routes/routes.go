@@ -1,83 +1,384 @@
 package routes
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
-	"go_api/internal/handlers"
-	"go_api/internal/middleware"
-	"go_api/internal/services"
+	"go_api/src/internal/config"
+	"go_api/src/internal/handlers"
+	"go_api/src/internal/health"
+	"go_api/src/internal/logging"
+	"go_api/src/internal/metrics"
+	"go_api/src/internal/middleware"
+	"go_api/src/internal/models"
+	"go_api/src/internal/policy"
+	"go_api/src/internal/requestid"
+	"go_api/src/internal/services"
+	"go_api/src/internal/tracing"
+	"go_api/src/internal/validation"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-// SetupRouter sets up all the routes for the application
-func SetupRouter(db *gorm.DB) *gin.Engine {
-	r := gin.Default()
-
-	// Enable CORS middleware with custom configuration
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	swaggerYamlDir := os.Getenv("SWAGGER_YAML_DIR")
-	swaggerJsonDir := os.Getenv("SWAGGER_JSON_DIR")
-
-	// Serve swagger docs
-	r.GET("/docs/swagger.json", func(c *gin.Context) {
-		c.File(swaggerYamlDir)
-	})
-	r.GET("/docs/swagger.yaml", func(c *gin.Context) {
-		c.File(swaggerJsonDir)
-	})
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+// Permission values of a Route that are not RBAC permissions
+const (
+	// PermissionPublic routes are served without a token
+	PermissionPublic = "public"
+	// PermissionAuthenticated routes only need a valid token; handlers scope them to the caller
+	PermissionAuthenticated = "authenticated"
+	// PermissionSuperAdmin routes work across organizations and are only served to super-admins
+	PermissionSuperAdmin = "super_admin"
+)
+
+// Rate limit scopes used in the route table
+const (
+	RateLimitAuth     = "auth"
+	RateLimitRegister = "register"
+	RateLimitAPI      = "api"
+)
+
+// Services are the dependencies of the handlers. Nil User, Role, Register, Login, Bootstrap,
+// Invitation, Organization, Group and Permission services are created from the database;
+// the magic-link and passkey routes are only mounted when their service is given. A nil RateLimitStore keeps
+// buckets in memory, a nil Readiness always reports ready and a nil Health checks the
+// database and signing key. A nil Policy is loaded from the configured policy file.
+type Services struct {
+	Login        *services.LoginService
+	Register     *services.RegisterService
+	User         *services.UserService
+	Role         *services.RoleService
+	MagicLink    *services.MagicLinkService
+	WebAuthn     *services.WebAuthnService
+	Bootstrap    *services.BootstrapService
+	Invitation   *services.InvitationService
+	Organization *services.OrganizationService
+	Group        *services.GroupService
+	Permission   *services.PermissionService
+
+	Policy         *policy.Policy
+	RateLimitStore middleware.RateLimitStore
+	Readiness      *health.Readiness
+	Health         *health.Checker
+}
+
+// policyActions are the policy actions of the routes acting on a single user. Their
+// requests are decided by the policy rules before the route's permission.
+var policyActions = map[string]string{
+	http.MethodGet + " /api/users/:id":                       "user.read",
+	http.MethodPut + " /api/users/:id":                       "user.update",
+	http.MethodDelete + " /api/users/:id":                    "user.delete",
+	http.MethodGet + " /api/users/:id/effective-permissions": "user.read",
+}
+
+// Route is one entry of the route table
+type Route struct {
+	Method string
+	Path   string
+	// Permission is the RBAC permission required, one of the registry in permissions.go, or
+	// PermissionAuthenticated, PermissionSuperAdmin or PermissionPublic; New refuses a route
+	// without one
+	Permission string
+	// RateLimit is the rate limit scope, empty for none. Public routes are keyed by client IP, the others by username.
+	RateLimit string
+	Handler   gin.HandlerFunc
+}
+
+// New builds the application handler: global middleware, documentation routes and the route table.
+// It only uses the router settings of cfg, so tests can start from config.Default() and set JWTKey.
+func New(cfg *config.Config, db *gorm.DB, svc Services) (http.Handler, error) {
+	if cfg.JWTKey == "" {
+		return nil, errors.New("JWT key is required")
+	}
+	if err := cfg.HTTP.CORS.Validate(); err != nil {
+		return nil, err
+	}
+	jwtKey := []byte(cfg.JWTKey)
+	if svc.Policy == nil {
+		p, err := policy.Load(cfg.Auth.PolicyPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy: %w", err)
+		}
+		svc.Policy = p
+	}
+	svc = svc.withDefaults(cfg, db, jwtKey)
+
+	r := gin.New()
+
+	// Only trust X-Forwarded-For from known proxies, otherwise clients could pick their own rate limit key
+	if err := r.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Request id, tracing, metrics and the request log first so rejected requests are seen too,
+	// then panic recovery, problem details for handler errors, CORS and security headers
+	r.Use(
+		requestid.Middleware(),
+		tracing.Middleware(),
+		metrics.Middleware(),
+		logging.Middleware(),
+		middleware.RecoveryMiddleware(),
+		middleware.ErrorMiddleware(),
+		middleware.CORSMiddleware(cfg.HTTP.CORS),
+		middleware.SecurityHeadersMiddleware(middleware.DefaultSecurityHeaders(cfg.HTTP.HSTSMaxAge)),
+		middleware.BodyLimitMiddleware(cfg.Server.MaxBodyBytes),
+	)
+
+	// Swagger UI, with a relaxed content security policy for the UI assets
+	r.GET("/swagger/*any", middleware.SecurityHeadersMiddleware(middleware.SwaggerSecurityHeaders(cfg.HTTP.HSTSMaxAge)), ginSwagger.WrapHandler(swaggerFiles.Handler))
+	if cfg.Swagger.JSONPath != "" {
+		r.GET("/docs/swagger.json", func(c *gin.Context) {
+			c.File(cfg.Swagger.JSONPath)
+		})
+	}
+	if cfg.Swagger.YAMLPath != "" {
+		r.GET("/docs/swagger.yaml", func(c *gin.Context) {
+			c.File(cfg.Swagger.YAMLPath)
+		})
+	}
+
+	r.NoRoute(middleware.NotFoundHandler)
 
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "Welcome to the API!"})
 	})
 
-	// Public routes: login and register
-	loginService := services.NewLoginService(db)
-	loginHandler := handlers.NewLoginHandler(loginService)
-	r.POST("/api/login", loginHandler.Login)
-
-	registerService := services.NewRegisterService(db)
-	registerHandler := handlers.NewRegisterHandler(registerService)
-	r.POST("/api/register", registerHandler.Register)
-
-	// Protected routes with JWT middleware and permission-based access control
-	protected := r.Group("/api")
-	protected.Use(middleware.JwtAuthMiddleware()) // Apply JWT middleware to the entire group
-
-	// User routes with RBAC permissions
-	userService := services.NewUserService(db)
-	userHandler := handlers.NewUserHandler(userService)
-	protected.GET("/users", middleware.PermissionAuthMiddleware("read"), userHandler.GetAllUsers)
-	protected.POST("/users", middleware.PermissionAuthMiddleware("create"), userHandler.CreateUser)
-	protected.GET("/users/:id", middleware.PermissionAuthMiddleware("read"), userHandler.GetUserByID)
-	protected.GET("/users/email/:email", middleware.PermissionAuthMiddleware("read"), userHandler.GetUserByEmail)
-	protected.GET("/users/username/:username", middleware.PermissionAuthMiddleware("read"), userHandler.GetUserByUsername)
-
-	// New route to get users by role ID
-	protected.GET("/users/role/:role_id", middleware.PermissionAuthMiddleware("read"), userHandler.GetUsersByRoleID)
-
-	// Role routes with RBAC permissions
-	roleService := services.NewRoleService(db)
-	roleHandler := handlers.NewRoleHandler(roleService)
-	protected.GET("/roles", middleware.PermissionAuthMiddleware("read"), roleHandler.GetRoles)
-	protected.POST("/roles", middleware.PermissionAuthMiddleware("create"), roleHandler.CreateRole)
-	protected.GET("/roles/:id", middleware.PermissionAuthMiddleware("read"), roleHandler.GetRoleByID)
-	protected.GET("/roles/name/:name", middleware.PermissionAuthMiddleware("read"), roleHandler.GetRoleByName)
-
-	return r
+	// Prometheus metrics, only on the API when no separate address is configured
+	if cfg.Metrics.Addr == "" && cfg.Metrics.Token != "" {
+		r.GET("/metrics", gin.WrapH(metrics.Handler(cfg.Metrics.Token)))
+	}
+
+	store := svc.RateLimitStore
+	if store == nil {
+		store = middleware.NewMemoryRateLimitStore()
+	}
+	limits := map[string]middleware.RateLimit{
+		RateLimitAuth:     cfg.HTTP.RateLimits.Auth,
+		RateLimitRegister: cfg.HTTP.RateLimits.Register,
+		RateLimitAPI:      cfg.HTTP.RateLimits.API,
+	}
+	rateLimiters := make(map[string]gin.HandlerFunc)
+	rateLimiter := func(scope string, keyFunc middleware.RateLimitKeyFunc) gin.HandlerFunc {
+		limit, ok := limits[scope]
+		if !ok || limit.Requests == 0 {
+			return nil
+		}
+		if _, ok := rateLimiters[scope]; !ok {
+			rateLimiters[scope] = middleware.RateLimitMiddleware(scope, limit, keyFunc, store)
+		}
+		return rateLimiters[scope]
+	}
+
+	// Request bodies are validated in binding, before the services; role permissions must
+	// be ones the route table checks
+	if err := validation.Setup(); err != nil {
+		return nil, err
+	}
+	table := Table(svc)
+	if err := checkTable(table); err != nil {
+		return nil, fmt.Errorf("invalid route table: %w", err)
+	}
+	validation.RegisterPermissions(permissionNames(table)...)
+
+	// Tokens of disabled users, tokens issued before a revocation and tokens for an
+	// organization the user left are rejected
+	jwtAuth := middleware.JwtAuthMiddleware(cfg.JWTVerificationKeys(), func(ctx context.Context, claims *middleware.Claims) error {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		tenant := services.Tenant{OrganizationID: claims.OrganizationID, Username: claims.Username, SuperAdmin: claims.SuperAdmin}
+		return svc.User.CheckToken(ctx, claims.Username, issuedAt, tenant)
+	})
+	for _, route := range table {
+		var chain []gin.HandlerFunc
+		keyFunc := middleware.KeyByUsername
+		if route.Permission == PermissionPublic {
+			keyFunc = middleware.KeyByIP
+		} else {
+			chain = append(chain, jwtAuth)
+		}
+		if limiter := rateLimiter(route.RateLimit, keyFunc); limiter != nil {
+			chain = append(chain, limiter)
+		}
+		switch route.Permission {
+		case PermissionPublic, PermissionAuthenticated:
+		case PermissionSuperAdmin:
+			chain = append(chain, middleware.SuperAdminMiddleware())
+		default:
+			if action, ok := policyActions[route.Method+" "+route.Path]; ok {
+				chain = append(chain, middleware.PolicyMiddleware(svc.Policy, action, route.Permission, svc.Permission.Permissions, userResource(svc.User)))
+				break
+			}
+			chain = append(chain, middleware.PermissionAuthMiddleware(route.Permission, svc.Permission.Permissions))
+		}
+		r.Handle(route.Method, route.Path, append(chain, route.Handler)...)
+	}
+
+	return r, nil
+}
+
+// userResource loads the user of the :id parameter for the policy rules
+func userResource(users *services.UserService) middleware.ResourceLoader {
+	return func(c *gin.Context) (map[string]any, error) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return nil, services.Validation("invalid_id", "Invalid user ID")
+		}
+		return users.PolicyAttributes(c.Request.Context(), uint(id))
+	}
+}
+
+// withDefaults fills in the services that need nothing but the database and configuration
+func (svc Services) withDefaults(cfg *config.Config, db *gorm.DB, jwtKey []byte) Services {
+	if svc.Login == nil {
+		svc.Login = services.NewLoginService(db, jwtKey)
+	}
+	if svc.Register == nil {
+		svc.Register = services.NewRegisterService(db, cfg.Registration)
+	}
+	if svc.User == nil {
+		svc.User = services.NewUserService(db)
+	}
+	if svc.Role == nil {
+		svc.Role = services.NewRoleService(db)
+	}
+	if svc.Bootstrap == nil {
+		svc.Bootstrap = services.NewBootstrapService(db, cfg.Bootstrap, cfg.Registration)
+	}
+	if svc.Organization == nil {
+		svc.Organization = services.NewOrganizationService(db)
+	}
+	if svc.Group == nil {
+		svc.Group = services.NewGroupService(db)
+	}
+	if svc.Permission == nil {
+		svc.Permission = services.NewPermissionService(db)
+	}
+	if svc.Invitation == nil {
+		svc.Invitation = services.NewInvitationService(db, services.NewMailer(cfg.SMTP), svc.Register, cfg.Invitation)
+	}
+	if svc.Health == nil {
+		svc.Health = health.NewChecker(svc.Readiness, cfg.Health.CacheTTL,
+			health.DatabaseCheck(db),
+			health.MigrationCheck(db, models.All()...),
+			health.SigningKeyCheck(jwtKey),
+		)
+	}
+	return svc
 }
 
+// Table returns every API route with its authorization and rate limit requirements
+func Table(svc Services) []Route {
+	loginHandler := handlers.NewLoginHandler(svc.Login)
+	registerHandler := handlers.NewRegisterHandler(svc.Register)
+	setupHandler := handlers.NewSetupHandler(svc.Bootstrap)
+	userHandler := handlers.NewUserHandler(svc.User, svc.Permission)
+	roleHandler := handlers.NewRoleHandler(svc.Role)
+	invitationHandler := handlers.NewInvitationHandler(svc.Invitation)
+	organizationHandler := handlers.NewOrganizationHandler(svc.Organization, svc.Login)
+	groupHandler := handlers.NewGroupHandler(svc.Group)
+	policyHandler := handlers.NewPolicyHandler(svc.Policy, svc.User)
+	healthHandler := handlers.NewHealthHandler(svc.Health)
+
+	table := []Route{
+		// Probes, not rate limited
+		{http.MethodGet, "/healthz", PermissionPublic, "", healthHandler.Liveness},
+		{http.MethodGet, "/readyz", PermissionPublic, "", healthHandler.Readiness},
+		{http.MethodGet, "/api/admin/health", PermissionHealthRead, RateLimitAPI, healthHandler.Detailed},
+
+		// Authentication
+		{http.MethodPost, "/api/login", PermissionPublic, RateLimitAuth, loginHandler.Login},
+		{http.MethodPost, "/api/register", PermissionPublic, RateLimitRegister, registerHandler.Register},
+		{http.MethodPost, "/api/setup", PermissionPublic, RateLimitAuth, setupHandler.Setup},
+
+		// Users
+		{http.MethodGet, "/api/users", PermissionUsersRead, RateLimitAPI, userHandler.GetAllUsers},
+		{http.MethodPost, "/api/users", PermissionUsersCreate, RateLimitAPI, userHandler.CreateUser},
+		{http.MethodGet, "/api/users/:id", PermissionUsersRead, RateLimitAPI, userHandler.GetUserByID},
+		{http.MethodPut, "/api/users/:id", PermissionUsersUpdate, RateLimitAPI, userHandler.UpdateUser},
+		{http.MethodDelete, "/api/users/:id", PermissionUsersDelete, RateLimitAPI, userHandler.DeleteUser},
+		{http.MethodGet, "/api/users/email/:email", PermissionUsersRead, RateLimitAPI, userHandler.GetUserByEmail},
+		{http.MethodGet, "/api/users/username/:username", PermissionUsersRead, RateLimitAPI, userHandler.GetUserByUsername},
+		{http.MethodGet, "/api/users/role/:role_id", PermissionUsersRead, RateLimitAPI, userHandler.GetUsersByRoleID},
+		{http.MethodGet, "/api/users/:id/effective-permissions", PermissionUsersRead, RateLimitAPI, userHandler.GetEffectivePermissions},
+		{http.MethodPost, "/api/users/password", PermissionAuthenticated, RateLimitAPI, userHandler.ChangePassword},
+
+		// Roles
+		{http.MethodGet, "/api/roles", PermissionRolesRead, RateLimitAPI, roleHandler.GetRoles},
+		{http.MethodPost, "/api/roles", PermissionRolesCreate, RateLimitAPI, roleHandler.CreateRole},
+		{http.MethodGet, "/api/roles/:id", PermissionRolesRead, RateLimitAPI, roleHandler.GetRoleByID},
+		{http.MethodPut, "/api/roles/:id", PermissionRolesUpdate, RateLimitAPI, roleHandler.UpdateRole},
+		{http.MethodGet, "/api/roles/name/:name", PermissionRolesRead, RateLimitAPI, roleHandler.GetRoleByName},
+
+		// Invitations
+		{http.MethodGet, "/api/invitations", PermissionInvitationsRead, RateLimitAPI, invitationHandler.GetInvitations},
+		{http.MethodPost, "/api/invitations", PermissionInvitationsCreate, RateLimitAPI, invitationHandler.CreateInvitation},
+		{http.MethodGet, "/api/invitations/:id", PermissionInvitationsRead, RateLimitAPI, invitationHandler.GetInvitationByID},
+		{http.MethodPost, "/api/invitations/:id/resend", PermissionInvitationsCreate, RateLimitAPI, invitationHandler.ResendInvitation},
+		{http.MethodDelete, "/api/invitations/:id", PermissionInvitationsDelete, RateLimitAPI, invitationHandler.RevokeInvitation},
+		{http.MethodPost, "/api/invitations/accept", PermissionPublic, RateLimitRegister, invitationHandler.AcceptInvitation},
+
+		// Groups
+		{http.MethodGet, "/api/groups", PermissionGroupsRead, RateLimitAPI, groupHandler.GetGroups},
+		{http.MethodPost, "/api/groups", PermissionGroupsCreate, RateLimitAPI, groupHandler.CreateGroup},
+		{http.MethodGet, "/api/groups/:id", PermissionGroupsRead, RateLimitAPI, groupHandler.GetGroupByID},
+		{http.MethodPut, "/api/groups/:id", PermissionGroupsUpdate, RateLimitAPI, groupHandler.UpdateGroup},
+		{http.MethodDelete, "/api/groups/:id", PermissionGroupsDelete, RateLimitAPI, groupHandler.DeleteGroup},
+		{http.MethodGet, "/api/groups/:id/members", PermissionGroupsRead, RateLimitAPI, groupHandler.GetGroupMembers},
+		{http.MethodPut, "/api/groups/:id/members/:user_id", PermissionGroupsUpdate, RateLimitAPI, groupHandler.AddGroupMember},
+		{http.MethodDelete, "/api/groups/:id/members/:user_id", PermissionGroupsUpdate, RateLimitAPI, groupHandler.RemoveGroupMember},
+		{http.MethodPut, "/api/groups/:id/roles/:role_id", PermissionGroupsUpdate, RateLimitAPI, groupHandler.AddGroupRole},
+		{http.MethodDelete, "/api/groups/:id/roles/:role_id", PermissionGroupsUpdate, RateLimitAPI, groupHandler.RemoveGroupRole},
+
+		// Organizations
+		{http.MethodGet, "/api/organizations", PermissionAuthenticated, RateLimitAPI, organizationHandler.GetOrganizations},
+		{http.MethodPost, "/api/organizations", PermissionSuperAdmin, RateLimitAPI, organizationHandler.CreateOrganization},
+		{http.MethodPost, "/api/organizations/:id/switch", PermissionAuthenticated, RateLimitAPI, organizationHandler.SwitchOrganization},
+		{http.MethodPut, "/api/organizations/:id/members", PermissionSuperAdmin, RateLimitAPI, organizationHandler.SetMember},
+		{http.MethodDelete, "/api/organizations/:id/members/:user_id", PermissionSuperAdmin, RateLimitAPI, organizationHandler.RemoveMember},
+
+		// Access policy
+		{http.MethodPost, "/api/policies/evaluate", PermissionSuperAdmin, RateLimitAPI, policyHandler.EvaluatePolicy},
+	}
+
+	if svc.MagicLink != nil {
+		magicLinkHandler := handlers.NewMagicLinkHandler(svc.MagicLink, svc.Role)
+		table = append(table,
+			Route{http.MethodPost, "/api/login/magic-link", PermissionPublic, RateLimitAuth, magicLinkHandler.RequestLink},
+			Route{http.MethodPost, "/api/login/magic-link/verify", PermissionPublic, RateLimitAuth, magicLinkHandler.ConsumeLink},
+			Route{http.MethodPut, "/api/roles/:id/magic-link", PermissionRolesUpdate, RateLimitAPI, magicLinkHandler.SetRoleMagicLink},
+		)
+	}
+
+	if svc.WebAuthn != nil {
+		webAuthnHandler := handlers.NewWebAuthnHandler(svc.WebAuthn)
+		table = append(table,
+			// Passkey login, either as the only factor or after a password login returned a ticket
+			Route{http.MethodPost, "/api/login/webauthn/begin", PermissionPublic, RateLimitAuth, webAuthnHandler.BeginLogin},
+			Route{http.MethodPost, "/api/login/webauthn/finish", PermissionPublic, RateLimitAuth, webAuthnHandler.FinishLogin},
+			Route{http.MethodPost, "/api/login/webauthn/second-factor/begin", PermissionPublic, RateLimitAuth, webAuthnHandler.BeginSecondFactor},
+			Route{http.MethodPost, "/api/login/webauthn/second-factor/finish", PermissionPublic, RateLimitAuth, webAuthnHandler.FinishSecondFactor},
+
+			// Passkey management, always scoped to the authenticated user
+			Route{http.MethodPost, "/api/webauthn/register/begin", PermissionAuthenticated, RateLimitAPI, webAuthnHandler.BeginRegistration},
+			Route{http.MethodPost, "/api/webauthn/register/finish", PermissionAuthenticated, RateLimitAPI, webAuthnHandler.FinishRegistration},
+			Route{http.MethodGet, "/api/webauthn/credentials", PermissionAuthenticated, RateLimitAPI, webAuthnHandler.ListCredentials},
+			Route{http.MethodPut, "/api/webauthn/credentials/:id", PermissionAuthenticated, RateLimitAPI, webAuthnHandler.RenameCredential},
+			Route{http.MethodDelete, "/api/webauthn/credentials/:id", PermissionAuthenticated, RateLimitAPI, webAuthnHandler.DeleteCredential},
+			Route{http.MethodPut, "/api/webauthn/mfa", PermissionAuthenticated, RateLimitAPI, webAuthnHandler.SetSecondFactor},
+		)
+	}
+
+	// Permission discovery, listing the permissions of the routes above
+	table = append(table, Route{http.MethodGet, "/api/permissions", PermissionAuthenticated, RateLimitAPI, handlers.NewPermissionHandler(registry(table)).GetPermissions})
+
+	return table
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/config"
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/middleware"
+)
+
+func newTestRouter(t *testing.T, cfg *config.Config) http.Handler {
+	t.Helper()
+	return newTestApp(t, cfg, dbtest.New(t))
+}
+
+func newTestApp(t *testing.T, cfg *config.Config, db *gorm.DB) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router, err := New(cfg, db, Services{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestNewRequiresJWTKey(t *testing.T) {
+	if _, err := New(config.Default(), dbtest.New(t), Services{}); err == nil {
+		t.Error("New without a JWT key succeeded")
+	}
+}
+
+// The builder works from config and database alone: a token from its login route is
+// checked against the permissions of the single route table
+func TestApplication(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWTKey = "test-key"
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	alice := dbtest.CreateUser(t, db, "alice", guest.ID)
+	dbtest.AddMember(t, db, dbtest.CreateOrganization(t, db, "acme"), alice, guest.ID)
+	app := newTestApp(t, cfg, db)
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"alice","password":"password"}`)))
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &login); w.Code != http.StatusOK || err != nil || login.Token == "" {
+		t.Fatalf("login = %d %s, want a token", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		method, path string
+		token        bool
+		status       int
+		code         string
+	}{
+		{method: http.MethodGet, path: "/healthz", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/users", token: true, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/users", status: http.StatusUnauthorized, code: "missing_token"},
+		{method: http.MethodPost, path: "/api/roles", token: true, status: http.StatusForbidden, code: "insufficient_permissions"},
+		{method: http.MethodGet, path: "/api/unknown", token: true, status: http.StatusNotFound, code: "route_not_found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name":"editor"}`))
+		if tt.token {
+			req.Header.Set("Authorization", "Bearer "+login.Token)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.status)
+			continue
+		}
+		var problem struct {
+			Code string `json:"code"`
+		}
+		if tt.code != "" && (json.Unmarshal(w.Body.Bytes(), &problem) != nil || problem.Code != tt.code) {
+			t.Errorf("%s %s = %s, want code %s", tt.method, tt.path, w.Body, tt.code)
+		}
+	}
+}
+
+// The Swagger UI gets its relaxed content security policy; every other response,
+// including unknown routes, keeps the strict one of the API
+func TestSwaggerSecurityHeaders(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWTKey = "test-key"
+	cfg.HTTP.HSTSMaxAge = time.Hour
+	router := newTestRouter(t, cfg)
+	swagger := middleware.SwaggerSecurityHeaders(cfg.HTTP.HSTSMaxAge)
+	strict := middleware.DefaultSecurityHeaders(cfg.HTTP.HSTSMaxAge)
+
+	tests := []struct {
+		path     string
+		policy   middleware.SecurityHeadersPolicy
+		referrer string
+	}{
+		{path: "/swagger/index.html", policy: swagger},
+		{path: "/swagger/doc.json", policy: swagger},
+		{path: "/", policy: strict},
+		{path: "/api/users", policy: strict},
+		{path: "/swagger-ui", policy: strict},
+		{path: "/unknown", policy: strict},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := w.Header().Get("Content-Security-Policy"); got != tt.policy.ContentSecurityPolicy {
+			t.Errorf("%s: Content-Security-Policy = %q, want %q", tt.path, got, tt.policy.ContentSecurityPolicy)
+		}
+		if got := w.Header().Get("Referrer-Policy"); got != tt.policy.ReferrerPolicy {
+			t.Errorf("%s: Referrer-Policy = %q, want %q", tt.path, got, tt.policy.ReferrerPolicy)
+		}
+		// The override only relaxes what the UI needs
+		if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+			t.Errorf("%s: Strict-Transport-Security = %q", tt.path, got)
+		}
+		if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s: frame or sniffing protection missing", tt.path)
+		}
+	}
+
+	csp := swagger.ContentSecurityPolicy
+	if !strings.Contains(csp, "frame-ancestors 'none'") || strings.Contains(csp, "unsafe-eval") || strings.Contains(csp, "*") {
+		t.Errorf("Swagger policy %q is looser than the UI needs", csp)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go_api/src/internal/config"
+	"go_api/src/internal/health"
+)
+
+// Server runs the API over an http.Server with explicit timeouts and a graceful shutdown.
+// Extra listeners, such as a private metrics address, share its lifecycle.
+type Server struct {
+	servers   []*http.Server // the API server first
+	cfg       config.ServerConfig
+	readiness *health.Readiness
+}
+
+// New creates a Server for handler. readiness is flipped when the server starts and stops.
+func New(cfg config.ServerConfig, handler http.Handler, readiness *health.Readiness) *Server {
+	s := &Server{cfg: cfg, readiness: readiness}
+	s.AddListener(":"+cfg.Port, handler)
+	return s
+}
+
+// AddListener serves handler on another address with the same limits and shutdown
+func (s *Server) AddListener(addr string, handler http.Handler) {
+	s.servers = append(s.servers, &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+		MaxHeaderBytes:    s.cfg.MaxHeaderBytes,
+	})
+}
+
+// Run serves until ctx is cancelled, usually by SIGINT or SIGTERM, and then shuts down:
+//
+//  1. readiness reports failure, and the server keeps serving for ShutdownDelay so
+//     load balancers can take the instance out of rotation
+//  2. the listeners close and in-flight requests are drained within ShutdownTimeout
+//  3. the cleanup functions run in order, e.g. closing the database pool
+//
+// Run returns the errors from serving, draining and cleanup.
+func (s *Server) Run(ctx context.Context, cleanup ...func() error) error {
+	listeners := make([]net.Listener, 0, len(s.servers))
+	for _, srv := range s.servers {
+		listener, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	serveErr := make(chan error, len(s.servers))
+	for i, srv := range s.servers {
+		go func() {
+			serveErr <- srv.Serve(listeners[i])
+		}()
+		slog.Info("Server listening", "addr", listeners[i].Addr().String())
+	}
+	s.readiness.SetReady(true)
+
+	var errs []error
+	select {
+	case err := <-serveErr:
+		// A server stopped on its own; drain the others
+		errs = append(errs, err, s.shutdown(0))
+	case <-ctx.Done():
+		errs = append(errs, s.shutdown(s.cfg.ShutdownDelay))
+	}
+
+	for _, fn := range cleanup {
+		errs = append(errs, fn())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) shutdown(delay time.Duration) error {
+	s.readiness.SetReady(false)
+	slog.Info("Shutdown: readiness failing, closing listeners after delay", "delay", delay.String())
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make([]error, len(s.servers))
+	var wg sync.WaitGroup
+	for i, srv := range s.servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				// Deadline exceeded: cut the remaining connections
+				srv.Close()
+				errs[i] = fmt.Errorf("shutdown %s: in-flight requests not drained within %s: %w", srv.Addr, s.cfg.ShutdownTimeout, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("Shutdown: in-flight requests drained", "duration", time.Since(start).Round(time.Millisecond).String())
+	return nil
+}
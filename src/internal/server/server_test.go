@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"go_api/src/internal/config"
+	"go_api/src/internal/health"
+)
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// slowHandler answers once release is closed, after telling started
+func slowHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		io.WriteString(w, "done")
+	})
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+type response struct {
+	body string
+	err  error
+}
+
+func get(url string) <-chan response {
+	done := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			done <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		done <- response{body: string(body), err: err}
+	}()
+	return done
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	cfg := config.ServerConfig{Port: freePort(t), ShutdownDelay: 200 * time.Millisecond, ShutdownTimeout: 2 * time.Second}
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	readiness := health.NewReadiness()
+	srv := New(cfg, slowHandler(started, release), readiness)
+
+	ctx, stop := context.WithCancel(context.Background())
+	cleanedUp := false
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- srv.Run(ctx, func() error {
+			cleanedUp = true
+			return nil
+		})
+	}()
+	waitFor(t, "readiness", readiness.Ready)
+
+	url := "http://127.0.0.1:" + cfg.Port
+	inFlight := get(url)
+	<-started
+	stop()
+
+	// Readiness fails at once, while the listener still accepts during the delay
+	waitFor(t, "readiness to fail", func() bool { return !readiness.Ready() })
+	late := get(url)
+	<-started
+
+	close(release)
+	for name, done := range map[string]<-chan response{"in-flight": inFlight, "during the delay": late} {
+		if r := <-done; r.err != nil || r.body != "done" {
+			t.Errorf("request %s = %q, %v, want it served", name, r.body, r.err)
+		}
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	if !cleanedUp {
+		t.Error("cleanup did not run")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("the listener still accepts connections after Run returned")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	cfg := config.ServerConfig{Port: freePort(t), ShutdownTimeout: 100 * time.Millisecond}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	readiness := health.NewReadiness()
+	srv := New(cfg, slowHandler(started, release), readiness)
+
+	ctx, stop := context.WithCancel(context.Background())
+	cleanupErr := errors.New("closing the database")
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- srv.Run(ctx, func() error { return cleanupErr })
+	}()
+
+	waitFor(t, "readiness", readiness.Ready)
+
+	stuck := get("http://127.0.0.1:" + cfg.Port)
+	<-started
+	stop()
+
+	// The request that outlives the deadline is cut and reported; cleanup still runs
+	err := <-runErr
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, cleanupErr) {
+		t.Errorf("Run = %v, want the drain deadline and the cleanup error", err)
+	}
+	if r := <-stuck; r.err == nil {
+		t.Errorf("request outliving the shutdown timeout = %q, want it cut", r.body)
+	}
+}
+
+// Every listener gets the configured limits
+func TestListenerLimits(t *testing.T) {
+	cfg := config.ServerConfig{Port: "8080", ReadTimeout: time.Second, ReadHeaderTimeout: 2 * time.Second, WriteTimeout: 3 * time.Second, IdleTimeout: 4 * time.Second, MaxHeaderBytes: 1 << 10}
+	srv := New(cfg, http.NotFoundHandler(), nil)
+	srv.AddListener("127.0.0.1:9090", http.NotFoundHandler())
+	for _, s := range srv.servers {
+		if s.ReadTimeout != cfg.ReadTimeout || s.ReadHeaderTimeout != cfg.ReadHeaderTimeout || s.WriteTimeout != cfg.WriteTimeout ||
+			s.IdleTimeout != cfg.IdleTimeout || s.MaxHeaderBytes != cfg.MaxHeaderBytes {
+			t.Errorf("%s: limits = %+v, want those of %+v", s.Addr, s, cfg)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+)
+
+// ErrInvalidCredentials is returned when a backend rejects the supplied credentials
+var ErrInvalidCredentials = Unauthenticated("invalid_credentials", "Invalid credentials")
+
+// ErrUnknownUser is returned when a backend has no record of the user, so the next backend may be tried
+var ErrUnknownUser = errors.New("unknown user")
+
+// ErrBackendUnavailable is returned when a backend can't be reached, so the next backend
+// may be tried; wrapped by the backend with the cause
+var ErrBackendUnavailable = Unavailable("authentication_unavailable", "Authentication is temporarily unavailable")
+
+// dummyPasswordHash is compared with the password of unknown users, so a login takes as
+// long whether or not the username exists
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// Authenticator verifies a username and password against a single identity backend
+// and returns the matching local user with its Role preloaded.
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// LocalAuthenticator checks credentials against the bcrypt hash stored in the users table
+type LocalAuthenticator struct {
+	db *gorm.DB
+}
+
+// NewLocalAuthenticator creates a new LocalAuthenticator
+func NewLocalAuthenticator(db *gorm.DB) *LocalAuthenticator {
+	return &LocalAuthenticator{db: db}
+}
+
+// Name returns the backend name used in AUTH_BACKENDS
+func (a *LocalAuthenticator) Name() string {
+	return models.AuthSourceLocal
+}
+
+// Authenticate compares the password with the stored hash of a local user
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	var user models.User
+
+	// Fetch the user from the database by username, preloading the Role
+	if err := a.db.WithContext(ctx).Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.DebugContext(ctx, "LocalAuthenticator: user not found", "username", username)
+			bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+			return nil, ErrUnknownUser
+		}
+		slog.ErrorContext(ctx, "LocalAuthenticator: error fetching user", "username", username, "err", err)
+		return nil, ErrBackendUnavailable.Wrap(err)
+	}
+
+	// Users provisioned by an external directory never have a usable local password
+	if user.AuthSource != "" && user.AuthSource != models.AuthSourceLocal {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, ErrUnknownUser
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		slog.InfoContext(ctx, "LocalAuthenticator: password mismatch", "username", username)
+		return nil, ErrInvalidCredentials
+	}
+
+	return &user, nil
+}
+
+// NewAuthenticators builds the authenticator chain in the order of backends
+// (for example "ldap", "local"). It defaults to the local database only.
+func NewAuthenticators(db *gorm.DB, backends []string, ldapConfig LDAPConfig) ([]Authenticator, error) {
+	if len(backends) == 0 {
+		backends = []string{models.AuthSourceLocal}
+	}
+
+	var authenticators []Authenticator
+	for _, name := range backends {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case models.AuthSourceLocal:
+			authenticators = append(authenticators, NewLocalAuthenticator(db))
+		case models.AuthSourceLDAP:
+			authenticators = append(authenticators, NewLDAPAuthenticator(db, ldapConfig, nil))
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown authentication backend %q", name)
+		}
+	}
+	return authenticators, nil
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
+)
+
+// ErrInvalidSetupToken is returned for unknown or expired setup tokens
+var ErrInvalidSetupToken = Unauthenticated("invalid_setup_token", "Invalid or expired setup token")
+
+// ErrSetupComplete is returned by CompleteSetup once an administrator exists
+var ErrSetupComplete = Conflict("setup_complete", "An administrator already exists")
+
+// bootstrapLockKey is the Postgres advisory lock held while bootstrapping, so instances
+// starting together don't create the same roles or administrator twice
+const bootstrapLockKey int64 = 0x626f6f747374 // "bootst"
+
+// RoleSeed is a role the bootstrap makes sure exists with at least Permissions, inheriting
+// from at least Parents
+type RoleSeed struct {
+	Name        string
+	Parents     []string
+	Permissions []string
+}
+
+// BootstrapConfig configures BootstrapService
+type BootstrapConfig struct {
+	// Roles are created when missing and given any missing permissions and parents;
+	// permissions and parents an administrator added are kept
+	Roles []RoleSeed
+	// AdminRole is the role of the first administrator, a super-admin who is also a
+	// member of the registration organization
+	AdminRole string
+	// The first administrator is created from these when set and no administrator exists;
+	// otherwise a setup token is logged
+	AdminUsername string
+	AdminEmail    string
+	AdminPassword string
+	SetupTokenTTL time.Duration
+}
+
+// ParseRoleSeeds parses the BOOTSTRAP_ROLES format, ';' separated name:permission,permission
+// entries where the name may list parent roles in parentheses, e.g.
+// "guest:users.read;admin(guest):users.create,users.update"
+func ParseRoleSeeds(value string) ([]RoleSeed, error) {
+	var seeds []RoleSeed
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, permissions, _ := strings.Cut(entry, ":")
+		name, parents, inherits := strings.Cut(name, "(")
+		seed := RoleSeed{Name: strings.TrimSpace(name)}
+		if seed.Name == "" {
+			return nil, fmt.Errorf("role without a name in %q", entry)
+		}
+		if inherits {
+			parents, closed := strings.CutSuffix(strings.TrimSpace(parents), ")")
+			if !closed {
+				return nil, fmt.Errorf("unclosed parent list in %q", entry)
+			}
+			for _, parent := range strings.Split(parents, ",") {
+				if parent = strings.TrimSpace(parent); parent != "" {
+					seed.Parents = append(seed.Parents, parent)
+				}
+			}
+		}
+		for _, permission := range strings.Split(permissions, ",") {
+			if permission = strings.TrimSpace(permission); permission != "" {
+				seed.Permissions = append(seed.Permissions, permission)
+			}
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
+
+// BootstrapResult reports what Run changed
+type BootstrapResult struct {
+	Roles        []models.Role
+	CreatedRoles []string
+	// Organization is the registration organization, if one is configured
+	Organization        *models.Organization
+	CreatedOrganization bool
+	// Admin is the administrator created from the configuration, if any
+	Admin *models.User
+	// SetupToken is set when no administrator exists; it is only valid until SetupTokenExpiresAt
+	SetupToken          string
+	SetupTokenExpiresAt time.Time
+}
+
+// BootstrapService prepares a fresh database: default roles, the registration organization
+// and the first administrator
+type BootstrapService struct {
+	db           *gorm.DB
+	cfg          BootstrapConfig
+	registration RegistrationConfig
+}
+
+// NewBootstrapService creates a new BootstrapService. The default role of registration,
+// the role of registered users, is created without permissions when it isn't one of
+// cfg.Roles; its organization is created when missing.
+func NewBootstrapService(db *gorm.DB, cfg BootstrapConfig, registration RegistrationConfig) *BootstrapService {
+	return &BootstrapService{db: db, cfg: cfg, registration: registration}
+}
+
+// Run ensures the configured roles exist and that there is an administrator, creating it
+// from the configuration or issuing a setup token. It is idempotent and safe to run on every start.
+func (s *BootstrapService) Run(ctx context.Context) (*BootstrapResult, error) {
+	ctx, span := tracing.Start(ctx, "BootstrapService.Run")
+	defer span.End()
+
+	if s.cfg.AdminRole == "" {
+		return nil, errors.New("bootstrap: no administrator role configured")
+	}
+	result := &BootstrapResult{}
+	err := s.locked(ctx, func(tx *gorm.DB) error {
+		roleService := NewRoleService(tx)
+		var adminRole *models.Role
+		seeds := s.seeds()
+		for _, seed := range seeds {
+			role, created, err := roleService.EnsureRole(ctx, seed.Name, seed.Permissions...)
+			if err != nil {
+				return fmt.Errorf("ensuring role %s: %w", seed.Name, err)
+			}
+			if created {
+				result.CreatedRoles = append(result.CreatedRoles, role.Name)
+			}
+			result.Roles = append(result.Roles, *role)
+		}
+		// Parents are added once every seeded role exists, whatever the order of the seeds
+		for i, seed := range seeds {
+			if len(seed.Parents) > 0 {
+				role, err := roleService.AddParents(ctx, seed.Name, seed.Parents...)
+				if err != nil {
+					return fmt.Errorf("ensuring parents of role %s: %w", seed.Name, err)
+				}
+				result.Roles[i] = *role
+			}
+			if seed.Name == s.cfg.AdminRole {
+				adminRole = &result.Roles[i]
+			}
+		}
+		if s.registration.Organization != "" {
+			organization, created, err := NewOrganizationService(tx).EnsureOrganization(ctx, s.registration.Organization)
+			if err != nil {
+				return fmt.Errorf("ensuring organization %s: %w", s.registration.Organization, err)
+			}
+			result.Organization, result.CreatedOrganization = organization, created
+		}
+
+		exists, err := adminExists(tx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			// Tokens of earlier starts are useless once there is an administrator
+			return tx.Where("1 = 1").Delete(&models.SetupToken{}).Error
+		}
+
+		if s.cfg.AdminUsername != "" {
+			admin := &models.User{
+				Username:   s.cfg.AdminUsername,
+				Email:      s.cfg.AdminEmail,
+				Password:   s.cfg.AdminPassword, // hashed by the BeforeCreate hook
+				RoleID:     adminRole.ID,
+				AuthSource: models.AuthSourceLocal,
+				SuperAdmin: true,
+			}
+			if err := s.createAdmin(tx, admin, adminRole); err != nil {
+				return fmt.Errorf("creating administrator %s: %w", admin.Username, err)
+			}
+			result.Admin = admin
+			return nil
+		}
+
+		token, err := newSetupToken()
+		if err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(s.cfg.SetupTokenTTL)
+		if err := tx.Create(&models.SetupToken{TokenHash: hashToken(token), ExpiresAt: expiresAt}).Error; err != nil {
+			return err
+		}
+		result.SetupToken, result.SetupTokenExpiresAt = token, expiresAt
+		return nil
+	})
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return result, nil
+}
+
+// CompleteSetup creates the first administrator with a setup token issued by Run. It fails
+// with ErrSetupComplete once any super-admin exists, so a leaked token is useless afterwards.
+func (s *BootstrapService) CompleteSetup(ctx context.Context, req *models.SetupRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "BootstrapService.CompleteSetup")
+	defer span.End()
+
+	var admin *models.User
+	err := s.locked(ctx, func(tx *gorm.DB) error {
+		var token models.SetupToken
+		if err := tx.Where("token_hash = ? AND expires_at > ?", hashToken(req.Token), time.Now()).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidSetupToken
+			}
+			return err
+		}
+
+		adminRole, err := NewRoleService(tx).GetRoleByName(ctx, s.cfg.AdminRole)
+		if err != nil {
+			return err
+		}
+		exists, err := adminExists(tx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrSetupComplete
+		}
+
+		admin = &models.User{
+			Username:   req.Username,
+			Email:      req.Email,
+			Password:   req.Password, // hashed by the BeforeCreate hook
+			First:      req.First,
+			Last:       req.Last,
+			RoleID:     adminRole.ID,
+			AuthSource: models.AuthSourceLocal,
+			SuperAdmin: true,
+		}
+		if err := s.createAdmin(tx, admin, adminRole); err != nil {
+			return err
+		}
+
+		// Every instance may have logged a token; none is needed any more
+		return tx.Where("1 = 1").Delete(&models.SetupToken{}).Error
+	})
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	slog.InfoContext(ctx, "CompleteSetup: created the first administrator", "username", admin.Username)
+	return admin, nil
+}
+
+// seeds returns the configured roles plus the administrator and default roles when they aren't listed
+func (s *BootstrapService) seeds() []RoleSeed {
+	seeds := append([]RoleSeed(nil), s.cfg.Roles...)
+	for _, name := range []string{s.cfg.AdminRole, s.registration.DefaultRole} {
+		listed := false
+		for _, seed := range seeds {
+			listed = listed || seed.Name == name
+		}
+		if !listed && name != "" {
+			seeds = append(seeds, RoleSeed{Name: name})
+		}
+	}
+	return seeds
+}
+
+// locked runs fn in a transaction holding the bootstrap lock
+func (s *BootstrapService) locked(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", bootstrapLockKey).Error; err != nil {
+			return fmt.Errorf("acquiring bootstrap lock: %w", err)
+		}
+		return fn(tx)
+	})
+}
+
+// createAdmin creates a super-admin, a member of the registration organization with adminRole
+func (s *BootstrapService) createAdmin(tx *gorm.DB, admin *models.User, adminRole *models.Role) error {
+	if err := tx.Create(admin).Error; err != nil {
+		return translateDBError(err, ErrUserExists)
+	}
+	admin.Role = *adminRole
+	if s.registration.Organization == "" {
+		return nil
+	}
+
+	var organization models.Organization
+	if err := tx.Where("name = ?", s.registration.Organization).First(&organization).Error; err != nil {
+		return fmt.Errorf("finding organization %s: %w", s.registration.Organization, err)
+	}
+	return tx.Create(&models.Membership{UserID: admin.ID, OrganizationID: organization.ID, RoleID: adminRole.ID}).Error
+}
+
+// adminExists reports whether there is a super-admin
+func adminExists(tx *gorm.DB) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("super_admin").Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func newSetupToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
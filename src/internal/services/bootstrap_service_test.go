@@ -0,0 +1,61 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoleSeeds(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []RoleSeed
+		wantErr bool
+	}{
+		{name: "empty", value: " ; "},
+		{
+			name:  "roles with parents",
+			value: "guest:users.read, groups.read; admin(guest):roles.read,roles.create;",
+			want: []RoleSeed{
+				{Name: "guest", Permissions: []string{"users.read", "groups.read"}},
+				{Name: "admin", Parents: []string{"guest"}, Permissions: []string{"roles.read", "roles.create"}},
+			},
+		},
+		{name: "without permissions", value: "auditor( guest , viewer )", want: []RoleSeed{{Name: "auditor", Parents: []string{"guest", "viewer"}}}},
+		{name: "without a name", value: ":users.read", wantErr: true},
+		{name: "unclosed parents", value: "admin(guest:users.read", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoleSeeds(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRoleSeeds(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoleSeeds(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// The administrator and default roles are seeded even when the configuration leaves them out
+func TestSeeds(t *testing.T) {
+	guest := RoleSeed{Name: "guest", Permissions: []string{"users.read"}}
+	tests := []struct {
+		name  string
+		roles []RoleSeed
+		want  []RoleSeed
+	}{
+		{name: "listed", roles: []RoleSeed{guest, {Name: "admin", Parents: []string{"guest"}}}, want: []RoleSeed{guest, {Name: "admin", Parents: []string{"guest"}}}},
+		{name: "missing", roles: []RoleSeed{{Name: "auditor"}}, want: []RoleSeed{{Name: "auditor"}, {Name: "admin"}, {Name: "guest"}}},
+		{name: "none", want: []RoleSeed{{Name: "admin"}, {Name: "guest"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewBootstrapService(nil, BootstrapConfig{Roles: tt.roles, AdminRole: "admin"}, RegistrationConfig{DefaultRole: "guest"})
+			if got := service.seeds(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("seeds() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+)
+
+// Error kinds. Every domain error wraps exactly one of them, and the HTTP layer
+// chooses the status from the kind alone.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrValidation      = errors.New("validation failed")
+	ErrForbidden       = errors.New("forbidden")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrUnavailable     = errors.New("unavailable")
+)
+
+// Error is a domain error. Code is a stable machine-readable identifier and Message
+// is safe to show to clients; the wrapped cause, if any, is only logged.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Err     error
+	// Fields lists the failed rules of a validation error, one entry per field and rule
+	Fields []models.FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap lets errors.Is match both the kind and the cause
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// Wrap returns a copy of e with err as its cause, so errors.Is still matches e's kind
+// and callers can log the cause
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// Is matches another *Error with the same code, so a wrapped copy still equals its sentinel
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// NotFound returns an ErrNotFound domain error
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+// Conflict returns an ErrConflict domain error
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+// Validation returns an ErrValidation domain error
+func Validation(code, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message}
+}
+
+// InvalidFields returns an ErrValidation domain error reporting the failed rules of a request
+func InvalidFields(fields []models.FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: "validation_failed", Message: "Request validation failed", Fields: fields}
+}
+
+// Forbidden returns an ErrForbidden domain error
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+// Unauthenticated returns an ErrUnauthenticated domain error
+func Unauthenticated(code, message string) *Error {
+	return &Error{Kind: ErrUnauthenticated, Code: code, Message: message}
+}
+
+// RateLimited returns an ErrRateLimited domain error
+func RateLimited(code, message string) *Error {
+	return &Error{Kind: ErrRateLimited, Code: code, Message: message}
+}
+
+// Unavailable returns an ErrUnavailable domain error
+func Unavailable(code, message string) *Error {
+	return &Error{Kind: ErrUnavailable, Code: code, Message: message}
+}
+
+// Domain errors of the user and role services. Their codes are part of the API;
+// the authentication services define their own next to the code returning them.
+var (
+	ErrUserNotFound      = NotFound("user_not_found", "User not found")
+	ErrRoleNotFound      = NotFound("role_not_found", "Role not found")
+	ErrUserExists        = Conflict("user_exists", "Username or email already exists")
+	ErrRoleExists        = Conflict("role_exists", "Role already exists")
+	ErrIncorrectPassword = Validation("incorrect_password", "Incorrect old password")
+	ErrSuperAdminUser    = Forbidden("super_admin_user", "Super-admin accounts can only be changed by a super-admin")
+	ErrSharedAccount     = Forbidden("shared_account", "The user belongs to other organizations; only their role here can be changed")
+)
+
+// unknownRole is the validation error of a request field naming a role that doesn't exist
+// or isn't visible in the organization of the request
+func unknownRole(field string) *Error {
+	return InvalidFields([]models.FieldError{{Field: field, Rule: "known_role", Message: "role does not exist"}})
+}
+
+// translateDBError maps a unique constraint violation to conflict and returns any
+// other error unchanged. It relies on gorm's TranslateError option.
+func translateDBError(err error, conflict *Error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return conflict.Wrap(err)
+	}
+	return err
+}
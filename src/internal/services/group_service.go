@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
+)
+
+// Group errors
+var (
+	ErrGroupNotFound       = NotFound("group_not_found", "Group not found")
+	ErrGroupExists         = Conflict("group_exists", "Group already exists")
+	ErrGroupMemberNotFound = NotFound("group_member_not_found", "User is not a member of this group")
+	ErrGroupRoleNotFound   = NotFound("group_role_not_found", "Role is not attached to this group")
+)
+
+// GroupService manages groups, their members and their roles. Groups belong to the
+// organization of the context they are created in and are only visible there.
+type GroupService struct {
+	db *gorm.DB
+}
+
+// NewGroupService creates a new GroupService
+func NewGroupService(db *gorm.DB) *GroupService {
+	return &GroupService{db: db}
+}
+
+// ListGroups returns the groups with their roles, ordered by name
+func (s *GroupService) ListGroups(ctx context.Context) ([]models.Group, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.ListGroups")
+	defer span.End()
+
+	var groups []models.Group
+	if err := s.db.WithContext(ctx).Scopes(scopeGroups(ctx)).Preload("Roles").Order("name").Find(&groups).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return groups, nil
+}
+
+// GetGroup retrieves a group with its roles by its ID
+func (s *GroupService) GetGroup(ctx context.Context, id uint) (*models.Group, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.GetGroup", attribute.Int64("group.id", int64(id)))
+	defer span.End()
+
+	var group models.Group
+	if err := s.db.WithContext(ctx).Scopes(scopeGroups(ctx)).Preload("Roles").First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, tracing.RecordError(span, err)
+	}
+	return &group, nil
+}
+
+// CreateGroup creates a group without members or roles, owned by the organization of ctx if any
+func (s *GroupService) CreateGroup(ctx context.Context, group *models.Group) error {
+	ctx, span := tracing.Start(ctx, "GroupService.CreateGroup", attribute.String("group.name", group.Name))
+	defer span.End()
+
+	group.OrganizationID = nil
+	if orgID, scoped := organizationScope(ctx); scoped {
+		group.OrganizationID = &orgID
+	}
+	group.Roles = nil
+	return tracing.RecordError(span, translateDBError(s.db.WithContext(ctx).Create(group).Error, ErrGroupExists))
+}
+
+// RenameGroup changes the name of a group
+func (s *GroupService) RenameGroup(ctx context.Context, id uint, name string) (*models.Group, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.RenameGroup", attribute.Int64("group.id", int64(id)))
+	defer span.End()
+
+	result := s.db.WithContext(ctx).Model(&models.Group{}).Scopes(scopeGroups(ctx)).Where("id = ?", id).Update("name", name)
+	if err := translateDBError(result.Error, ErrGroupExists); err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrGroupNotFound
+	}
+	return s.GetGroup(ctx, id)
+}
+
+// DeleteGroup deletes a group. Its members lose the permissions of its roles.
+func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
+	ctx, span := tracing.Start(ctx, "GroupService.DeleteGroup", attribute.Int64("group.id", int64(id)))
+	defer span.End()
+
+	result := s.db.WithContext(ctx).Scopes(scopeGroups(ctx)).Delete(&models.Group{}, id)
+	if result.Error != nil {
+		return tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// GetMembers returns the members of a group, with their role in the group's organization
+func (s *GroupService) GetMembers(ctx context.Context, id uint) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.GetMembers", attribute.Int64("group.id", int64(id)))
+	defer span.End()
+
+	group, err := s.GetGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	err = s.db.WithContext(ctx).Preload("Role").
+		Where("id IN (SELECT user_id FROM group_members WHERE group_id = ?)", id).
+		Order("username").Find(&users).Error
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := NewUserService(s.db).withOrganizationRoles(groupContext(ctx, group), users); err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return users, nil
+}
+
+// AddMember adds a user to a group. Within an organization only its members can join its groups.
+func (s *GroupService) AddMember(ctx context.Context, id, userID uint) error {
+	ctx, span := tracing.Start(ctx, "GroupService.AddMember", attribute.Int64("group.id", int64(id)), attribute.Int64("user.id", int64(userID)))
+	defer span.End()
+
+	group, err := s.GetGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := NewUserService(s.db).GetUserByID(groupContext(ctx, group), userID); err != nil {
+		return err
+	}
+	member := &models.GroupMember{GroupID: group.ID, UserID: userID}
+	return tracing.RecordError(span, s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error)
+}
+
+// RemoveMember removes a user from a group
+func (s *GroupService) RemoveMember(ctx context.Context, id, userID uint) error {
+	ctx, span := tracing.Start(ctx, "GroupService.RemoveMember", attribute.Int64("group.id", int64(id)), attribute.Int64("user.id", int64(userID)))
+	defer span.End()
+
+	if _, err := s.GetGroup(ctx, id); err != nil {
+		return err
+	}
+	result := s.db.WithContext(ctx).Where("group_id = ? AND user_id = ?", id, userID).Delete(&models.GroupMember{})
+	if result.Error != nil {
+		return tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrGroupMemberNotFound
+	}
+	return nil
+}
+
+// AddRole attaches a role to a group. The role must be visible where the group lives: a
+// shared role, or one of the group's organization. Unless the caller is a super-admin, the
+// role may only grant permissions the caller holds.
+func (s *GroupService) AddRole(ctx context.Context, id, roleID uint) (*models.Group, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.AddRole", attribute.Int64("group.id", int64(id)), attribute.Int64("role.id", int64(roleID)))
+	defer span.End()
+
+	group, err := s.GetGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	role, err := NewRoleService(s.db).GetRoleByID(groupContext(ctx, group), roleID)
+	if err != nil {
+		return nil, err
+	}
+	if group.OrganizationID == nil && role.OrganizationID != nil {
+		return nil, ErrRoleNotFound
+	}
+	if err := checkGrantable(ctx, s.db, role.ID); err != nil {
+		return nil, err
+	}
+	groupRole := &models.GroupRole{GroupID: group.ID, RoleID: role.ID}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(groupRole).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return s.GetGroup(ctx, id)
+}
+
+// RemoveRole detaches a role from a group
+func (s *GroupService) RemoveRole(ctx context.Context, id, roleID uint) (*models.Group, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.RemoveRole", attribute.Int64("group.id", int64(id)), attribute.Int64("role.id", int64(roleID)))
+	defer span.End()
+
+	if _, err := s.GetGroup(ctx, id); err != nil {
+		return nil, err
+	}
+	result := s.db.WithContext(ctx).Where("group_id = ? AND role_id = ?", id, roleID).Delete(&models.GroupRole{})
+	if result.Error != nil {
+		return nil, tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrGroupRoleNotFound
+	}
+	return s.GetGroup(ctx, id)
+}
+
+// groupContext returns ctx acting in the organization of group, so users and roles are
+// looked up where the group lives even when a super-admin manages it from outside
+func groupContext(ctx context.Context, group *models.Group) context.Context {
+	if group.OrganizationID == nil {
+		return ctx
+	}
+	tenant, _ := TenantFromContext(ctx)
+	tenant.OrganizationID = *group.OrganizationID
+	return WithTenant(ctx, tenant)
+}
+
+// scopeGroups limits a groups query to those of the organization of ctx
+func scopeGroups(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if orgID, scoped := organizationScope(ctx); scoped {
+			return db.Where("groups.organization_id = ?", orgID)
+		}
+		return db
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
+)
+
+// Invitation errors
+var (
+	// ErrInvalidInvitation is returned for unknown, expired or used invitation tokens, and
+	// for tokens presented with another email address than the one invited
+	ErrInvalidInvitation  = Forbidden("invalid_invitation", "Invalid or expired invitation")
+	ErrInvitationNotFound = NotFound("invitation_not_found", "Invitation not found")
+	ErrInvitationAccepted = Conflict("invitation_accepted", "Invitation has already been accepted")
+)
+
+// InvitationConfig configures InvitationService
+type InvitationConfig struct {
+	TTL time.Duration // lifetime of an invitation
+	URL string        // page accepting invitations, the token is appended as ?invite=
+}
+
+// InvitationService lets administrators invite users into a role. Invitees get the link by
+// email and choose their username and password when accepting.
+type InvitationService struct {
+	db              *gorm.DB
+	mailer          Mailer
+	registerService *RegisterService
+	ttl             time.Duration
+	url             string
+}
+
+// NewInvitationService creates a new InvitationService. Invitations are accepted through
+// registerService, so the registration mode applies to them too.
+func NewInvitationService(db *gorm.DB, mailer Mailer, registerService *RegisterService, cfg InvitationConfig) *InvitationService {
+	return &InvitationService{db: db, mailer: mailer, registerService: registerService, ttl: cfg.TTL, url: cfg.URL}
+}
+
+// CreateInvitation invites email into the role roleID of the active organization and mails
+// the link. The role may only grant permissions the caller holds. The token is only
+// returned here and by ResendInvitation.
+func (s *InvitationService) CreateInvitation(ctx context.Context, req *models.InvitationRequest, invitedBy string) (*models.InvitationCreated, error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.CreateInvitation", attribute.Int64("role.id", int64(req.RoleID)))
+	defer span.End()
+
+	role, err := NewRoleService(s.db).knownRole(ctx, "role_id", req.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGrantable(ctx, s.db, role.ID); err != nil {
+		return nil, err
+	}
+	token, err := newSetupToken()
+	if err != nil {
+		return nil, err
+	}
+
+	created := &models.InvitationCreated{Invitation: models.Invitation{
+		Email:     strings.ToLower(strings.TrimSpace(req.Email)),
+		RoleID:    role.ID,
+		TokenHash: hashToken(token),
+		InvitedBy: invitedBy,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}}
+	if orgID, scoped := organizationScope(ctx); scoped {
+		created.OrganizationID = &orgID
+	}
+	// The invitation is only kept when the mail went out
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Role").Create(&created.Invitation).Error; err != nil {
+			return err
+		}
+		created.Role = *role
+		return s.send(ctx, created, token)
+	})
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	slog.InfoContext(ctx, "CreateInvitation: invited user", "invitation_id", created.ID, "role", role.Name, "invited_by", invitedBy)
+	return created, nil
+}
+
+// ListInvitations returns the invitations that haven't been accepted, newest first.
+// Expired ones are included so they can be resent.
+func (s *InvitationService) ListInvitations(ctx context.Context) ([]models.Invitation, error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.ListInvitations")
+	defer span.End()
+
+	var invitations []models.Invitation
+	if err := s.db.WithContext(ctx).Scopes(scopeInvitations(ctx)).Preload("Role").Where("accepted_at IS NULL").Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return invitations, nil
+}
+
+// GetInvitation returns an invitation with its role and, once accepted, its user
+func (s *InvitationService) GetInvitation(ctx context.Context, id uint) (*models.Invitation, error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.GetInvitation", attribute.Int64("invitation.id", int64(id)))
+	defer span.End()
+
+	var invitation models.Invitation
+	if err := s.db.WithContext(ctx).Scopes(scopeInvitations(ctx)).Preload("Role").Preload("User").First(&invitation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, tracing.RecordError(span, err)
+	}
+	return &invitation, nil
+}
+
+// ResendInvitation mails an open invitation again with a new token and expiry. The link
+// sent before stops working.
+func (s *InvitationService) ResendInvitation(ctx context.Context, id uint) (*models.InvitationCreated, error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.ResendInvitation", attribute.Int64("invitation.id", int64(id)))
+	defer span.End()
+
+	token, err := newSetupToken()
+	if err != nil {
+		return nil, err
+	}
+	created := &models.InvitationCreated{}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(scopeInvitations(ctx)).First(&created.Invitation, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvitationNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if created.AcceptedAt != nil {
+			return ErrInvitationAccepted
+		}
+
+		created.TokenHash, created.ExpiresAt = hashToken(token), time.Now().Add(s.ttl)
+		if err := tx.Model(&created.Invitation).Updates(map[string]interface{}{"token_hash": created.TokenHash, "expires_at": created.ExpiresAt}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&created.Invitation).Association("Role").Find(&created.Role); err != nil {
+			return err
+		}
+		return s.send(ctx, created, token)
+	})
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return created, nil
+}
+
+// RevokeInvitation deletes an invitation that hasn't been accepted
+func (s *InvitationService) RevokeInvitation(ctx context.Context, id uint) error {
+	ctx, span := tracing.Start(ctx, "InvitationService.RevokeInvitation", attribute.Int64("invitation.id", int64(id)))
+	defer span.End()
+
+	result := s.db.WithContext(ctx).Scopes(scopeInvitations(ctx)).Where("accepted_at IS NULL").Delete(&models.Invitation{}, id)
+	if result.Error != nil {
+		return tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// Tell a missing invitation from an accepted one
+	if _, err := s.GetInvitation(ctx, id); err != nil {
+		return err
+	}
+	return ErrInvitationAccepted
+}
+
+// AcceptInvitation registers the invitee with the invited email address, the role of the
+// invitation and the username and password of their choice
+func (s *InvitationService) AcceptInvitation(ctx context.Context, req *models.InvitationAcceptRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.AcceptInvitation")
+	defer span.End()
+
+	var invitation models.Invitation
+	err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", hashToken(req.Token), time.Now()).
+		First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	// RegisterUser redeems the token again under a lock, so it can only be used once
+	return s.registerService.RegisterUser(ctx, &models.RegisterRequest{
+		InviteToken: req.Token,
+		Email:       invitation.Email,
+		Username:    req.Username,
+		Password:    req.Password,
+		First:       req.First,
+		Last:        req.Last,
+		Phone:       req.Phone,
+	})
+}
+
+// send mails the invitation link for token
+func (s *InvitationService) send(ctx context.Context, invitation *models.InvitationCreated, token string) error {
+	invitation.URL = s.url + "?invite=" + url.QueryEscape(token)
+	invitation.Token = token
+
+	body := fmt.Sprintf("You have been invited to join with the role %s. Use the link below to choose your username and password. "+
+		"It expires on %s and can only be used once.\n\n%s\n",
+		invitation.Role.Name, invitation.ExpiresAt.UTC().Format(time.RFC1123), invitation.URL)
+	if err := s.mailer.Send(invitation.Email, "You have been invited", body); err != nil {
+		slog.ErrorContext(ctx, "InvitationService: error sending invitation", "invitation_id", invitation.ID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// scopeInvitations limits an invitations query to those of the organization of ctx
+func scopeInvitations(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if orgID, scoped := organizationScope(ctx); scoped {
+			return db.Where("invitations.organization_id = ?", orgID)
+		}
+		return db
+	}
+}
+
+// redeemInvitation locks the open invitation for token within tx. The invitation must be
+// for email; the caller marks it accepted once the user exists.
+func redeemInvitation(tx *gorm.DB, token, email string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+		First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Email != strings.ToLower(strings.TrimSpace(email)) {
+		return nil, ErrInvalidInvitation
+	}
+	return &invitation, nil
+}
+
+// acceptInvitation links an invitation to the user who registered with it
+func acceptInvitation(tx *gorm.DB, invitation *models.Invitation, userID uint) error {
+	now := time.Now()
+	invitation.AcceptedAt, invitation.UserID = &now, &userID
+	return tx.Model(invitation).Updates(map[string]interface{}{"accepted_at": now, "user_id": userID}).Error
+}
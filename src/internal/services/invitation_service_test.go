@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+// newInvitationFixture returns a service inviting into the role editor of the organization
+// acme, with registration open to invitations only, and the mailer it sends links with
+func newInvitationFixture(t *testing.T) (*InvitationService, *fakeMailer, *gorm.DB, context.Context, *models.Role) {
+	t.Helper()
+	db := dbtest.New(t)
+	acme := dbtest.CreateOrganization(t, db, "acme")
+	dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	editor := dbtest.CreateRole(t, db, "editor", acme.ID, "users.update")
+	effectiveRoles.invalidate()
+
+	mailer := &fakeMailer{}
+	register := NewRegisterService(db, RegistrationConfig{DefaultRole: "guest", Mode: RegistrationInvite})
+	service := NewInvitationService(db, mailer, register, InvitationConfig{TTL: time.Hour, URL: "https://app.example.com/register"})
+	ctx := WithTenant(context.Background(), Tenant{OrganizationID: acme.ID, Username: "root", SuperAdmin: true})
+	return service, mailer, db, ctx, editor
+}
+
+func invite(t *testing.T, service *InvitationService, ctx context.Context, email string, role *models.Role) *models.InvitationCreated {
+	t.Helper()
+	created, err := service.CreateInvitation(ctx, &models.InvitationRequest{Email: email, RoleID: role.ID}, "root")
+	if err != nil {
+		t.Fatalf("CreateInvitation(%s): %v", email, err)
+	}
+	return created
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	service, mailer, db, ctx, editor := newInvitationFixture(t)
+	created := invite(t, service, ctx, " Erin@Example.com", editor)
+	if len(mailer.sent) != 1 || mailer.sent[0].to != "erin@example.com" || !strings.Contains(mailer.sent[0].body, created.URL) {
+		t.Fatalf("mailed %+v, want the link %s to erin@example.com", mailer.sent, created.URL)
+	}
+
+	user, err := service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: created.Token, Username: "erin", Password: "password"})
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if user.Email != "erin@example.com" || user.RoleID != editor.ID {
+		t.Errorf("user = %s with role %d, want erin@example.com with editor %d", user.Email, user.RoleID, editor.ID)
+	}
+	var membership models.Membership
+	if err := db.Where("user_id = ? AND organization_id = ?", user.ID, *created.OrganizationID).First(&membership).Error; err != nil || membership.RoleID != editor.ID {
+		t.Errorf("membership in acme = %+v, %v, want role editor", membership, err)
+	}
+	var invitation models.Invitation
+	db.First(&invitation, created.ID)
+	if invitation.AcceptedAt == nil || invitation.UserID == nil || *invitation.UserID != user.ID {
+		t.Errorf("invitation after accepting = %+v, want accepted by %d", invitation, user.ID)
+	}
+
+	// The token can only be used once
+	_, err = service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: created.Token, Username: "erin2", Password: "password"})
+	if !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("accepting twice = %v, want %v", err, ErrInvalidInvitation)
+	}
+	_, err = service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: "unknown", Username: "frank", Password: "password"})
+	if !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("accepting an unknown token = %v, want %v", err, ErrInvalidInvitation)
+	}
+}
+
+func TestExpiredInvitation(t *testing.T) {
+	service, _, db, ctx, editor := newInvitationFixture(t)
+	created := invite(t, service, ctx, "erin@example.com", editor)
+	db.Model(&models.Invitation{}).Where("id = ?", created.ID).Update("expires_at", time.Now().Add(-time.Minute))
+
+	_, err := service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: created.Token, Username: "erin", Password: "password"})
+	if !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("accepting an expired invitation = %v, want %v", err, ErrInvalidInvitation)
+	}
+
+	// Resending gives a new link and retires the old one
+	resent, err := service.ResendInvitation(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("ResendInvitation: %v", err)
+	}
+	if resent.Token == created.Token || !resent.ExpiresAt.After(time.Now()) {
+		t.Errorf("resent invitation = %+v, want a new token expiring later", resent)
+	}
+	_, err = service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: created.Token, Username: "erin", Password: "password"})
+	if !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("accepting with the old token = %v, want %v", err, ErrInvalidInvitation)
+	}
+	if _, err := service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: resent.Token, Username: "erin", Password: "password"}); err != nil {
+		t.Errorf("accepting with the new token: %v", err)
+	}
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	service, _, db, ctx, editor := newInvitationFixture(t)
+	revoked := invite(t, service, ctx, "erin@example.com", editor)
+	accepted := invite(t, service, ctx, "frank@example.com", editor)
+	if _, err := service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: accepted.Token, Username: "frank", Password: "password"}); err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+
+	if err := service.RevokeInvitation(ctx, revoked.ID); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+	if err := db.First(&models.Invitation{}, revoked.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("revoked invitation still exists: %v", err)
+	}
+	_, err := service.AcceptInvitation(context.Background(), &models.InvitationAcceptRequest{Token: revoked.Token, Username: "erin", Password: "password"})
+	if !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("accepting a revoked invitation = %v, want %v", err, ErrInvalidInvitation)
+	}
+
+	tests := []struct {
+		name string
+		id   uint
+		want error
+	}{
+		{name: "revoked", id: revoked.ID, want: ErrInvitationNotFound},
+		{name: "accepted", id: accepted.ID, want: ErrInvitationAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.RevokeInvitation(ctx, tt.id); !errors.Is(err, tt.want) {
+				t.Errorf("RevokeInvitation = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+)
+
+// LDAPGroupRole maps a directory group to a local role name.
+// Group is either a full DN or a bare CN, compared case-insensitively.
+type LDAPGroupRole struct {
+	Group string
+	Role  string
+}
+
+// LDAPConfig holds the connection and mapping settings for an LDAP or Active Directory server
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // must contain a single %s for the escaped username
+	GroupAttribute     string
+	EmailAttribute     string
+	FirstAttribute     string
+	LastAttribute      string
+	PhoneAttribute     string
+	GroupRoles         []LDAPGroupRole // checked in configured order, the first the user is a member of wins
+	DefaultRole        string          // assigned when no group matches; empty rejects the login
+	Organization       string          // directory users are members with their role; empty leaves them outside organizations
+}
+
+// DefaultLDAPConfig returns the Active Directory attribute names and user filter;
+// the connection settings must still be filled in.
+func DefaultLDAPConfig() LDAPConfig {
+	return LDAPConfig{
+		UserFilter:     "(&(objectClass=person)(sAMAccountName=%s))",
+		GroupAttribute: "memberOf",
+		EmailAttribute: "mail",
+		FirstAttribute: "givenName",
+		LastAttribute:  "sn",
+		PhoneAttribute: "telephoneNumber",
+		Organization:   "default",
+	}
+}
+
+// ParseLDAPGroupRoles parses the LDAP_GROUP_ROLE_MAP format. The role is taken
+// after the last ':' so that group DNs may contain any other character.
+func ParseLDAPGroupRoles(value string) []LDAPGroupRole {
+	var mappings []LDAPGroupRole
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		idx := strings.LastIndex(pair, ":")
+		if idx <= 0 || idx == len(pair)-1 {
+			continue
+		}
+		mappings = append(mappings, LDAPGroupRole{
+			Group: strings.TrimSpace(pair[:idx]),
+			Role:  strings.TrimSpace(pair[idx+1:]),
+		})
+	}
+	return mappings
+}
+
+// LDAPConn is the subset of *ldap.Conn used by LDAPAuthenticator.
+// Tests can supply an in-process implementation through an LDAPDialer.
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPDialer opens a connection to the directory described by cfg
+type LDAPDialer func(cfg LDAPConfig) (LDAPConn, error)
+
+// DialLDAP connects to cfg.URL, upgrading with StartTLS when configured
+func DialLDAP(cfg LDAPConfig) (LDAPConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// LDAPAuthenticator authenticates with a search-then-bind against a directory,
+// provisions the local user on first login and syncs its role from group membership.
+type LDAPAuthenticator struct {
+	db     *gorm.DB
+	cfg    LDAPConfig
+	dialer LDAPDialer
+}
+
+// NewLDAPAuthenticator creates a new LDAPAuthenticator. A nil dialer uses DialLDAP.
+func NewLDAPAuthenticator(db *gorm.DB, cfg LDAPConfig, dialer LDAPDialer) *LDAPAuthenticator {
+	if dialer == nil {
+		dialer = DialLDAP
+	}
+	return &LDAPAuthenticator{db: db, cfg: cfg, dialer: dialer}
+}
+
+// Name returns the backend name used in AUTH_BACKENDS
+func (a *LDAPAuthenticator) Name() string {
+	return models.AuthSourceLDAP
+}
+
+// Authenticate verifies the credentials against the directory and returns the synced local user
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// An empty password would turn the user bind into an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dialer(a.cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "LDAPAuthenticator: error connecting", "url", a.cfg.URL, "err", err)
+		return nil, ErrBackendUnavailable.Wrap(err)
+	}
+	defer conn.Close()
+
+	if a.cfg.BindDN != "" {
+		if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+			slog.ErrorContext(ctx, "LDAPAuthenticator: service account bind failed", "err", err)
+			return nil, ErrBackendUnavailable.Wrap(err)
+		}
+	}
+
+	entry, err := a.findUser(ctx, conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			slog.InfoContext(ctx, "LDAPAuthenticator: password mismatch", "username", username)
+			return nil, ErrInvalidCredentials
+		}
+		slog.ErrorContext(ctx, "LDAPAuthenticator: user bind failed", "username", username, "err", err)
+		if ldap.IsErrorAnyOf(err, ldap.ErrorNetwork, ldap.LDAPResultBusy, ldap.LDAPResultUnavailable) {
+			return nil, ErrBackendUnavailable.Wrap(err)
+		}
+		return nil, err
+	}
+
+	roleName := a.roleForGroups(entry.GetAttributeValues(a.cfg.GroupAttribute))
+	if roleName == "" {
+		slog.InfoContext(ctx, "LDAPAuthenticator: user is not in any mapped group", "username", username)
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.provision(ctx, username, entry, roleName)
+}
+
+// findUser looks up the directory entry for username
+func (a *LDAPAuthenticator) findUser(ctx context.Context, conn LDAPConn, username string) (*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(
+		a.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(a.cfg.UserFilter, ldap.EscapeFilter(username)),
+		[]string{
+			a.cfg.GroupAttribute,
+			a.cfg.EmailAttribute,
+			a.cfg.FirstAttribute,
+			a.cfg.LastAttribute,
+			a.cfg.PhoneAttribute,
+		},
+		nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		slog.ErrorContext(ctx, "LDAPAuthenticator: user search failed", "username", username, "err", err)
+		return nil, ErrBackendUnavailable.Wrap(err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUnknownUser
+	case 1:
+		return result.Entries[0], nil
+	default:
+		slog.InfoContext(ctx, "LDAPAuthenticator: user search did not return exactly one entry", "username", username, "entries", len(result.Entries))
+		return nil, ErrInvalidCredentials
+	}
+}
+
+// roleForGroups returns the role of the first configured mapping the user is a member of.
+// The order of the mappings decides, not the order the directory lists the groups in, so
+// a user in several mapped groups always gets the same role.
+func (a *LDAPAuthenticator) roleForGroups(groups []string) string {
+	for _, mapping := range a.cfg.GroupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(mapping.Group, group) || strings.EqualFold(mapping.Group, groupCN(group)) {
+				return mapping.Role
+			}
+		}
+	}
+	return a.cfg.DefaultRole
+}
+
+// groupCN extracts the value of the leading CN component of a group DN
+func groupCN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return ""
+	}
+	for _, attr := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// provision creates the local user on first login and otherwise syncs profile fields and role
+func (a *LDAPAuthenticator) provision(ctx context.Context, username string, entry *ldap.Entry, roleName string) (*models.User, error) {
+	var role models.Role
+	db := a.db.WithContext(ctx)
+	if err := db.Where("name = ? AND organization_id IS NULL", roleName).First(&role).Error; err != nil {
+		slog.ErrorContext(ctx, "LDAPAuthenticator: mapped role does not exist", "role", roleName, "err", err)
+		return nil, err
+	}
+
+	var user models.User
+	err := db.Where("username = ?", username).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		password, err := unusablePassword()
+		if err != nil {
+			return nil, err
+		}
+		user = models.User{
+			Username:   username,
+			Password:   password,
+			AuthSource: models.AuthSourceLDAP,
+		}
+	case err != nil:
+		return nil, err
+	case user.AuthSource != models.AuthSourceLDAP:
+		// Never take over an account that was created locally
+		slog.WarnContext(ctx, "LDAPAuthenticator: local account shadows the directory user", "username", username)
+		return nil, ErrUnknownUser
+	}
+
+	user.Email = entry.GetAttributeValue(a.cfg.EmailAttribute)
+	user.First = entry.GetAttributeValue(a.cfg.FirstAttribute)
+	user.Last = entry.GetAttributeValue(a.cfg.LastAttribute)
+	user.Phone = entry.GetAttributeValue(a.cfg.PhoneAttribute)
+	user.RoleID = role.ID
+	user.Role = role
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if a.cfg.Organization == "" {
+			return nil
+		}
+		organization, _, err := NewOrganizationService(tx).EnsureOrganization(ctx, a.cfg.Organization)
+		if err != nil {
+			return err
+		}
+		_, err = NewOrganizationService(tx).SetMember(ctx, organization.ID, &models.MembershipRequest{UserID: user.ID, RoleID: role.ID})
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "LDAPAuthenticator: error provisioning user", "username", username, "err", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+// unusablePassword returns a random secret so directory users can never log in through the local backend
+func unusablePassword() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+const (
+	testBindDN       = "cn=goapi,ou=services,dc=example,dc=com"
+	testBindPassword = "service-secret"
+	testAdminsGroup  = "cn=goapi-admins,ou=groups,dc=example,dc=com"
+	testUsersGroup   = "cn=goapi-users,ou=groups,dc=example,dc=com"
+)
+
+// fakeDirectory is an in-process LDAP server: it accepts the service account and the
+// passwords of its entries, and answers searches for the filter of one username
+type fakeDirectory struct {
+	cfg       LDAPConfig
+	passwords map[string]string // by DN
+	entries   map[string]*ldap.Entry
+	dials     int
+	down      bool // refuses connections
+}
+
+func newFakeDirectory(cfg LDAPConfig) *fakeDirectory {
+	return &fakeDirectory{
+		cfg:       cfg,
+		passwords: map[string]string{testBindDN: testBindPassword},
+		entries:   map[string]*ldap.Entry{},
+	}
+}
+
+// add creates the entry of username with password, member of groups in the given order
+func (d *fakeDirectory) add(username, password string, groups ...string) {
+	dn := fmt.Sprintf("cn=%s,ou=people,dc=example,dc=com", username)
+	d.passwords[dn] = password
+	d.entries[username] = ldap.NewEntry(dn, map[string][]string{
+		"memberOf":        groups,
+		"mail":            {username + "@corp.example.com"},
+		"givenName":       {"Directory"},
+		"sn":              {username},
+		"telephoneNumber": {"+15550100"},
+	})
+}
+
+func (d *fakeDirectory) dial(LDAPConfig) (LDAPConn, error) {
+	d.dials++
+	if d.down {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("connection refused"))
+	}
+	return &fakeConn{directory: d}, nil
+}
+
+type fakeConn struct {
+	directory *fakeDirectory
+}
+
+func (c *fakeConn) Bind(username, password string) error {
+	if want, ok := c.directory.passwords[username]; !ok || want != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+func (c *fakeConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+	for username, entry := range c.directory.entries {
+		if request.Filter == fmt.Sprintf(c.directory.cfg.UserFilter, ldap.EscapeFilter(username)) {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func testLDAPConfig() LDAPConfig {
+	cfg := DefaultLDAPConfig()
+	cfg.BindDN = testBindDN
+	cfg.BindPassword = testBindPassword
+	cfg.BaseDN = "dc=example,dc=com"
+	cfg.GroupRoles = []LDAPGroupRole{{Group: testAdminsGroup, Role: "admin"}, {Group: "goapi-users", Role: "guest"}}
+	return cfg
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *LDAPConfig)
+		username  string
+		password  string
+		wantRole  string
+		wantErr   error
+	}{
+		{name: "mapped by DN", username: "ada", password: "ada-secret", wantRole: "admin"},
+		{name: "mapped by CN", username: "bob", password: "bob-secret", wantRole: "guest"},
+		{name: "configured order wins over the directory's", username: "cy", password: "cy-secret", wantRole: "admin"},
+		{name: "unmapped without default role", username: "dan", password: "dan-secret", wantErr: ErrInvalidCredentials},
+		{name: "unmapped with default role", configure: func(cfg *LDAPConfig) { cfg.DefaultRole = "guest" }, username: "dan", password: "dan-secret", wantRole: "guest"},
+		{name: "wrong password", username: "ada", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "empty password", username: "ada", password: "", wantErr: ErrInvalidCredentials},
+		{name: "not in the directory", username: "eve", password: "eve-secret", wantErr: ErrUnknownUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New(t)
+			dbtest.CreateRole(t, db, "guest", 0, "users.read")
+			dbtest.CreateRole(t, db, "admin", 0, "users.update")
+			cfg := testLDAPConfig()
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+			directory := newFakeDirectory(cfg)
+			directory.add("ada", "ada-secret", testAdminsGroup)
+			directory.add("bob", "bob-secret", testUsersGroup)
+			directory.add("cy", "cy-secret", testUsersGroup, testAdminsGroup)
+			directory.add("dan", "dan-secret", "cn=other,ou=groups,dc=example,dc=com")
+
+			user, err := NewLDAPAuthenticator(db, cfg, directory.dial).Authenticate(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate: got %v, want %v", err, tt.wantErr)
+				}
+				var count int64
+				db.Model(&models.User{}).Where("username = ?", tt.username).Count(&count)
+				if count != 0 {
+					t.Errorf("Authenticate provisioned %s although it failed", tt.username)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Role.Name != tt.wantRole {
+				t.Errorf("role = %s, want %s", user.Role.Name, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestLDAPServiceBindFailure(t *testing.T) {
+	db := dbtest.New(t)
+	cfg := testLDAPConfig()
+	cfg.BindPassword = "rotated"
+	directory := newFakeDirectory(cfg)
+	directory.add("ada", "ada-secret", testAdminsGroup)
+
+	_, err := NewLDAPAuthenticator(db, cfg, directory.dial).Authenticate(context.Background(), "ada", "ada-secret")
+	if err == nil {
+		t.Fatal("Authenticate succeeded with a wrong service account password")
+	}
+	// The directory can't answer, which says nothing about the user's password
+	if !errors.Is(err, ErrBackendUnavailable) || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate: got %v, want %v", err, ErrBackendUnavailable)
+	}
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("Authenticate: got %v, want the directory's error", err)
+	}
+}
+
+func TestLDAPProvisioning(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	admin := dbtest.CreateRole(t, db, "admin", 0, "users.update")
+	cfg := testLDAPConfig()
+	directory := newFakeDirectory(cfg)
+	directory.add("ada", "ada-secret", testUsersGroup)
+	authenticator := NewLDAPAuthenticator(db, cfg, directory.dial)
+
+	user, err := authenticator.Authenticate(context.Background(), "ada", "ada-secret")
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.AuthSource != models.AuthSourceLDAP || user.Email != "ada@corp.example.com" || user.RoleID != guest.ID {
+		t.Errorf("first login provisioned %+v", user)
+	}
+	var membership models.Membership
+	if err := db.Joins("JOIN organizations ON organizations.id = memberships.organization_id").
+		Where("organizations.name = ? AND memberships.user_id = ?", cfg.Organization, user.ID).First(&membership).Error; err != nil {
+		t.Fatalf("membership in %s: %v", cfg.Organization, err)
+	}
+
+	// A later login follows the directory's groups
+	directory.add("ada", "ada-secret", testAdminsGroup)
+	again, err := authenticator.Authenticate(context.Background(), "ada", "ada-secret")
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != user.ID || again.RoleID != admin.ID {
+		t.Errorf("second login gave user %d role %d, want user %d role %d", again.ID, again.RoleID, user.ID, admin.ID)
+	}
+	db.First(&membership, "user_id = ?", user.ID)
+	if membership.RoleID != admin.ID {
+		t.Errorf("membership role = %d, want %d", membership.RoleID, admin.ID)
+	}
+
+	// The directory never takes over a local account
+	dbtest.CreateUser(t, db, "bob", guest.ID)
+	directory.add("bob", "bob-secret", testAdminsGroup)
+	if _, err := authenticator.Authenticate(context.Background(), "bob", "bob-secret"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("login of a local account through LDAP: got %v, want %v", err, ErrUnknownUser)
+	}
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		ldapFirst bool
+		ldapOnly  bool
+		down      bool
+		username  string
+		password  string
+		wantDials int
+		wantErr   error
+	}{
+		{name: "local user found locally", username: "alice", password: "password", wantDials: 0},
+		{name: "wrong local password stops the chain", username: "alice", password: "wrong", wantDials: 0, wantErr: ErrInvalidCredentials},
+		{name: "unknown locally falls back to ldap", username: "ada", password: "ada-secret", wantDials: 1},
+		{name: "unknown everywhere", username: "eve", password: "eve-secret", wantDials: 1, wantErr: ErrUnknownUser},
+		{name: "ldap first falls back to local", ldapFirst: true, username: "alice", password: "password", wantDials: 1},
+		{name: "wrong ldap password stops the chain", ldapFirst: true, username: "ada", password: "wrong", wantDials: 1, wantErr: ErrInvalidCredentials},
+		{name: "ldap down falls back to local", ldapFirst: true, down: true, username: "alice", password: "password", wantDials: 1},
+		{name: "ldap down after a wrong local password", down: true, username: "alice", password: "wrong", wantDials: 0, wantErr: ErrInvalidCredentials},
+		{name: "unknown locally with ldap down", down: true, username: "ada", password: "ada-secret", wantDials: 1, wantErr: ErrBackendUnavailable},
+		{name: "no backend answers", ldapOnly: true, down: true, username: "ada", password: "ada-secret", wantDials: 1, wantErr: ErrBackendUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New(t)
+			guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+			dbtest.CreateRole(t, db, "admin", 0, "users.update")
+			dbtest.CreateUser(t, db, "alice", guest.ID)
+			cfg := testLDAPConfig()
+			directory := newFakeDirectory(cfg)
+			directory.add("ada", "ada-secret", testUsersGroup)
+			directory.down = tt.down
+
+			local, directoryBackend := Authenticator(NewLocalAuthenticator(db)), Authenticator(NewLDAPAuthenticator(db, cfg, directory.dial))
+			chain := []Authenticator{local, directoryBackend}
+			if tt.ldapFirst {
+				chain = []Authenticator{directoryBackend, local}
+			}
+			if tt.ldapOnly {
+				chain = []Authenticator{directoryBackend}
+			}
+			user, err := NewLoginService(db, []byte("test-key"), chain...).authenticateWithBackends(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("authenticate: got %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || user.Username != tt.username {
+				t.Errorf("authenticate: got %v, %v, want user %s", user, err, tt.username)
+			}
+			if directory.dials != tt.wantDials {
+				t.Errorf("directory dialed %d times, want %d", directory.dials, tt.wantDials)
+			}
+		})
+	}
+}
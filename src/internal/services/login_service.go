@@ -1,121 +1,288 @@
 package services
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
-	"log"
-	"os"
-	"path/filepath"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
-	"golang.org/x/crypto/bcrypt"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 
-	"go_api/internal/models"
-	"encoding/hex"
+	"go_api/src/internal/metrics"
+	"go_api/src/internal/models"
+	"go_api/src/internal/requestid"
+	"go_api/src/internal/tracing"
 )
 
+// Claims are the claims of an access token. Role is the user's role in the active
+// organization, OrganizationID; 0 is outside any organization.
 type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	OrganizationID uint   `json:"org,omitempty"`
+	SuperAdmin     bool   `json:"super_admin,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ErrSecondFactorRequired is returned by Authenticate together with a second-factor ticket
+// when the password was correct but the user must still complete a passkey assertion.
+var ErrSecondFactorRequired = errors.New("second factor required")
+
+// ErrUserDisabled is returned when a disabled account authenticates successfully
+var ErrUserDisabled = errors.New("user disabled")
+
+// secondFactorTicketTTL bounds the time between the password and the passkey step
+const secondFactorTicketTTL = 5 * time.Minute
+
 type LoginService struct {
-	db     *gorm.DB
-	jwtKey []byte
+	db             *gorm.DB
+	jwtKey         []byte
+	authenticators []Authenticator
+}
+
+// NewLoginService creates a new LoginService that signs tokens with jwtKey.
+// Authenticators are tried in order; without any only local accounts can log in.
+func NewLoginService(db *gorm.DB, jwtKey []byte, authenticators ...Authenticator) *LoginService {
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewLocalAuthenticator(db)}
+	}
+
+	return &LoginService{
+		db:             db,
+		jwtKey:         jwtKey,
+		authenticators: authenticators,
+	}
 }
 
-func NewLoginService(db *gorm.DB) *LoginService {
-	// Resolve path to root relative to current file
-	rootPath, err := filepath.Abs(filepath.Join("./"))
+func (s *LoginService) Authenticate(ctx context.Context, username, password string) (string, error) {
+	ctx, span := tracing.Start(ctx, "LoginService.Authenticate")
+	defer span.End()
+
+	slog.DebugContext(ctx, "Authenticate: attempting password login", "username", username)
+
+	user, err := s.authenticateWithBackends(ctx, username, password)
 	if err != nil {
-		log.Fatal("Unable to resolve root path:", err)
+		span.SetAttributes(attribute.String("login.failure_reason", loginFailureReason(err)))
+		s.RecordLogin(ctx, username, models.LoginMethodPassword, models.LoginOutcomeRejected, loginFailureReason(err))
+		if errors.Is(err, ErrBackendUnavailable) {
+			return "", err
+		}
+		return "", ErrInvalidCredentials.Wrap(err)
 	}
 
-	envPath := filepath.Join(rootPath, ".env")
+	if user.Disabled {
+		s.RecordLogin(ctx, user.Username, models.LoginMethodPassword, models.LoginOutcomeRejected, loginFailureReason(ErrUserDisabled))
+		return "", ErrInvalidCredentials.Wrap(ErrUserDisabled)
+	}
 
-	log.Println("Loaded .env from:", envPath)
-	// Load .env from root
-	if err := godotenv.Load(envPath); err != nil {
-		log.Fatalf("Error loading .env file from %s: %v", envPath, err)
+	if user.PasskeyMFA {
+		ticket, err := s.issueSecondFactorTicket(user)
+		if err != nil {
+			return "", err
+		}
+		s.RecordLogin(ctx, user.Username, models.LoginMethodPassword, models.LoginOutcomeRequested, "second_factor_required")
+		return ticket, ErrSecondFactorRequired
 	}
 
-	jwtKey := os.Getenv("JWT_KEY")
-	if jwtKey == "" {
-		log.Fatal("JWT_KEY is not set in the environment variables")
+	return s.IssueToken(ctx, user, models.LoginMethodPassword)
+}
+
+// issueSecondFactorTicket signs a short-lived ticket proving the password step succeeded.
+// It uses a key derived from the JWT key so the ticket is never accepted as an access token.
+func (s *LoginService) issueSecondFactorTicket(user *models.User) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Subject:   user.Username,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(secondFactorTicketTTL)),
 	}
-	log.Printf("==> JWT_KEY value: %s", jwtKey)
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secondFactorKey())
+}
 
-	return &LoginService{
-		db:     db,
-		jwtKey: []byte(jwtKey),
+// ParseSecondFactorTicket validates a ticket from issueSecondFactorTicket and returns the username
+func (s *LoginService) ParseSecondFactorTicket(ticket string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(ticket, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.secondFactorKey(), nil
+	})
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return "", ErrInvalidCredentials
 	}
+	return claims.Subject, nil
 }
 
-func (s *LoginService) Authenticate(username, password string) (string, error) {
-	var user models.User
+func (s *LoginService) secondFactorKey() []byte {
+	sum := sha256.Sum256(append([]byte("second-factor:"), s.jwtKey...))
+	return sum[:]
+}
 
-	log.Printf("Authenticate: Attempting to authenticate user: %s", username)
+// IssueToken signs a JWT for an already authenticated user and records the login. The
+// active organization is the one the user joined first.
+func (s *LoginService) IssueToken(ctx context.Context, user *models.User, method string) (string, error) {
+	ctx, span := tracing.Start(ctx, "LoginService.IssueToken", attribute.String("login.method", method))
+	defer span.End()
 
-	// Fetch the user from the database by username, preloading the Role
-	if err := s.db.Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
-		log.Printf("Authenticate: Error fetching user '%s' from DB: %v", username, err)
-		return "", errors.New("invalid credentials")
+	if user.Disabled {
+		s.RecordLogin(ctx, user.Username, method, models.LoginOutcomeRejected, loginFailureReason(ErrUserDisabled))
+		return "", ErrUserDisabled
 	}
 
-	if user.ID == 0 { // Check if user was actually found.  Important!
-		log.Printf("Authenticate: User '%s' not found in database", username)
-		return "", errors.New("invalid credentials")
+	var first []models.Membership
+	if err := s.db.WithContext(ctx).Preload("Role").Where("user_id = ?", user.ID).Order("created_at, organization_id").Limit(1).Find(&first).Error; err != nil {
+		return "", tracing.RecordError(span, err)
 	}
+	var m *models.Membership
+	if len(first) > 0 {
+		m = &first[0]
+	}
+	tokenString, err := s.signToken(ctx, user, m)
+	if err != nil {
+		return "", tracing.RecordError(span, err)
+	}
+
+	// Log the successful login attempt
+	s.RecordLogin(ctx, user.Username, method, models.LoginOutcomeSuccess, "")
 
-	log.Printf("Authenticate: Fetched user from DB: Username: %s", user.Username)
-    log.Printf("Authenticate: Fetched user from DB: Hashed Password (string): %s", user.Password)
-    log.Printf("Authenticate: Fetched user from DB: Hashed Password (bytes): %s", hex.EncodeToString([]byte(user.Password)))
+	slog.InfoContext(ctx, "Authenticate: login succeeded", "username", user.Username, "method", method)
+	return tokenString, nil
+}
 
+// SwitchOrganization signs a new token for username with organizationID as the active
+// organization. Super-admins can switch to any organization, or to none with 0.
+func (s *LoginService) SwitchOrganization(ctx context.Context, username string, organizationID uint) (string, error) {
+	ctx, span := tracing.Start(ctx, "LoginService.SwitchOrganization", attribute.Int64("organization.id", int64(organizationID)))
+	defer span.End()
 
-	// Compare the provided password with the stored hash
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		log.Printf("Authenticate: Password mismatch for user '%s': %v", username, err)
-		return "", errors.New("invalid credentials")
+	var user models.User
+	if err := s.db.WithContext(ctx).Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUserNotFound
+		}
+		return "", tracing.RecordError(span, err)
+	}
+	if user.Disabled {
+		return "", ErrTokenRevoked.Wrap(ErrUserDisabled)
+	}
+
+	var m *models.Membership
+	if organizationID != 0 {
+		var err error
+		m, err = membership(ctx, s.db, user.ID, organizationID)
+		if errors.Is(err, ErrMembershipNotFound) && user.SuperAdmin {
+			// Super-admins act in organizations they don't belong to with their own role
+			if _, err = NewOrganizationService(s.db).GetOrganization(ctx, organizationID); err != nil {
+				return "", err
+			}
+			m = &models.Membership{UserID: user.ID, OrganizationID: organizationID, RoleID: user.RoleID, Role: user.Role}
+		} else if errors.Is(err, ErrMembershipNotFound) {
+			return "", ErrNotMember
+		} else if err != nil {
+			return "", tracing.RecordError(span, err)
+		}
+	} else if !user.SuperAdmin {
+		return "", ErrNotMember
 	}
 
-	log.Printf("Authenticate: Password comparison successful for user: %s", username)
+	tokenString, err := s.signToken(ctx, &user, m)
+	if err != nil {
+		return "", tracing.RecordError(span, err)
+	}
+	slog.InfoContext(ctx, "SwitchOrganization: switched organization", "username", user.Username, "organization_id", organizationID)
+	return tokenString, nil
+}
 
-	// Create JWT claims
+// signToken signs a JWT for user acting in the organization of m, or outside organizations
+// with their own role when m is nil. The issue time lets revoking a user's tokens reject older ones.
+func (s *LoginService) signToken(ctx context.Context, user *models.User, m *models.Membership) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		Username: username,
-		Role:     user.Role.Name,
+		Username:   user.Username,
+		Role:       user.Role.Name,
+		SuperAdmin: user.SuperAdmin,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)),
 		},
 	}
+	if m != nil {
+		claims.Role, claims.OrganizationID = m.Role.Name, m.OrganizationID
+	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtKey)
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtKey)
 	if err != nil {
-		log.Printf("Authenticate: Error generating JWT token for user '%s': %v", username, err)
+		slog.ErrorContext(ctx, "signToken: error signing token", "username", user.Username, "err", err)
 		return "", errors.New("failed to generate token")
 	}
+	return tokenString, nil
+}
 
-	log.Printf("Authenticate: Token generated successfully for user: %s", username)
-
-	// Log the successful login attempt
+// RecordLogin writes an audit entry to the logins table. Failures are logged and never returned,
+// since auditing must not change the outcome of the login itself.
+func (s *LoginService) RecordLogin(ctx context.Context, username, method, outcome, detail string) {
 	login := models.Login{
 		Username:  username,
 		Password:  "", // Do not store the password in the login log
+		Method:    method,
+		Outcome:   outcome,
+		Detail:    detail,
+		RequestID: requestid.FromContext(ctx),
 		LoginTime: time.Now(),
 	}
-	if err := s.db.Create(&login).Error; err != nil {
-		log.Println("Authenticate: Error logging login attempt:", err)
-		// Do not return this error to the client as the login was successful
+	if err := s.db.WithContext(ctx).Create(&login).Error; err != nil {
+		slog.ErrorContext(ctx, "Authenticate: error recording login attempt", "err", err)
 	}
-
-	log.Printf("Authenticate: Successful login for user: %s", username)
-	return tokenString, nil
+	metrics.Logins.WithLabelValues(method, outcome, detail).Inc()
 }
 
+// authenticateWithBackends tries each authenticator in order. A backend that does not
+// know the user or can't be reached passes to the next one; any other failure stops the
+// chain. When no backend knew the user and one couldn't be reached, the login fails with
+// ErrBackendUnavailable rather than as unknown: the user may be in the missing backend.
+func (s *LoginService) authenticateWithBackends(ctx context.Context, username, password string) (*models.User, error) {
+	var unavailable error
+	for _, authenticator := range s.authenticators {
+		backendCtx, span := tracing.Start(ctx, "Authenticator.Authenticate", attribute.String("auth.backend", authenticator.Name()))
+		user, err := authenticator.Authenticate(backendCtx, username, password)
+		span.End()
+		if err == nil {
+			slog.DebugContext(ctx, "Authenticate: user authenticated", "username", username, "backend", authenticator.Name())
+			return user, nil
+		}
+		if errors.Is(err, ErrBackendUnavailable) {
+			slog.WarnContext(ctx, "Authenticate: backend unavailable", "username", username, "backend", authenticator.Name(), "err", err)
+			unavailable = err
+			continue
+		}
+		if errors.Is(err, ErrUnknownUser) {
+			continue
+		}
+		slog.InfoContext(ctx, "Authenticate: backend rejected user", "username", username, "backend", authenticator.Name(), "err", err)
+		return nil, err
+	}
+	if unavailable != nil {
+		return nil, unavailable
+	}
+	slog.InfoContext(ctx, "Authenticate: user not found in any backend", "username", username)
+	return nil, ErrUnknownUser
+}
 
+// loginFailureReason is the audit and metrics reason for a failed password login
+func loginFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownUser):
+		return "unknown_user"
+	case errors.Is(err, ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, ErrUserDisabled):
+		return "user_disabled"
+	case errors.Is(err, ErrBackendUnavailable):
+		return "backend_unavailable"
+	default:
+		return "backend_error"
+	}
+}
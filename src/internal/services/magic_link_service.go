@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+)
+
+// ErrMagicLinkRateLimited is returned when too many links were requested for one email address
+var ErrMagicLinkRateLimited = RateLimited("magic_link_rate_limited", "Too many requests, try again later")
+
+// ErrInvalidMagicLink is returned for forged, unknown, expired or already used links
+var ErrInvalidMagicLink = Unauthenticated("invalid_magic_link", "Invalid or expired login link")
+
+// MagicLinkService issues and consumes single-use passwordless login links
+type MagicLinkService struct {
+	db           *gorm.DB
+	loginService *LoginService
+	mailer       Mailer
+	key          []byte
+	ttl          time.Duration
+	baseURL      string
+	limiter      *emailRateLimiter
+}
+
+// MagicLinkConfig configures MagicLinkService
+type MagicLinkConfig struct {
+	Key          []byte        // HMAC key for link tokens
+	TTL          time.Duration // lifetime of a link
+	URL          string        // login page the token is appended to
+	MaxPerWindow int           // links per email address within Window
+	Window       time.Duration
+}
+
+// NewMagicLinkService creates a new MagicLinkService
+func NewMagicLinkService(db *gorm.DB, loginService *LoginService, mailer Mailer, cfg MagicLinkConfig) *MagicLinkService {
+	return &MagicLinkService{
+		db:           db,
+		loginService: loginService,
+		mailer:       mailer,
+		key:          cfg.Key,
+		ttl:          cfg.TTL,
+		baseURL:      cfg.URL,
+		limiter:      newEmailRateLimiter(cfg.MaxPerWindow, cfg.Window),
+	}
+}
+
+// RequestLink emails a login link to the user owning email. Unknown addresses, roles
+// without magic-link login and failures to create or send the link all succeed silently,
+// so the endpoint cannot be used to probe accounts; failures are logged instead.
+func (s *MagicLinkService) RequestLink(ctx context.Context, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if !s.limiter.Allow(email) {
+		s.loginService.RecordLogin(ctx, email, models.LoginMethodMagicLink, models.LoginOutcomeRejected, "rate_limited")
+		return ErrMagicLinkRateLimited
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Preload("Role").Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.loginService.RecordLogin(ctx, email, models.LoginMethodMagicLink, models.LoginOutcomeRejected, "unknown_email")
+			return nil
+		}
+		return err
+	}
+
+	if !user.Role.MagicLinkEnabled {
+		s.loginService.RecordLogin(ctx, user.Username, models.LoginMethodMagicLink, models.LoginOutcomeRejected, "disabled_for_role")
+		return nil
+	}
+
+	if err := s.sendLink(ctx, &user); err != nil {
+		slog.ErrorContext(ctx, "RequestLink: error sending login link", "username", user.Username, "err", err)
+		s.loginService.RecordLogin(ctx, user.Username, models.LoginMethodMagicLink, models.LoginOutcomeRejected, "send_failed")
+		return nil
+	}
+
+	s.loginService.RecordLogin(ctx, user.Username, models.LoginMethodMagicLink, models.LoginOutcomeRequested, "")
+	return nil
+}
+
+// sendLink stores a new link for user and mails it
+func (s *MagicLinkService) sendLink(ctx context.Context, user *models.User) error {
+	token, err := s.newToken()
+	if err != nil {
+		return err
+	}
+
+	link := models.MagicLink{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.db.WithContext(ctx).Create(&link).Error; err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Use the link below to sign in. It expires in %s and can only be used once.\n\n%s?token=%s\n",
+		s.ttl, s.baseURL, url.QueryEscape(token))
+	return s.mailer.Send(user.Email, "Your sign-in link", body)
+}
+
+// ConsumeLink redeems a login link exactly once and returns a signed JWT. Like Authenticate,
+// it returns a second-factor ticket with ErrSecondFactorRequired for users with passkey MFA.
+func (s *MagicLinkService) ConsumeLink(ctx context.Context, token string) (string, error) {
+	if !s.validSignature(token) {
+		return "", ErrInvalidMagicLink
+	}
+
+	var link models.MagicLink
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", hashToken(token)).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrInvalidMagicLink
+		}
+		return "", err
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Preload("Role").First(&user, link.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrInvalidMagicLink
+		}
+		return "", err
+	}
+
+	// Mark the link used in a single conditional update so concurrent replays cannot both succeed
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.MagicLink{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", link.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		detail := "expired"
+		if link.UsedAt != nil {
+			detail = "replayed"
+		}
+		s.loginService.RecordLogin(ctx, user.Username, models.LoginMethodMagicLink, models.LoginOutcomeRejected, detail)
+		return "", ErrInvalidMagicLink
+	}
+
+	if !user.Role.MagicLinkEnabled {
+		s.loginService.RecordLogin(ctx, user.Username, models.LoginMethodMagicLink, models.LoginOutcomeRejected, "disabled_for_role")
+		return "", ErrInvalidMagicLink
+	}
+
+	// Checked before both branches, so a disabled user doesn't get a second-factor ticket either
+	if user.Disabled {
+		s.loginService.RecordLogin(ctx, user.Username, models.LoginMethodMagicLink, models.LoginOutcomeRejected, loginFailureReason(ErrUserDisabled))
+		return "", ErrInvalidMagicLink.Wrap(ErrUserDisabled)
+	}
+
+	if user.PasskeyMFA {
+		ticket, err := s.loginService.issueSecondFactorTicket(&user)
+		if err != nil {
+			return "", err
+		}
+		return ticket, ErrSecondFactorRequired
+	}
+
+	return s.loginService.IssueToken(ctx, &user, models.LoginMethodMagicLink)
+}
+
+// newToken returns "<nonce>.<signature>" where the signature is an HMAC of the nonce
+func (s *MagicLinkService) newToken() (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// validSignature rejects tampered tokens before they reach the database
+func (s *MagicLinkService) validSignature(token string) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(nonce)))
+}
+
+func (s *MagicLinkService) sign(value string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashToken returns the hex SHA-256 of a token, which is what gets stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// emailRateLimiter allows at most max events per key within a sliding window
+type emailRateLimiter struct {
+	mu     sync.Mutex
+	max    int
+	window time.Duration
+	events map[string][]time.Time
+	now    func() time.Time
+}
+
+func newEmailRateLimiter(max int, window time.Duration) *emailRateLimiter {
+	return &emailRateLimiter{max: max, window: window, events: make(map[string][]time.Time), now: time.Now}
+}
+
+// Allow records an event for key and reports whether it is within the limit
+func (l *emailRateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+
+	// Drop idle keys once the map grows so random addresses cannot exhaust memory
+	if len(l.events) > 10000 {
+		for k, ts := range l.events {
+			if len(ts) == 0 || !ts[len(ts)-1].After(cutoff) {
+				delete(l.events, k)
+			}
+		}
+	}
+
+	recent := l.events[key][:0]
+	for _, t := range l.events[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= l.max {
+		l.events[key] = recent
+		return false
+	}
+	l.events[key] = append(recent, now)
+	return true
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// fakeMailer records the messages it is asked to send, or fails with err
+type fakeMailer struct {
+	sent []sentMail
+	err  error
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// token returns the token of the last link mailed
+func (m *fakeMailer) token(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no link was mailed")
+	}
+	match := linkToken.FindStringSubmatch(m.sent[len(m.sent)-1].body)
+	if match == nil {
+		t.Fatalf("no link in %q", m.sent[len(m.sent)-1].body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// newMagicLinkFixture returns a service allowing 3 links per address and hour, and the
+// user alice of a role with magic-link login
+func newMagicLinkFixture(t *testing.T) (*MagicLinkService, *fakeMailer, *gorm.DB) {
+	t.Helper()
+	db := dbtest.New(t)
+	role := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	db.Model(role).Update("magic_link_enabled", true)
+	dbtest.CreateUser(t, db, "alice", role.ID)
+	mailer := &fakeMailer{}
+	service := NewMagicLinkService(db, NewLoginService(db, []byte("test-key")), mailer, MagicLinkConfig{
+		Key:          []byte("link-key"),
+		TTL:          15 * time.Minute,
+		URL:          "https://app.example.com/login",
+		MaxPerWindow: 3,
+		Window:       time.Hour,
+	})
+	return service, mailer, db
+}
+
+// lastLogin returns the outcome and detail of the latest login record
+func lastLogin(t *testing.T, db *gorm.DB) (string, string) {
+	t.Helper()
+	var login models.Login
+	if err := db.Order("id DESC").First(&login).Error; err != nil {
+		t.Fatal(err)
+	}
+	return login.Outcome, login.Detail
+}
+
+func TestMagicLinkSingleUse(t *testing.T) {
+	ctx := context.Background()
+	service, mailer, db := newMagicLinkFixture(t)
+
+	if err := service.RequestLink(ctx, " Alice@Example.com "); err != nil {
+		t.Fatal(err)
+	}
+	if mailer.sent[0].to != "alice@example.com" || !strings.HasPrefix(mailer.sent[0].body, "Use the link below") {
+		t.Errorf("mailed %+v", mailer.sent[0])
+	}
+	token := mailer.token(t)
+
+	var link models.MagicLink
+	db.First(&link)
+	if link.TokenHash != hashToken(token) || strings.Contains(link.TokenHash, token) {
+		t.Error("the link is not stored as the hash of its token")
+	}
+
+	jwt, err := service.ConsumeLink(ctx, token)
+	if err != nil || jwt == "" {
+		t.Fatalf("ConsumeLink = %q, %v", jwt, err)
+	}
+	if outcome, _ := lastLogin(t, db); outcome != models.LoginOutcomeSuccess {
+		t.Errorf("login outcome = %s, want success", outcome)
+	}
+
+	if _, err := service.ConsumeLink(ctx, token); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("second use: got %v, want %v", err, ErrInvalidMagicLink)
+	}
+	if outcome, detail := lastLogin(t, db); outcome != models.LoginOutcomeRejected || detail != "replayed" {
+		t.Errorf("second use recorded %s %s, want rejected replayed", outcome, detail)
+	}
+
+	// Each request mails a new link; using one leaves the others valid
+	service.RequestLink(ctx, "alice@example.com")
+	service.RequestLink(ctx, "alice@example.com")
+	second := mailer.token(t)
+	if second == token {
+		t.Fatal("a new request mailed the same token")
+	}
+	if _, err := service.ConsumeLink(ctx, second); err != nil {
+		t.Errorf("newest link: %v", err)
+	}
+	if _, err := service.ConsumeLink(ctx, linkToken.FindStringSubmatch(mailer.sent[1].body)[1]); err != nil {
+		t.Errorf("earlier link: %v", err)
+	}
+}
+
+func TestMagicLinkExpiry(t *testing.T) {
+	ctx := context.Background()
+	service, mailer, db := newMagicLinkFixture(t)
+	if err := service.RequestLink(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := mailer.token(t)
+
+	var link models.MagicLink
+	db.First(&link)
+	if ttl := time.Until(link.ExpiresAt); ttl <= 14*time.Minute || ttl > 15*time.Minute {
+		t.Errorf("link expires in %s, want the 15 minute TTL", ttl)
+	}
+
+	db.Model(&link).Update("expires_at", time.Now().Add(-time.Second))
+	if _, err := service.ConsumeLink(ctx, token); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("expired link: got %v, want %v", err, ErrInvalidMagicLink)
+	}
+	if outcome, detail := lastLogin(t, db); outcome != models.LoginOutcomeRejected || detail != "expired" {
+		t.Errorf("expired link recorded %s %s, want rejected expired", outcome, detail)
+	}
+	db.First(&link, link.ID)
+	if link.UsedAt != nil {
+		t.Error("an expired link was marked used")
+	}
+}
+
+// A link mailed before the user was disabled logs nobody in, nor leads to the second factor
+func TestMagicLinkDisabledUser(t *testing.T) {
+	for _, passkeyMFA := range []bool{false, true} {
+		t.Run(fmt.Sprintf("passkey MFA %v", passkeyMFA), func(t *testing.T) {
+			ctx := context.Background()
+			service, mailer, db := newMagicLinkFixture(t)
+			if err := service.RequestLink(ctx, "alice@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			db.Model(&models.User{}).Where("username = ?", "alice").Updates(map[string]interface{}{"disabled": true, "passkey_mfa": passkeyMFA})
+
+			ticket, err := service.ConsumeLink(ctx, mailer.token(t))
+			if !errors.Is(err, ErrInvalidMagicLink) || ticket != "" {
+				t.Errorf("ConsumeLink = %q, %v, want %v", ticket, err, ErrInvalidMagicLink)
+			}
+			if outcome, detail := lastLogin(t, db); outcome != models.LoginOutcomeRejected || detail != "user_disabled" {
+				t.Errorf("recorded %s %s, want rejected user_disabled", outcome, detail)
+			}
+		})
+	}
+}
+
+func TestMagicLinkForgedTokens(t *testing.T) {
+	ctx := context.Background()
+	service, mailer, _ := newMagicLinkFixture(t)
+	service.RequestLink(ctx, "alice@example.com")
+	token := mailer.token(t)
+	nonce, _, _ := strings.Cut(token, ".")
+
+	// A token signed with the right key but never issued is unknown
+	unissued, err := service.newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, forged := range map[string]string{
+		"empty":          "",
+		"nonce only":     nonce,
+		"bad signature":  nonce + ".AAAA",
+		"other key":      nonce + "." + (&MagicLinkService{key: []byte("other-key")}).sign(nonce),
+		"never issued":   unissued,
+		"trailing bytes": token + "x",
+	} {
+		if _, err := service.ConsumeLink(ctx, forged); !errors.Is(err, ErrInvalidMagicLink) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidMagicLink)
+		}
+	}
+	if _, err := service.ConsumeLink(ctx, token); err != nil {
+		t.Errorf("the issued link stopped working after forged attempts: %v", err)
+	}
+}
+
+// The caller can't tell known from unknown addresses, whether or not the link was sent
+func TestRequestLinkDoesNotRevealAccounts(t *testing.T) {
+	ctx := context.Background()
+	service, mailer, db := newMagicLinkFixture(t)
+	dbtest.CreateUser(t, db, "bob", dbtest.CreateRole(t, db, "staff", 0, "users.read").ID)
+
+	tests := []struct {
+		name       string
+		email      string
+		mailErr    error
+		wantMail   bool
+		wantDetail string
+	}{
+		{name: "known", email: "alice@example.com", wantMail: true},
+		{name: "unknown", email: "nobody@example.com", wantDetail: "unknown_email"},
+		{name: "role without magic links", email: "bob@example.com", wantDetail: "disabled_for_role"},
+		{name: "mailer failure", email: "alice@example.com", mailErr: errors.New("connection refused"), wantDetail: "send_failed"},
+	}
+	for _, tt := range tests {
+		mailer.sent, mailer.err = nil, tt.mailErr
+		if err := service.RequestLink(ctx, tt.email); err != nil {
+			t.Errorf("%s: RequestLink = %v, want the same success as for every address", tt.name, err)
+		}
+		if sent := len(mailer.sent) > 0; sent != tt.wantMail {
+			t.Errorf("%s: mail sent = %v, want %v", tt.name, sent, tt.wantMail)
+		}
+		if _, detail := lastLogin(t, db); detail != tt.wantDetail {
+			t.Errorf("%s: recorded %q, want %q", tt.name, detail, tt.wantDetail)
+		}
+	}
+}
+
+func TestMagicLinkRateLimit(t *testing.T) {
+	ctx := context.Background()
+	service, mailer, db := newMagicLinkFixture(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := service.RequestLink(ctx, "alice@example.com"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	// Addresses are counted case-insensitively, so changing the case doesn't bypass the limit
+	if err := service.RequestLink(ctx, "ALICE@example.com"); !errors.Is(err, ErrMagicLinkRateLimited) {
+		t.Fatalf("fourth request: got %v, want %v", err, ErrMagicLinkRateLimited)
+	}
+	if len(mailer.sent) != 3 {
+		t.Errorf("%d links mailed, want 3", len(mailer.sent))
+	}
+	if outcome, detail := lastLogin(t, db); outcome != models.LoginOutcomeRejected || detail != "rate_limited" {
+		t.Errorf("limited request recorded %s %s", outcome, detail)
+	}
+
+	// Unknown addresses are limited alike, so the limit reveals nothing either
+	for i := 0; i < 3; i++ {
+		service.RequestLink(ctx, "nobody@example.com")
+	}
+	if err := service.RequestLink(ctx, "nobody@example.com"); !errors.Is(err, ErrMagicLinkRateLimited) {
+		t.Errorf("unknown address: got %v, want %v", err, ErrMagicLinkRateLimited)
+	}
+
+	// The window slides: requests age out one by one
+	now = now.Add(59 * time.Minute)
+	if err := service.RequestLink(ctx, "alice@example.com"); !errors.Is(err, ErrMagicLinkRateLimited) {
+		t.Errorf("within the window: got %v, want %v", err, ErrMagicLinkRateLimited)
+	}
+	now = now.Add(time.Minute + time.Second)
+	if err := service.RequestLink(ctx, "alice@example.com"); err != nil {
+		t.Errorf("after the window: %v", err)
+	}
+	if len(mailer.sent) != 4 {
+		t.Errorf("%d links mailed, want 4", len(mailer.sent))
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer delivers plain-text email messages
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// LogMailer writes messages to the application log instead of sending them.
+// It is the default when no SMTP server is configured and is meant for development.
+type LogMailer struct{}
+
+// Send logs the message at debug level. Tokens in links are redacted like any other log value.
+func (LogMailer) Send(to, subject, body string) error {
+	slog.Debug("LogMailer: message not sent", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP relay
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send delivers the message using PLAIN auth when credentials are configured
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := m.Addr
+		if idx := strings.LastIndex(host, ":"); idx >= 0 {
+			host = host[:idx]
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		m.From, to, subject, body)
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(msg))
+}
+
+// NewMailer returns cfg as the mailer when an SMTP address is set and a LogMailer otherwise
+func NewMailer(cfg SMTPMailer) Mailer {
+	if cfg.Addr == "" {
+		return LogMailer{}
+	}
+	return &cfg
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
+)
+
+// Organization errors
+var (
+	ErrOrganizationNotFound = NotFound("organization_not_found", "Organization not found")
+	ErrOrganizationExists   = Conflict("organization_exists", "Organization already exists")
+	ErrMembershipNotFound   = NotFound("membership_not_found", "User is not a member of this organization")
+	// ErrNotMember is returned when switching to an organization the user doesn't belong to
+	ErrNotMember = Forbidden("not_member", "Not a member of this organization")
+)
+
+// OrganizationService manages organizations and their members. Members are usually
+// managed through UserService within the organization; this service works across them.
+type OrganizationService struct {
+	db *gorm.DB
+}
+
+// NewOrganizationService creates a new OrganizationService
+func NewOrganizationService(db *gorm.DB) *OrganizationService {
+	return &OrganizationService{db: db}
+}
+
+// ListOrganizations returns the organizations username belongs to, or every organization
+// for super-admins and callers without a tenant
+func (s *OrganizationService) ListOrganizations(ctx context.Context, username string) ([]models.Organization, error) {
+	ctx, span := tracing.Start(ctx, "OrganizationService.ListOrganizations")
+	defer span.End()
+
+	query := s.db.WithContext(ctx).Order("name")
+	if tenant, ok := TenantFromContext(ctx); ok && !tenant.SuperAdmin {
+		query = query.Where("id IN (SELECT memberships.organization_id FROM memberships JOIN users ON users.id = memberships.user_id WHERE users.username = ?)", username)
+	}
+	var organizations []models.Organization
+	if err := query.Find(&organizations).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return organizations, nil
+}
+
+// GetOrganization retrieves an organization by its ID
+func (s *OrganizationService) GetOrganization(ctx context.Context, id uint) (*models.Organization, error) {
+	ctx, span := tracing.Start(ctx, "OrganizationService.GetOrganization", attribute.Int64("organization.id", int64(id)))
+	defer span.End()
+
+	var organization models.Organization
+	if err := s.db.WithContext(ctx).First(&organization, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, tracing.RecordError(span, err)
+	}
+	return &organization, nil
+}
+
+// GetOrganizationByName retrieves an organization by its name
+func (s *OrganizationService) GetOrganizationByName(ctx context.Context, name string) (*models.Organization, error) {
+	ctx, span := tracing.Start(ctx, "OrganizationService.GetOrganizationByName", attribute.String("organization.name", name))
+	defer span.End()
+
+	var organization models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(&organization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, tracing.RecordError(span, err)
+	}
+	return &organization, nil
+}
+
+// CreateOrganization creates a new organization
+func (s *OrganizationService) CreateOrganization(ctx context.Context, organization *models.Organization) error {
+	ctx, span := tracing.Start(ctx, "OrganizationService.CreateOrganization", attribute.String("organization.name", organization.Name))
+	defer span.End()
+
+	return tracing.RecordError(span, translateDBError(s.db.WithContext(ctx).Create(organization).Error, ErrOrganizationExists))
+}
+
+// EnsureOrganization creates the organization name unless it exists and reports whether it was created
+func (s *OrganizationService) EnsureOrganization(ctx context.Context, name string) (*models.Organization, bool, error) {
+	ctx, span := tracing.Start(ctx, "OrganizationService.EnsureOrganization", attribute.String("organization.name", name))
+	defer span.End()
+
+	organization := &models.Organization{}
+	result := s.db.WithContext(ctx).Where(models.Organization{Name: name}).FirstOrCreate(organization)
+	if result.Error != nil {
+		return nil, false, tracing.RecordError(span, result.Error)
+	}
+	return organization, result.RowsAffected > 0, nil
+}
+
+// SetMember adds a user to an organization with a role, or changes their role in it. The
+// role must be shared or owned by the organization.
+func (s *OrganizationService) SetMember(ctx context.Context, organizationID uint, req *models.MembershipRequest) (*models.Membership, error) {
+	ctx, span := tracing.Start(ctx, "OrganizationService.SetMember",
+		attribute.Int64("organization.id", int64(organizationID)), attribute.Int64("user.id", int64(req.UserID)))
+	defer span.End()
+
+	if _, err := s.GetOrganization(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	var exists int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", req.UserID).Count(&exists).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if exists == 0 {
+		return nil, ErrUserNotFound
+	}
+	role, err := NewRoleService(s.db).knownRole(WithTenant(ctx, Tenant{OrganizationID: organizationID}), "role_id", req.RoleID)
+	if err != nil {
+		return nil, err
+	}
+
+	membership := &models.Membership{UserID: req.UserID, OrganizationID: organizationID, RoleID: role.ID}
+	err = s.db.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "organization_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role_id"}),
+	}).Create(membership).Error
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	membership.Role = *role
+	return membership, nil
+}
+
+// RemoveMember removes a user from an organization. The account itself is kept.
+func (s *OrganizationService) RemoveMember(ctx context.Context, organizationID, userID uint) error {
+	ctx, span := tracing.Start(ctx, "OrganizationService.RemoveMember",
+		attribute.Int64("organization.id", int64(organizationID)), attribute.Int64("user.id", int64(userID)))
+	defer span.End()
+
+	result := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", organizationID, userID).Delete(&models.Membership{})
+	if result.Error != nil {
+		return tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMembershipNotFound
+	}
+	return nil
+}
+
+// membership returns the membership of userID in organizationID with its role
+func membership(ctx context.Context, db *gorm.DB, userID, organizationID uint) (*models.Membership, error) {
+	var m models.Membership
+	err := db.WithContext(ctx).Preload("Role").Where("user_id = ? AND organization_id = ?", userID, organizationID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMembershipNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
+)
+
+// ErrRoleEscalation is returned when a caller assigns a role granting permissions they
+// don't hold themselves
+var ErrRoleEscalation = Forbidden("role_escalation", "The role grants permissions you don't hold")
+
+// PermissionService computes effective permissions: the union of the permissions of a
+// user's own role and of the roles of their groups, each with the permissions it inherits
+// from its parent roles. Within an organization these are the
+// user's role in it and the organization's groups; outside organizations the account's role
+// and the groups without an organization.
+type PermissionService struct {
+	db *gorm.DB
+}
+
+// NewPermissionService creates a new PermissionService
+func NewPermissionService(db *gorm.DB) *PermissionService {
+	return &PermissionService{db: db}
+}
+
+// EffectivePermissions returns the permissions of the user userID with the roles and groups
+// granting each of them, ordered by permission
+func (s *PermissionService) EffectivePermissions(ctx context.Context, userID uint) (*models.EffectivePermissions, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.EffectivePermissions", attribute.Int64("user.id", int64(userID)))
+	defer span.End()
+
+	user, err := NewUserService(s.db).GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.sources(ctx, user)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	result := &models.EffectivePermissions{UserID: user.ID, Username: user.Username, Permissions: []models.EffectivePermission{}}
+	for _, source := range sources {
+		for _, permission := range source.permissions {
+			i := slices.IndexFunc(result.Permissions, func(p models.EffectivePermission) bool { return p.Permission == permission.Permission })
+			if i < 0 {
+				result.Permissions = append(result.Permissions, models.EffectivePermission{Permission: permission.Permission})
+				i = len(result.Permissions) - 1
+			}
+			origin := source.PermissionSource
+			if permission.RoleID != origin.RoleID {
+				origin.InheritedFrom = permission.Role
+			}
+			result.Permissions[i].Sources = append(result.Permissions[i].Sources, origin)
+		}
+	}
+	slices.SortFunc(result.Permissions, func(a, b models.EffectivePermission) int {
+		return cmp.Compare(a.Permission, b.Permission)
+	})
+	return result, nil
+}
+
+// Permissions returns the effective permissions of username in the organization of ctx. It
+// backs the permission check of the routes.
+func (s *PermissionService) Permissions(ctx context.Context, username string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.Permissions")
+	defer span.End()
+
+	user, err := NewUserService(s.db).GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.sources(ctx, user)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	var permissions []string
+	for _, source := range sources {
+		for _, permission := range source.permissions {
+			if !slices.Contains(permissions, permission.Permission) {
+				permissions = append(permissions, permission.Permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// permissionSource is a role granting permissions to a user, with its effective permissions
+type permissionSource struct {
+	models.PermissionSource
+	permissions []inheritedPermission
+}
+
+// sources returns the user's own role followed by the roles of their groups. user has been
+// loaded in ctx, so its role is the one of the active organization.
+func (s *PermissionService) sources(ctx context.Context, user *models.User) ([]permissionSource, error) {
+	var sources []permissionSource
+	if user.Role.ID != 0 {
+		permissions, err := effectiveRoles.permissions(ctx, s.db, user.Role.ID)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, permissionSource{
+			PermissionSource: models.PermissionSource{RoleID: user.Role.ID, Role: user.Role.Name},
+			permissions:      permissions,
+		})
+	}
+
+	query := s.db.WithContext(ctx).Preload("Roles").
+		Where("id IN (SELECT group_id FROM group_members WHERE user_id = ?)", user.ID).Order("name")
+	if orgID, scoped := organizationScope(ctx); scoped {
+		query = query.Where("organization_id = ?", orgID)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+	var groups []models.Group
+	if err := query.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		for _, role := range group.Roles {
+			permissions, err := effectiveRoles.permissions(ctx, s.db, role.ID)
+			if err != nil {
+				return nil, err
+			}
+			groupID := group.ID
+			sources = append(sources, permissionSource{
+				PermissionSource: models.PermissionSource{RoleID: role.ID, Role: role.Name, GroupID: &groupID, Group: group.Name},
+				permissions:      permissions,
+			})
+		}
+	}
+	return sources, nil
+}
+
+// checkGrantable returns ErrRoleEscalation unless the caller of ctx holds every effective
+// permission of the role roleID in the organization of ctx. Super-admins and contexts
+// without a tenant, i.e. the CLI and the bootstrap, may assign any role.
+func checkGrantable(ctx context.Context, db *gorm.DB, roleID uint) error {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok || tenant.SuperAdmin {
+		return nil
+	}
+	held, err := NewPermissionService(db).Permissions(ctx, tenant.Username)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+	granted, err := effectiveRoles.permissions(ctx, db, roleID)
+	if err != nil {
+		return err
+	}
+	for _, permission := range granted {
+		if !slices.Contains(held, permission.Permission) {
+			return ErrRoleEscalation
+		}
+	}
+	return nil
+}
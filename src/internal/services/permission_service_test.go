@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+// groupFixture is the organization acme with the group ops, whose role editor inherits
+// from viewer. carol and dave are viewers of acme and carol is in ops; owner and its
+// child heir grant users.delete, which neither holds.
+type groupFixture struct {
+	db                          *gorm.DB
+	acme                        *models.Organization
+	viewer, editor, owner, heir *models.Role
+	carol, dave                 *models.User
+	ops                         *models.Group
+	superAdmin                  context.Context
+}
+
+func newGroupFixture(t *testing.T) *groupFixture {
+	t.Helper()
+	db := dbtest.New(t)
+	f := &groupFixture{db: db}
+	f.acme = dbtest.CreateOrganization(t, db, "acme")
+	f.viewer = dbtest.CreateRole(t, db, "viewer", 0, "users.read")
+	f.editor = dbtest.CreateRole(t, db, "editor", f.acme.ID, "users.update", "groups.update")
+	f.owner = dbtest.CreateRole(t, db, "owner", f.acme.ID, "users.delete")
+	f.heir = dbtest.CreateRole(t, db, "heir", f.acme.ID)
+	db.Model(f.editor).Update("parent_ids", pq.Int64Array{int64(f.viewer.ID)})
+	db.Model(f.heir).Update("parent_ids", pq.Int64Array{int64(f.owner.ID)})
+	f.carol = dbtest.CreateUser(t, db, "carol", f.viewer.ID)
+	f.dave = dbtest.CreateUser(t, db, "dave", f.viewer.ID)
+	dbtest.AddMember(t, db, f.acme, f.carol, f.viewer.ID)
+	dbtest.AddMember(t, db, f.acme, f.dave, f.viewer.ID)
+	// The role cache is shared by the tests, whose databases reuse role ids
+	effectiveRoles.invalidate()
+
+	f.superAdmin = WithTenant(context.Background(), Tenant{OrganizationID: f.acme.ID, Username: "root", SuperAdmin: true})
+	groups := NewGroupService(db)
+	f.ops = &models.Group{Name: "ops"}
+	if err := groups.CreateGroup(f.superAdmin, f.ops); err != nil {
+		t.Fatal(err)
+	}
+	if err := groups.AddMember(f.superAdmin, f.ops.ID, f.carol.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groups.AddRole(f.superAdmin, f.ops.ID, f.editor.ID); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// as returns the context of username acting in acme
+func (f *groupFixture) as(username string) context.Context {
+	return WithTenant(context.Background(), Tenant{OrganizationID: f.acme.ID, Username: username})
+}
+
+func sortedPermissions(t *testing.T, ctx context.Context, db *gorm.DB, username string) []string {
+	t.Helper()
+	permissions, err := NewPermissionService(db).Permissions(ctx, username)
+	if err != nil {
+		t.Fatalf("Permissions(%s): %v", username, err)
+	}
+	slices.Sort(permissions)
+	return permissions
+}
+
+func TestPermissionsThroughGroups(t *testing.T) {
+	f := newGroupFixture(t)
+	groups := NewGroupService(f.db)
+	ctx := f.as("carol")
+
+	if got, want := sortedPermissions(t, ctx, f.db, "carol"), []string{"groups.update", "users.read", "users.update"}; !slices.Equal(got, want) {
+		t.Errorf("carol in acme = %v, want %v", got, want)
+	}
+	if got, want := sortedPermissions(t, ctx, f.db, "dave"), []string{"users.read"}; !slices.Equal(got, want) {
+		t.Errorf("dave in acme = %v, want %v", got, want)
+	}
+
+	effective, err := NewPermissionService(f.db).EffectivePermissions(ctx, f.carol.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range effective.Permissions {
+		if p.Permission != "users.read" {
+			continue
+		}
+		// Once through her own role, once through ops' role inheriting it
+		if len(p.Sources) != 2 || p.Sources[0].GroupID != nil ||
+			p.Sources[1].Group != "ops" || p.Sources[1].Role != "editor" || p.Sources[1].InheritedFrom != "viewer" {
+			t.Errorf("users.read sources = %+v", p.Sources)
+		}
+	}
+
+	// The groups of acme don't apply outside it, nor those outside organizations within it
+	auditor := dbtest.CreateRole(t, f.db, "auditor", 0, "health.read")
+	effectiveRoles.invalidate()
+	staff := &models.Group{Name: "staff"}
+	groups.CreateGroup(context.Background(), staff)
+	groups.AddMember(context.Background(), staff.ID, f.carol.ID)
+	if _, err := groups.AddRole(context.Background(), staff.ID, auditor.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedPermissions(t, context.Background(), f.db, "carol"), []string{"health.read", "users.read"}; !slices.Equal(got, want) {
+		t.Errorf("carol outside organizations = %v, want %v", got, want)
+	}
+	if got, want := sortedPermissions(t, ctx, f.db, "carol"), []string{"groups.update", "users.read", "users.update"}; !slices.Equal(got, want) {
+		t.Errorf("carol in acme with staff = %v, want %v", got, want)
+	}
+
+	// Leaving the group or deleting it takes its roles away
+	if err := groups.RemoveMember(f.superAdmin, f.ops.ID, f.carol.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedPermissions(t, ctx, f.db, "carol"), []string{"users.read"}; !slices.Equal(got, want) {
+		t.Errorf("carol after leaving ops = %v, want %v", got, want)
+	}
+	groups.AddMember(f.superAdmin, f.ops.ID, f.dave.ID)
+	if got, want := sortedPermissions(t, ctx, f.db, "dave"), []string{"groups.update", "users.read", "users.update"}; !slices.Equal(got, want) {
+		t.Errorf("dave after joining ops = %v, want %v", got, want)
+	}
+	if err := groups.DeleteGroup(f.superAdmin, f.ops.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedPermissions(t, ctx, f.db, "dave"), []string{"users.read"}; !slices.Equal(got, want) {
+		t.Errorf("dave after deleting ops = %v, want %v", got, want)
+	}
+}
+
+// Callers can only hand out permissions they hold, including those of their groups
+func TestRoleEscalation(t *testing.T) {
+	f := newGroupFixture(t)
+	groups := NewGroupService(f.db)
+	users := NewUserService(f.db)
+	carol := f.as("carol")
+
+	support := &models.Group{Name: "support"}
+	if err := groups.CreateGroup(carol, support); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		role *models.Role
+		want error
+	}{
+		{name: "role of her own", role: f.viewer},
+		{name: "role of her group", role: f.editor},
+		{name: "permission she lacks", role: f.owner, want: ErrRoleEscalation},
+		{name: "inherited permission she lacks", role: f.heir, want: ErrRoleEscalation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := groups.AddRole(carol, support.ID, tt.role.ID); !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("AddRole(%s) = %v, want %v", tt.role.Name, err, tt.want)
+			}
+			update := &models.UserUpdateRequest{Email: "dave@example.com", Username: "dave", RoleID: tt.role.ID}
+			if _, err := users.UpdateUser(carol, f.dave.ID, update); !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("UpdateUser(dave, %s) = %v, want %v", tt.role.Name, err, tt.want)
+			}
+		})
+	}
+	var dave models.Membership
+	f.db.Where("user_id = ? AND organization_id = ?", f.dave.ID, f.acme.ID).First(&dave)
+	if dave.RoleID != f.editor.ID {
+		t.Errorf("dave's role in acme = %d, want editor %d, the last role carol could grant", dave.RoleID, f.editor.ID)
+	}
+
+	create := &models.UserCreateRequest{Username: "erin", Email: "erin@example.com", Password: "password", RoleID: f.owner.ID}
+	if _, err := users.CreateUser(carol, create); !errors.Is(err, ErrRoleEscalation) {
+		t.Errorf("CreateUser with owner = %v, want %v", err, ErrRoleEscalation)
+	}
+
+	// Without ops carol no longer holds editor's permissions
+	groups.RemoveMember(f.superAdmin, f.ops.ID, f.carol.ID)
+	qa := &models.Group{Name: "qa"}
+	groups.CreateGroup(carol, qa)
+	if _, err := groups.AddRole(carol, qa.ID, f.editor.ID); !errors.Is(err, ErrRoleEscalation) {
+		t.Errorf("AddRole(editor) after leaving ops = %v, want %v", err, ErrRoleEscalation)
+	}
+
+	// Super-admins may grant any role
+	if _, err := groups.AddRole(f.superAdmin, qa.ID, f.heir.ID); err != nil {
+		t.Errorf("AddRole(heir) by a super-admin: %v", err)
+	}
+	if _, err := users.UpdateUser(f.superAdmin, f.dave.ID, &models.UserUpdateRequest{Email: "dave@example.com", Username: "dave", RoleID: f.owner.ID}); err != nil {
+		t.Errorf("UpdateUser(dave, owner) by a super-admin: %v", err)
+	}
+}
@@ -1,49 +1,159 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"golang.org/x/crypto/bcrypt"
-	"go_api/internal/models"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
+)
+
+// Registration modes
+const (
+	// RegistrationOpen lets anyone register
+	RegistrationOpen = "open"
+	// RegistrationInvite requires an invitation
+	RegistrationInvite = "invite"
+	// RegistrationDomain lets addresses of the allowed domains register, others need an invitation
+	RegistrationDomain = "domain"
+	// RegistrationClosed turns registration off, invitations included
+	RegistrationClosed = "closed"
+)
+
+// Registration errors
+var (
+	ErrRegistrationClosed    = Forbidden("registration_closed", "Registration is closed")
+	ErrInvitationRequired    = Forbidden("invitation_required", "Registration requires an invitation")
+	ErrEmailDomainNotAllowed = Forbidden("email_domain_not_allowed", "Registration is not open to this email domain")
 )
 
 type RegisterService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	defaultRole    string
+	mode           string
+	allowedDomains []string
+	organization   string
 }
 
-func NewRegisterService(db *gorm.DB) *RegisterService {
-	return &RegisterService{db: db}
+// RegistrationConfig configures self-registration
+type RegistrationConfig struct {
+	DefaultRole    string   // role given to registered users without an invitation
+	Mode           string   // RegistrationOpen, RegistrationInvite, RegistrationDomain or RegistrationClosed
+	AllowedDomains []string // lower-case email domains of RegistrationDomain
+	Organization   string   // organization users join unless their invitation names one
 }
 
-func (s *RegisterService) RegisterUser(req *models.UserCreateRequest) (*models.User, error) {
-	// Check if username or email already exists
-	var existingUser models.User
-	if err := s.db.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
-		return nil, errors.New("username or email already exists")
-	} else if err != gorm.ErrRecordNotFound {
-		return nil, err
-	}
+func NewRegisterService(db *gorm.DB, cfg RegistrationConfig) *RegisterService {
+	return &RegisterService{db: db, defaultRole: cfg.DefaultRole, mode: cfg.Mode, allowedDomains: cfg.AllowedDomains, organization: cfg.Organization}
+}
 
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
+// RegisterUser creates a local user. Callers can't choose their role: users invited get the
+// role of their invitation, everyone else the default role, as far as the mode lets them in.
+// They join the organization of their invitation, or the registration organization.
+func (s *RegisterService) RegisterUser(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "RegisterService.RegisterUser", attribute.String("registration.mode", s.mode))
+	defer span.End()
+
+	if err := s.admit(req); err != nil {
 		return nil, err
 	}
 
-	user := &models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		First:    req.First,
-		Last:     req.Last,
-		Phone:    req.Phone,
-		Password: string(hashedPassword),
-		RoleID:   req.RoleID, // you may want to default to a role here, e.g. "guest" role id
+	// Check if username or email already exists
+	var existingUser models.User
+	if err := s.db.WithContext(ctx).Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, tracing.RecordError(span, err)
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
+	var user *models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation *models.Invitation
+		var role *models.Role
+		var err error
+		if req.InviteToken != "" {
+			if invitation, err = redeemInvitation(tx, req.InviteToken, req.Email); err != nil {
+				return err
+			}
+			role, err = NewRoleService(tx).GetRoleByID(ctx, invitation.RoleID)
+		} else {
+			role, err = NewRoleService(tx).GetRoleByName(ctx, s.defaultRole)
+			if errors.Is(err, ErrRoleNotFound) {
+				return fmt.Errorf("default role %q does not exist", s.defaultRole)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		user = &models.User{
+			Username: req.Username,
+			Email:    req.Email,
+			First:    req.First,
+			Last:     req.Last,
+			Phone:    req.Phone,
+			Password: req.Password, // hashed by the BeforeCreate hook
+			RoleID:   role.ID,
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return translateDBError(err, ErrUserExists)
+		}
+		user.Role = *role
+
+		var organizationID uint
+		if invitation != nil && invitation.OrganizationID != nil {
+			organizationID = *invitation.OrganizationID
+		} else if s.organization != "" {
+			organization, err := NewOrganizationService(tx).GetOrganizationByName(ctx, s.organization)
+			if errors.Is(err, ErrOrganizationNotFound) {
+				return fmt.Errorf("registration organization %q does not exist", s.organization)
+			}
+			if err != nil {
+				return err
+			}
+			organizationID = organization.ID
+		}
+		if organizationID != 0 {
+			if err := tx.Create(&models.Membership{UserID: user.ID, OrganizationID: organizationID, RoleID: role.ID}).Error; err != nil {
+				return err
+			}
+		}
+
+		if invitation != nil {
+			return acceptInvitation(tx, invitation, user.ID)
+		}
+		return nil
+	})
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
 		return nil, err
 	}
-
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
 	return user, nil
 }
 
+// admit applies the registration mode. Invitations are checked when the user is created.
+func (s *RegisterService) admit(req *models.RegisterRequest) error {
+	switch s.mode {
+	case RegistrationClosed:
+		return ErrRegistrationClosed
+	case RegistrationInvite:
+		if req.InviteToken == "" {
+			return ErrInvitationRequired
+		}
+	case RegistrationDomain:
+		_, domain, _ := strings.Cut(strings.ToLower(req.Email), "@")
+		if req.InviteToken == "" && !slices.Contains(s.allowedDomains, domain) {
+			return ErrEmailDomainNotAllowed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+// Registered users get the default role and join the registration organization, whatever
+// the role they ask for
+func TestRegisterDefaultRole(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	dbtest.CreateRole(t, db, "admin", 0, "users.delete")
+	acme := dbtest.CreateOrganization(t, db, "acme")
+
+	service := NewRegisterService(db, RegistrationConfig{DefaultRole: "guest", Mode: RegistrationOpen, Organization: "acme"})
+	user, err := service.RegisterUser(context.Background(), &models.RegisterRequest{Username: "erin", Email: "erin@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	if user.RoleID != guest.ID || user.Role.Name != "guest" {
+		t.Errorf("role = %d %q, want guest %d", user.RoleID, user.Role.Name, guest.ID)
+	}
+	var membership models.Membership
+	if err := db.Where("user_id = ? AND organization_id = ?", user.ID, acme.ID).First(&membership).Error; err != nil || membership.RoleID != guest.ID {
+		t.Errorf("membership in acme = %+v, %v, want role guest", membership, err)
+	}
+
+	if _, err := service.RegisterUser(context.Background(), &models.RegisterRequest{Username: "erin", Email: "other@example.com", Password: "password"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("registering erin twice = %v, want %v", err, ErrUserExists)
+	}
+
+	// A missing default role is a configuration error, not the caller's
+	service = NewRegisterService(db, RegistrationConfig{DefaultRole: "member", Mode: RegistrationOpen})
+	_, err = service.RegisterUser(context.Background(), &models.RegisterRequest{Username: "frank", Email: "frank@example.com", Password: "password"})
+	var domainErr *Error
+	if err == nil || errors.As(err, &domainErr) {
+		t.Errorf("registering without the default role = %v, want an internal error", err)
+	}
+}
+
+func TestRegistrationModes(t *testing.T) {
+	db := dbtest.New(t)
+	dbtest.CreateRole(t, db, "guest", 0)
+	tests := []struct {
+		mode  string
+		email string
+		want  error
+	}{
+		{mode: RegistrationOpen, email: "a@example.com"},
+		{mode: RegistrationInvite, email: "b@example.com", want: ErrInvitationRequired},
+		{mode: RegistrationDomain, email: "c@Example.com"},
+		{mode: RegistrationDomain, email: "d@elsewhere.com", want: ErrEmailDomainNotAllowed},
+		{mode: RegistrationClosed, email: "e@example.com", want: ErrRegistrationClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.email, func(t *testing.T) {
+			service := NewRegisterService(db, RegistrationConfig{DefaultRole: "guest", Mode: tt.mode, AllowedDomains: []string{"example.com"}})
+			_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{Username: tt.email[:1] + "user", Email: tt.email, Password: "password"})
+			if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("RegisterUser = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
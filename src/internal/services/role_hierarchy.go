@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+)
+
+// Role hierarchy errors
+var (
+	ErrRoleCycle          = Validation("role_cycle", "A role can't inherit from itself, directly or through its parents")
+	ErrParentRoleNotFound = Validation("parent_role_not_found", "Parent role not found")
+)
+
+// roleHierarchyLockKey is the Postgres advisory lock held while parent roles change, so
+// two concurrent updates can't close a cycle between them
+const roleHierarchyLockKey int64 = 0x726f6c6570 // "rolep"
+
+// roleCacheTTL bounds how long the effective permissions of roles are cached. Changes made
+// through RoleService clear the cache at once; other instances see them after the TTL.
+const roleCacheTTL = 30 * time.Second
+
+// inheritedPermission is a permission of a role's effective set and the role declaring it
+type inheritedPermission struct {
+	Permission string
+	RoleID     uint
+	Role       string
+}
+
+// roleNode is a role as far as the hierarchy is concerned
+type roleNode struct {
+	Name        string
+	Permissions []string
+	Parents     []uint
+}
+
+// roleCache holds the effective permissions of every role, computed from one query of the
+// roles table
+type roleCache struct {
+	mu        sync.Mutex
+	effective map[uint][]inheritedPermission
+	loadedAt  time.Time
+}
+
+var effectiveRoles = &roleCache{}
+
+// permissions returns the effective permissions of roleID: its own followed by those it
+// inherits, each once, with the nearest role declaring it
+func (c *roleCache) permissions(ctx context.Context, db *gorm.DB, roleID uint) ([]inheritedPermission, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.effective == nil || time.Since(c.loadedAt) > roleCacheTTL {
+		nodes, err := loadRoleNodes(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		c.effective = make(map[uint][]inheritedPermission, len(nodes))
+		for id := range nodes {
+			c.effective[id] = resolvePermissions(nodes, id)
+		}
+		c.loadedAt = time.Now()
+	}
+	return c.effective[roleID], nil
+}
+
+// invalidate makes the next lookup reload the roles
+func (c *roleCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.effective = nil
+}
+
+// loadRoleNodes reads the hierarchy of every role
+func loadRoleNodes(ctx context.Context, db *gorm.DB) (map[uint]roleNode, error) {
+	var roles []models.Role
+	if err := db.WithContext(ctx).Select("id", "name", "permissions", "parent_ids").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	nodes := make(map[uint]roleNode, len(roles))
+	for _, role := range roles {
+		nodes[role.ID] = roleNode{Name: role.Name, Permissions: role.Permissions, Parents: parentIDs(role.ParentIDs)}
+	}
+	return nodes, nil
+}
+
+// resolvePermissions walks the hierarchy breadth first from id, so a permission is
+// attributed to the nearest role declaring it. Roles are visited once, which also stops
+// at cycles created outside RoleService.
+func resolvePermissions(nodes map[uint]roleNode, id uint) []inheritedPermission {
+	var result []inheritedPermission
+	visited := map[uint]bool{id: true}
+	queue := []uint{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		node, ok := nodes[current]
+		if !ok {
+			continue
+		}
+		for _, permission := range node.Permissions {
+			if !slices.ContainsFunc(result, func(p inheritedPermission) bool { return p.Permission == permission }) {
+				result = append(result, inheritedPermission{Permission: permission, RoleID: current, Role: node.Name})
+			}
+		}
+		for _, parent := range node.Parents {
+			if !visited[parent] {
+				visited[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return result
+}
+
+// checkParents verifies within tx, which must hold roleHierarchyLockKey, that role may
+// inherit from its parents: they exist and are visible where the role lives, or the
+// parent_ids entry fails the known_role rule, and don't inherit from the role themselves.
+// role.ID is 0 for a role being created.
+func checkParents(ctx context.Context, tx *gorm.DB, role *models.Role) error {
+	if len(role.ParentIDs) == 0 {
+		return nil
+	}
+	nodes, err := loadRoleNodes(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	var parents []models.Role
+	if err := tx.WithContext(ctx).Where("id IN ?", parentIDs(role.ParentIDs)).Find(&parents).Error; err != nil {
+		return err
+	}
+	for n, parentID := range role.ParentIDs {
+		id := uint(parentID)
+		i := slices.IndexFunc(parents, func(parent models.Role) bool { return parent.ID == id })
+		if i < 0 {
+			return unknownRole(fmt.Sprintf("parent_ids[%d]", n))
+		}
+		// Shared roles only inherit from shared roles, others also from their organization's
+		if owner := parents[i].OrganizationID; owner != nil && (role.OrganizationID == nil || *owner != *role.OrganizationID) {
+			return unknownRole(fmt.Sprintf("parent_ids[%d]", n))
+		}
+		if role.ID != 0 && reaches(nodes, id, role.ID) {
+			return ErrRoleCycle
+		}
+	}
+	return nil
+}
+
+// reaches reports whether target is from or one of its ancestors
+func reaches(nodes map[uint]roleNode, from, target uint) bool {
+	visited := map[uint]bool{}
+	stack := []uint{from}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == target {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, nodes[current].Parents...)
+	}
+	return false
+}
+
+// parentIDs converts the parent_ids column to role IDs, dropping duplicates
+func parentIDs(ids []int64) []uint {
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(result, uint(id)) {
+			result = append(result, uint(id))
+		}
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+// testHierarchy is
+//
+//	1 admin  -> 2 editor -> 3 viewer
+//	         -> 4 auditor -> 3 viewer
+//	5 orphan -> 99 (deleted)
+var testHierarchy = map[uint]roleNode{
+	1: {Name: "admin", Permissions: []string{"users.delete"}, Parents: []uint{2, 4}},
+	2: {Name: "editor", Permissions: []string{"users.update", "users.read"}, Parents: []uint{3}},
+	3: {Name: "viewer", Permissions: []string{"users.read", "roles.read"}},
+	4: {Name: "auditor", Permissions: []string{"roles.read", "health.read"}, Parents: []uint{3}},
+	5: {Name: "orphan", Permissions: []string{"groups.read"}, Parents: []uint{99}},
+}
+
+func TestReaches(t *testing.T) {
+	tests := []struct {
+		name         string
+		from, target uint
+		want         bool
+	}{
+		{name: "self", from: 2, target: 2, want: true},
+		{name: "direct parent", from: 1, target: 2, want: true},
+		{name: "transitive parent", from: 1, target: 3, want: true},
+		{name: "child", from: 3, target: 1, want: false},
+		{name: "sibling", from: 2, target: 4, want: false},
+		{name: "missing parent", from: 5, target: 1, want: false},
+	}
+	for _, tt := range tests {
+		if got := reaches(testHierarchy, tt.from, tt.target); got != tt.want {
+			t.Errorf("%s: reaches(%d, %d) = %v, want %v", tt.name, tt.from, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePermissions(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes map[uint]roleNode
+		id    uint
+		want  []inheritedPermission
+	}{
+		{
+			name:  "own permissions first, then the nearest role declaring each",
+			nodes: testHierarchy,
+			id:    1,
+			want: []inheritedPermission{
+				{Permission: "users.delete", RoleID: 1, Role: "admin"},
+				{Permission: "users.update", RoleID: 2, Role: "editor"},
+				{Permission: "users.read", RoleID: 2, Role: "editor"},
+				{Permission: "roles.read", RoleID: 4, Role: "auditor"},
+				{Permission: "health.read", RoleID: 4, Role: "auditor"},
+			},
+		},
+		{
+			name:  "without parents",
+			nodes: testHierarchy,
+			id:    3,
+			want: []inheritedPermission{
+				{Permission: "users.read", RoleID: 3, Role: "viewer"},
+				{Permission: "roles.read", RoleID: 3, Role: "viewer"},
+			},
+		},
+		{
+			name:  "missing parent is skipped",
+			nodes: testHierarchy,
+			id:    5,
+			want:  []inheritedPermission{{Permission: "groups.read", RoleID: 5, Role: "orphan"}},
+		},
+		{
+			name:  "unknown role",
+			nodes: testHierarchy,
+			id:    42,
+		},
+		{
+			name: "cycle written outside RoleService",
+			nodes: map[uint]roleNode{
+				1: {Name: "a", Permissions: []string{"users.read"}, Parents: []uint{2}},
+				2: {Name: "b", Permissions: []string{"roles.read"}, Parents: []uint{1}},
+			},
+			id: 2,
+			want: []inheritedPermission{
+				{Permission: "roles.read", RoleID: 2, Role: "b"},
+				{Permission: "users.read", RoleID: 1, Role: "a"},
+			},
+		},
+		{
+			name:  "role inheriting from itself",
+			nodes: map[uint]roleNode{1: {Name: "a", Permissions: []string{"users.read"}, Parents: []uint{1}}},
+			id:    1,
+			want:  []inheritedPermission{{Permission: "users.read", RoleID: 1, Role: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := resolvePermissions(tt.nodes, tt.id); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckParents(t *testing.T) {
+	db := dbtest.New(t)
+	acme := dbtest.CreateOrganization(t, db, "acme")
+	globex := dbtest.CreateOrganization(t, db, "globex")
+	viewer := dbtest.CreateRole(t, db, "viewer", 0, "users.read")
+	editor := dbtest.CreateRole(t, db, "editor", 0, "users.update")
+	admin := dbtest.CreateRole(t, db, "admin", 0, "users.delete")
+	acmeRole := dbtest.CreateRole(t, db, "acme-support", acme.ID, "groups.read")
+	globexRole := dbtest.CreateRole(t, db, "globex-support", globex.ID, "groups.read")
+	// viewer <- editor <- admin
+	db.Model(editor).Update("parent_ids", pq.Int64Array{int64(viewer.ID)})
+	db.Model(admin).Update("parent_ids", pq.Int64Array{int64(editor.ID)})
+
+	parents := func(roles ...*models.Role) pq.Int64Array {
+		ids := pq.Int64Array{}
+		for _, role := range roles {
+			ids = append(ids, int64(role.ID))
+		}
+		return ids
+	}
+	role := func(id uint, orgID *uint, parentIDs pq.Int64Array) *models.Role {
+		return &models.Role{ID: id, OrganizationID: orgID, ParentIDs: parentIDs}
+	}
+
+	tests := []struct {
+		name string
+		role *models.Role
+		want error
+	}{
+		{name: "no parents", role: role(viewer.ID, nil, nil)},
+		{name: "new role", role: role(0, nil, parents(admin, viewer))},
+		{name: "self", role: role(viewer.ID, nil, parents(viewer)), want: ErrRoleCycle},
+		{name: "direct cycle", role: role(editor.ID, nil, parents(admin)), want: ErrRoleCycle},
+		{name: "transitive cycle", role: role(viewer.ID, nil, parents(admin)), want: ErrRoleCycle},
+		{name: "unknown parent", role: role(0, nil, pq.Int64Array{999}), want: unknownRole("parent_ids[0]")},
+		{name: "organization role inheriting a shared role", role: role(0, &acme.ID, parents(viewer, acmeRole))},
+		{name: "shared role inheriting an organization role", role: role(0, nil, parents(viewer, acmeRole)), want: unknownRole("parent_ids[1]")},
+		{name: "role of another organization", role: role(0, &acme.ID, parents(globexRole)), want: unknownRole("parent_ids[0]")},
+	}
+	for _, tt := range tests {
+		if err := checkParents(context.Background(), db, tt.role); !sameError(err, tt.want) {
+			t.Errorf("%s: checkParents = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// sameError reports whether err is want, comparing the failed rules of validation errors
+func sameError(err, want error) bool {
+	if want == nil || err == nil {
+		return err == want
+	}
+	var got, wanted *Error
+	if errors.As(err, &got) && errors.As(want, &wanted) && !reflect.DeepEqual(got.Fields, wanted.Fields) {
+		return false
+	}
+	return errors.Is(err, want)
+}
+
+func TestRoleCache(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	viewer := dbtest.CreateRole(t, db, "viewer", 0, "users.read")
+	editor := dbtest.CreateRole(t, db, "editor", 0, "users.update")
+	db.Model(editor).Update("parent_ids", pq.Int64Array{int64(viewer.ID)})
+	cache := &roleCache{}
+
+	names := func(roleID uint) []string {
+		t.Helper()
+		permissions, err := cache.permissions(ctx, db, roleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, p := range permissions {
+			names = append(names, p.Permission)
+		}
+		return names
+	}
+
+	if got := names(editor.ID); !reflect.DeepEqual(got, []string{"users.update", "users.read"}) {
+		t.Fatalf("editor = %v", got)
+	}
+
+	// A change made behind the cache's back is only seen after invalidate or the TTL
+	db.Model(viewer).Update("permissions", pq.StringArray{"users.read", "roles.read"})
+	if got := names(editor.ID); !reflect.DeepEqual(got, []string{"users.update", "users.read"}) {
+		t.Errorf("editor before invalidate = %v, want the cached permissions", got)
+	}
+	cache.invalidate()
+	if got := names(editor.ID); !reflect.DeepEqual(got, []string{"users.update", "users.read", "roles.read"}) {
+		t.Errorf("editor after invalidate = %v", got)
+	}
+
+	db.Model(editor).Update("parent_ids", pq.Int64Array{})
+	cache.loadedAt = cache.loadedAt.Add(-roleCacheTTL - time.Second)
+	if got := names(editor.ID); !reflect.DeepEqual(got, []string{"users.update"}) {
+		t.Errorf("editor after the TTL = %v", got)
+	}
+
+	// Roles created after the load are found once the cache is cleared
+	auditor := dbtest.CreateRole(t, db, "auditor", 0, "health.read")
+	if got := names(auditor.ID); got != nil {
+		t.Errorf("auditor before invalidate = %v, want nothing", got)
+	}
+	cache.invalidate()
+	if got := names(auditor.ID); !reflect.DeepEqual(got, []string{"health.read"}) {
+		t.Errorf("auditor after invalidate = %v", got)
+	}
+}
+
+// RoleService clears the shared cache, so a grant applies to the next request
+// instead of after the TTL
+func TestRoleServiceInvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	viewer := dbtest.CreateRole(t, db, "viewer", 0, "users.read")
+	editor := dbtest.CreateRole(t, db, "editor", 0, "users.update")
+	db.Model(editor).Update("parent_ids", pq.Int64Array{int64(viewer.ID)})
+	dbtest.CreateUser(t, db, "alice", editor.ID)
+	permissions := NewPermissionService(db)
+	effectiveRoles.invalidate()
+
+	if got, err := permissions.Permissions(ctx, "alice"); err != nil || !reflect.DeepEqual(got, []string{"users.update", "users.read"}) {
+		t.Fatalf("Permissions = %v, %v", got, err)
+	}
+	if _, err := NewRoleService(db).GrantPermissions(ctx, "viewer", "roles.read"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := permissions.Permissions(ctx, "alice"); !reflect.DeepEqual(got, []string{"users.update", "users.read", "roles.read"}) {
+		t.Errorf("Permissions after a grant to a parent = %v", got)
+	}
+}
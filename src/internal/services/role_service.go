@@ -1,12 +1,24 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"slices"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
-	"go_api/internal/models"
+	"gorm.io/gorm/clause"
+	"github.com/lib/pq"
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
 )
 
+// ErrSharedRole is returned when a role shared by every organization is changed from within one
+var ErrSharedRole = Forbidden("shared_role", "Shared roles can only be changed by a super-admin")
+
+// RoleService manages roles. Within an organization it sees the shared roles and the
+// organization's own, and only changes the latter.
 type RoleService struct {
 	db *gorm.DB
 }
@@ -16,40 +28,301 @@ func NewRoleService(db *gorm.DB) *RoleService {
 }
 
 // GetAllRoles retrieves all roles from the database
-func (s *RoleService) GetAllRoles() ([]models.Role, error) {
+func (s *RoleService) GetAllRoles(ctx context.Context) ([]models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.GetAllRoles")
+	defer span.End()
+
 	var roles []models.Role
-	if err := s.db.Find(&roles).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Scopes(scopeRoles(ctx)).Find(&roles).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 	return roles, nil
 }
 
 // GetRoleByID retrieves a role by its ID from the database
-func (s *RoleService) GetRoleByID(id uint) (*models.Role, error) {
+func (s *RoleService) GetRoleByID(ctx context.Context, id uint) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.GetRoleByID", attribute.Int64("role.id", int64(id)))
+	defer span.End()
+
 	var role models.Role
-	if err := s.db.First(&role, id).Error; err != nil {
+	if err := s.db.WithContext(ctx).Scopes(scopeRoles(ctx)).First(&role, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+			return nil, ErrRoleNotFound
 		}
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 	return &role, nil
 }
 
+// knownRole returns the role id named by the request field field, or the known_role
+// validation error when no such role is visible in the organization of ctx
+func (s *RoleService) knownRole(ctx context.Context, field string, id uint) (*models.Role, error) {
+	role, err := s.GetRoleByID(ctx, id)
+	if errors.Is(err, ErrRoleNotFound) {
+		return nil, unknownRole(field)
+	}
+	return role, err
+}
+
 // GetRoleByName retrieves a role by its name from the database
-func (s *RoleService) GetRoleByName(name string) (*models.Role, error) {
+func (s *RoleService) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.GetRoleByName", attribute.String("role.name", name))
+	defer span.End()
+
 	var role models.Role
-	if err := s.db.Where("name = ?", name).First(&role).Error; err != nil {
+	if err := s.db.WithContext(ctx).Scopes(scopeRoleName(ctx, name)).First(&role).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+			return nil, ErrRoleNotFound
+		}
+		return nil, tracing.RecordError(span, err)
+	}
+	return &role, nil
+}
+
+// CreateRole creates a new role in the database, owned by the organization of ctx if any.
+// Names must not clash with the roles visible there, shared roles included, and parent
+// roles must be visible there too.
+func (s *RoleService) CreateRole(ctx context.Context, role *models.Role) error {
+	ctx, span := tracing.Start(ctx, "RoleService.CreateRole", attribute.String("role.name", role.Name))
+	defer span.End()
+
+	role.OrganizationID = nil
+	if orgID, scoped := organizationScope(ctx); scoped {
+		role.OrganizationID = &orgID
+	}
+	err := s.hierarchy(ctx, func(tx *gorm.DB) error {
+		var clashes int64
+		if err := tx.Model(&models.Role{}).Scopes(scopeRoles(ctx)).Where("name = ?", role.Name).Count(&clashes).Error; err != nil {
+			return err
+		}
+		if clashes > 0 {
+			return ErrRoleExists
+		}
+		if err := checkParents(ctx, tx, role); err != nil {
+			return err
+		}
+		return translateDBError(tx.Create(role).Error, ErrRoleExists)
+	})
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+	return tracing.RecordError(span, err)
+}
+
+// UpdateRole replaces the permissions and parent roles of a role. A role can't become its
+// own ancestor.
+func (s *RoleService) UpdateRole(ctx context.Context, id uint, req *models.RoleUpdateRequest) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.UpdateRole", attribute.Int64("role.id", int64(id)))
+	defer span.End()
+
+	var role models.Role
+	err := s.hierarchy(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(scopeRoles(ctx)).First(&role, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if err := checkWritable(ctx, &role); err != nil {
+			return err
+		}
+
+		role.Permissions = append(pq.StringArray{}, req.Permissions...)
+		role.ParentIDs = pq.Int64Array{}
+		for _, parentID := range req.ParentIDs {
+			role.ParentIDs = append(role.ParentIDs, int64(parentID))
 		}
+		if err := checkParents(ctx, tx, &role); err != nil {
+			return err
+		}
+		return tx.Model(&role).Updates(map[string]interface{}{"permissions": role.Permissions, "parent_ids": role.ParentIDs}).Error
+	})
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
 		return nil, err
 	}
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
 	return &role, nil
 }
 
-// CreateRole creates a new role in the database
-func (s *RoleService) CreateRole(role *models.Role) error {
-	return s.db.Create(role).Error
+// AddParents makes a role inherit from the roles named parents, keeping the parents it has
+func (s *RoleService) AddParents(ctx context.Context, name string, parents ...string) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.AddParents", attribute.String("role.name", name))
+	defer span.End()
+
+	var role models.Role
+	err := s.hierarchy(ctx, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(scopeRoleName(ctx, name)).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if err := checkWritable(ctx, &role); err != nil {
+			return err
+		}
+
+		for _, parentName := range parents {
+			var parent models.Role
+			if err := tx.Scopes(scopeRoleName(ctx, parentName)).First(&parent).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrParentRoleNotFound
+				}
+				return err
+			}
+			if !slices.Contains(role.ParentIDs, int64(parent.ID)) {
+				role.ParentIDs = append(role.ParentIDs, int64(parent.ID))
+			}
+		}
+		if err := checkParents(ctx, tx, &role); err != nil {
+			return err
+		}
+		return tx.Model(&role).Update("parent_ids", role.ParentIDs).Error
+	})
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return &role, nil
 }
 
+// GrantPermissions adds permissions to a role, keeping the ones it already has
+func (s *RoleService) GrantPermissions(ctx context.Context, name string, permissions ...string) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.GrantPermissions", attribute.String("role.name", name))
+	defer span.End()
+
+	return s.updatePermissions(ctx, span, name, func(current []string) []string {
+		for _, permission := range permissions {
+			if !slices.Contains(current, permission) {
+				current = append(current, permission)
+			}
+		}
+		return current
+	})
+}
+
+// RevokePermissions removes permissions from a role
+func (s *RoleService) RevokePermissions(ctx context.Context, name string, permissions ...string) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.RevokePermissions", attribute.String("role.name", name))
+	defer span.End()
+
+	return s.updatePermissions(ctx, span, name, func(current []string) []string {
+		return slices.DeleteFunc(current, func(permission string) bool {
+			return slices.Contains(permissions, permission)
+		})
+	})
+}
+
+// EnsureRole creates a shared role with the given permissions, or adds the ones an existing role
+// lacks, so seeding can run repeatedly. Permissions added by hand are kept. It reports
+// whether the role was created.
+func (s *RoleService) EnsureRole(ctx context.Context, name string, permissions ...string) (*models.Role, bool, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.EnsureRole", attribute.String("role.name", name))
+	defer span.End()
+
+	role := &models.Role{}
+	result := s.db.WithContext(ctx).Scopes(scopeRoleName(ctx, name)).Attrs(models.Role{Name: name, Permissions: permissions}).FirstOrCreate(role)
+	if result.Error != nil {
+		return nil, false, tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		effectiveRoles.invalidate()
+		return role, true, nil
+	}
+
+	for _, permission := range permissions {
+		if !slices.Contains(role.Permissions, permission) {
+			role, err := s.GrantPermissions(ctx, name, permissions...)
+			return role, false, err
+		}
+	}
+	return role, false, nil
+}
+
+// updatePermissions rewrites the permissions of a role inside a transaction, so concurrent
+// grants and revokes don't overwrite each other
+func (s *RoleService) updatePermissions(ctx context.Context, span trace.Span, name string, update func([]string) []string) (*models.Role, error) {
+	var role models.Role
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(scopeRoleName(ctx, name)).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if err := checkWritable(ctx, &role); err != nil {
+			return err
+		}
+		role.Permissions = update(role.Permissions)
+		return tx.Model(&role).Update("permissions", role.Permissions).Error
+	})
+	if errors.Is(err, ErrRoleNotFound) || errors.Is(err, ErrSharedRole) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	effectiveRoles.invalidate()
+	return &role, nil
+}
+
+// hierarchy runs fn in a transaction holding the role hierarchy lock and clears the cached
+// effective permissions once it committed
+func (s *RoleService) hierarchy(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", roleHierarchyLockKey).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+	if err == nil {
+		effectiveRoles.invalidate()
+	}
+	return err
+}
+
+
+// SetMagicLinkEnabled turns passwordless magic-link login on or off for members of a role
+func (s *RoleService) SetMagicLinkEnabled(ctx context.Context, id uint, enabled bool) (*models.Role, error) {
+	ctx, span := tracing.Start(ctx, "RoleService.SetMagicLinkEnabled", attribute.Int64("role.id", int64(id)))
+	defer span.End()
+
+	role, err := s.GetRoleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(ctx, role); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(role).Update("magic_link_enabled", enabled).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	role.MagicLinkEnabled = enabled
+	return role, nil
+}
+
+// scopeRoleName selects the role called name among those visible to ctx. Without an
+// organization only shared roles match, since names are only unique per organization.
+func scopeRoleName(ctx context.Context, name string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("roles.name = ?", name)
+		if _, scoped := organizationScope(ctx); !scoped {
+			return db.Where("roles.organization_id IS NULL")
+		}
+		return db.Scopes(scopeRoles(ctx))
+	}
+}
+
+// checkWritable rejects changes to shared roles from within an organization
+func checkWritable(ctx context.Context, role *models.Role) error {
+	if _, scoped := organizationScope(ctx); scoped && role.OrganizationID == nil {
+		return ErrSharedRole
+	}
+	return nil
+}
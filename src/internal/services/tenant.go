@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Tenant is who a request acts for: the active organization of its token, the caller and
+// whether the caller is a super-admin
+type Tenant struct {
+	OrganizationID uint // 0 outside any organization
+	Username       string
+	SuperAdmin     bool
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose UserService and RoleService queries are limited to
+// the tenant's organization
+func WithTenant(ctx context.Context, tenant Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(Tenant)
+	return tenant, ok
+}
+
+// organizationScope returns the organization the queries of ctx are limited to. Contexts
+// without a tenant, i.e. the CLI, the bootstrap and other internal callers, and
+// super-admins outside organizations are not limited. Other callers outside organizations
+// are limited to organization 0, which has no users and no roles of its own.
+func organizationScope(ctx context.Context) (uint, bool) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok || (tenant.SuperAdmin && tenant.OrganizationID == 0) {
+		return 0, false
+	}
+	return tenant.OrganizationID, true
+}
+
+// scopeUsers limits a users query to the members of the organization of ctx
+func scopeUsers(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		orgID, scoped := organizationScope(ctx)
+		if !scoped {
+			return db
+		}
+		return db.Where("EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id AND memberships.organization_id = ?)", orgID)
+	}
+}
+
+// scopeRoles limits a roles query to the shared roles and those of the organization of ctx
+func scopeRoles(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		orgID, scoped := organizationScope(ctx)
+		if !scoped {
+			return db
+		}
+		return db.Where("(roles.organization_id IS NULL OR roles.organization_id = ?)", orgID)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+// tenantFixture is two organizations sharing the member role, each with a role of its
+// own: alice is in acme, bob in globex and carol in both, as an admin of acme only
+type tenantFixture struct {
+	db                      *gorm.DB
+	acme, globex            *models.Organization
+	member, acmeAdmin       *models.Role
+	globexAdmin             *models.Role
+	alice, bob, carol, root *models.User
+}
+
+func newTenantFixture(t *testing.T) *tenantFixture {
+	t.Helper()
+	db := dbtest.New(t)
+	f := &tenantFixture{db: db}
+	f.acme = dbtest.CreateOrganization(t, db, "acme")
+	f.globex = dbtest.CreateOrganization(t, db, "globex")
+	f.member = dbtest.CreateRole(t, db, "member", 0, "users.read")
+	f.acmeAdmin = dbtest.CreateRole(t, db, "admin", f.acme.ID, "users.read", "users.update", "users.delete")
+	f.globexAdmin = dbtest.CreateRole(t, db, "admin", f.globex.ID, "users.read", "users.update", "users.delete")
+
+	f.alice = dbtest.CreateUser(t, db, "alice", f.member.ID)
+	f.bob = dbtest.CreateUser(t, db, "bob", f.member.ID)
+	f.carol = dbtest.CreateUser(t, db, "carol", f.member.ID)
+	f.root = dbtest.CreateUser(t, db, "root", f.member.ID)
+	if err := db.Model(f.root).Update("super_admin", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	dbtest.AddMember(t, db, f.acme, f.alice, f.member.ID)
+	dbtest.AddMember(t, db, f.globex, f.bob, f.member.ID)
+	dbtest.AddMember(t, db, f.acme, f.carol, f.acmeAdmin.ID)
+	dbtest.AddMember(t, db, f.globex, f.carol, f.member.ID)
+	// The role cache is shared by the tests, whose databases reuse role ids
+	effectiveRoles.invalidate()
+	return f
+}
+
+// in returns the context of carol acting in organization
+func (f *tenantFixture) in(organization *models.Organization) context.Context {
+	return WithTenant(context.Background(), Tenant{OrganizationID: organization.ID, Username: "carol"})
+}
+
+func usernames(users []models.User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func roleOwners(roles []models.Role) []string {
+	owners := make([]string, 0, len(roles))
+	for _, role := range roles {
+		owner := "shared"
+		if role.OrganizationID != nil {
+			owner = "org"
+		}
+		owners = append(owners, role.Name+"/"+owner)
+	}
+	slices.Sort(owners)
+	return owners
+}
+
+func TestScopedUserQueries(t *testing.T) {
+	f := newTenantFixture(t)
+	s := NewUserService(f.db)
+	ctx := f.in(f.acme)
+
+	users, err := s.GetAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if got, want := usernames(users), []string{"alice", "carol"}; !slices.Equal(got, want) {
+		t.Errorf("GetAllUsers in acme = %v, want %v", got, want)
+	}
+	for _, user := range users {
+		if user.Username == "carol" && user.RoleID != f.acmeAdmin.ID {
+			t.Errorf("GetAllUsers in acme gave carol role %d, want her acme role %d", user.RoleID, f.acmeAdmin.ID)
+		}
+	}
+
+	if _, err := s.GetUserByID(ctx, f.bob.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID(bob) in acme: got %v, want %v", err, ErrUserNotFound)
+	}
+	carol, err := s.GetUserByID(ctx, f.carol.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID(carol): %v", err)
+	}
+	if carol.RoleID != f.acmeAdmin.ID {
+		t.Errorf("GetUserByID(carol) in acme gave role %d, want %d", carol.RoleID, f.acmeAdmin.ID)
+	}
+
+	// carol holds member in globex only, bob is not in acme at all
+	members, err := s.GetUsersByRoleID(ctx, f.member.ID)
+	if err != nil {
+		t.Fatalf("GetUsersByRoleID: %v", err)
+	}
+	if got, want := usernames(members), []string{"alice"}; !slices.Equal(got, want) {
+		t.Errorf("GetUsersByRoleID(member) in acme = %v, want %v", got, want)
+	}
+	admins, err := s.GetUsersByRoleID(ctx, f.globexAdmin.ID)
+	if err != nil {
+		t.Fatalf("GetUsersByRoleID: %v", err)
+	}
+	if len(admins) != 0 {
+		t.Errorf("GetUsersByRoleID(globex admin) in acme = %v, want none", usernames(admins))
+	}
+}
+
+func TestUpdateUserAcrossOrganizations(t *testing.T) {
+	f := newTenantFixture(t)
+	s := NewUserService(f.db)
+	ctx := f.in(f.acme)
+
+	_, err := s.UpdateUser(ctx, f.bob.ID, &models.UserUpdateRequest{Email: "bob@evil.example", Username: "bob"})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("UpdateUser(bob) in acme: got %v, want %v", err, ErrUserNotFound)
+	}
+	var bob models.User
+	f.db.First(&bob, f.bob.ID)
+	if bob.Email != "bob@example.com" {
+		t.Errorf("UpdateUser(bob) in acme changed his email to %s", bob.Email)
+	}
+
+	_, err = s.UpdateUser(ctx, f.alice.ID, &models.UserUpdateRequest{Email: "alice@example.com", Username: "alice", RoleID: f.globexAdmin.ID})
+	if !sameError(err, unknownRole("role_id")) {
+		t.Errorf("UpdateUser(alice) with a globex role: got %v, want the known_role error", err)
+	}
+
+	// A role change within acme leaves carol's globex membership and account role alone
+	if _, err := s.UpdateUser(ctx, f.carol.ID, &models.UserUpdateRequest{Email: "carol@example.com", Username: "carol", RoleID: f.member.ID}); err != nil {
+		t.Fatalf("UpdateUser(carol): %v", err)
+	}
+	var inGlobex models.Membership
+	f.db.Where("user_id = ? AND organization_id = ?", f.carol.ID, f.globex.ID).First(&inGlobex)
+	if inGlobex.RoleID != f.member.ID {
+		t.Errorf("UpdateUser(carol) in acme changed her globex role to %d", inGlobex.RoleID)
+	}
+	var inAcme models.Membership
+	f.db.Where("user_id = ? AND organization_id = ?", f.carol.ID, f.acme.ID).First(&inAcme)
+	if inAcme.RoleID != f.member.ID {
+		t.Errorf("UpdateUser(carol) in acme left her acme role at %d, want %d", inAcme.RoleID, f.member.ID)
+	}
+}
+
+// Within an organization the account fields of users who are members elsewhere too are
+// left to super-admins, and super-admin accounts to super-admins alone
+func TestUpdateUserAccountFields(t *testing.T) {
+	f := newTenantFixture(t)
+	s := NewUserService(f.db)
+	ctx := f.in(f.acme)
+	superAdmin := WithTenant(context.Background(), Tenant{OrganizationID: f.acme.ID, Username: "root", SuperAdmin: true})
+	dan := dbtest.CreateUser(t, f.db, "dan", f.member.ID)
+	dbtest.AddMember(t, f.db, f.acme, dan, f.member.ID)
+	dbtest.AddMember(t, f.db, f.globex, dan, f.member.ID)
+	dbtest.AddMember(t, f.db, f.acme, f.root, f.member.ID)
+	disabled := true
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		user *models.User
+		req  models.UserUpdateRequest
+		want error
+	}{
+		{
+			name: "account of acme alone",
+			ctx:  ctx,
+			user: f.alice,
+			req:  models.UserUpdateRequest{Email: "alice@acme.example", Username: "alice", First: "Alice", Disabled: &disabled},
+		},
+		{
+			name: "email of an account shared with globex",
+			ctx:  ctx,
+			user: dan,
+			req:  models.UserUpdateRequest{Email: "dan@acme.example", Username: "dan"},
+			want: ErrSharedAccount,
+		},
+		{
+			name: "status of an account shared with globex",
+			ctx:  ctx,
+			user: dan,
+			req:  models.UserUpdateRequest{Email: "dan@example.com", Username: "dan", Disabled: &disabled},
+			want: ErrSharedAccount,
+		},
+		{
+			name: "role of an account shared with globex",
+			ctx:  ctx,
+			user: dan,
+			req:  models.UserUpdateRequest{Email: "dan@example.com", Username: "dan", RoleID: f.acmeAdmin.ID},
+		},
+		{
+			name: "super-admin changing an account shared with globex",
+			ctx:  superAdmin,
+			user: dan,
+			req:  models.UserUpdateRequest{Email: "dan@acme.example", Username: "dan", Disabled: &disabled},
+		},
+		{
+			name: "role of a super-admin",
+			ctx:  ctx,
+			user: f.root,
+			req:  models.UserUpdateRequest{Email: "root@example.com", Username: "root", RoleID: f.acmeAdmin.ID},
+			want: ErrSuperAdminUser,
+		},
+		{
+			name: "super-admin changing a super-admin",
+			ctx:  superAdmin,
+			user: f.root,
+			req:  models.UserUpdateRequest{Email: "root@example.com", Username: "root", First: "Root"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before models.User
+			f.db.First(&before, tt.user.ID)
+			_, err := s.UpdateUser(tt.ctx, tt.user.ID, &tt.req)
+			if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Fatalf("UpdateUser = %v, want %v", err, tt.want)
+			}
+			var after models.User
+			f.db.First(&after, tt.user.ID)
+			var membership models.Membership
+			f.db.Where("user_id = ? AND organization_id = ?", tt.user.ID, f.acme.ID).First(&membership)
+			if tt.want != nil {
+				if after.Email != before.Email || after.Disabled != before.Disabled || membership.RoleID != f.member.ID {
+					t.Errorf("a refused update changed the user: %+v, role %d", after, membership.RoleID)
+				}
+				return
+			}
+			if after.Email != tt.req.Email || after.First != tt.req.First || after.Disabled != (tt.req.Disabled != nil && *tt.req.Disabled) {
+				t.Errorf("user after the update = %+v, want the fields of %+v", after, tt.req)
+			}
+			if tt.req.RoleID != 0 && membership.RoleID != tt.req.RoleID {
+				t.Errorf("role in acme = %d, want %d", membership.RoleID, tt.req.RoleID)
+			}
+		})
+	}
+
+	// The role change within acme left dan's account role and globex membership alone
+	var inGlobex models.Membership
+	f.db.Where("user_id = ? AND organization_id = ?", dan.ID, f.globex.ID).First(&inGlobex)
+	if inGlobex.RoleID != f.member.ID {
+		t.Errorf("dan's globex role = %d, want %d", inGlobex.RoleID, f.member.ID)
+	}
+}
+
+// Role ids of requests must name a role visible where the request acts
+func TestUnknownRoles(t *testing.T) {
+	f := newTenantFixture(t)
+	users := NewUserService(f.db)
+	invitations := NewInvitationService(f.db, &fakeMailer{}, nil, InvitationConfig{TTL: time.Hour})
+	organizations := NewOrganizationService(f.db)
+	newUser := func(roleID uint) *models.UserCreateRequest {
+		return &models.UserCreateRequest{Username: "erin", Email: "erin@example.com", Password: "password", RoleID: roleID}
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"user created without a role", func() error { _, err := users.CreateUser(context.Background(), newUser(0)); return err }},
+		{"user created with a deleted role", func() error { _, err := users.CreateUser(context.Background(), newUser(999)); return err }},
+		{"user created with a globex role in acme", func() error { _, err := users.CreateUser(f.in(f.acme), newUser(f.globexAdmin.ID)); return err }},
+		{"user updated to a deleted role", func() error {
+			_, err := users.UpdateUser(context.Background(), f.alice.ID, &models.UserUpdateRequest{Email: "alice@example.com", Username: "alice", RoleID: 999})
+			return err
+		}},
+		{"invitation to a globex role in acme", func() error {
+			_, err := invitations.CreateInvitation(f.in(f.acme), &models.InvitationRequest{Email: "erin@example.com", RoleID: f.globexAdmin.ID}, "carol")
+			return err
+		}},
+		{"acme membership with a globex role", func() error {
+			_, err := organizations.SetMember(context.Background(), f.acme.ID, &models.MembershipRequest{UserID: f.bob.ID, RoleID: f.globexAdmin.ID})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !sameError(err, unknownRole("role_id")) {
+			t.Errorf("%s: got %v, want the known_role error on role_id", tt.name, err)
+		}
+	}
+
+	var created, invited, bobInAcme int64
+	f.db.Model(&models.User{}).Where("username = ?", "erin").Count(&created)
+	f.db.Model(&models.Invitation{}).Count(&invited)
+	f.db.Model(&models.Membership{}).Where("user_id = ? AND organization_id = ?", f.bob.ID, f.acme.ID).Count(&bobInAcme)
+	if created+invited+bobInAcme != 0 {
+		t.Errorf("rejected requests created %d users, %d invitations and %d memberships", created, invited, bobInAcme)
+	}
+	var alice models.User
+	f.db.First(&alice, f.alice.ID)
+	if alice.RoleID != f.member.ID {
+		t.Errorf("alice's role = %d, want %d", alice.RoleID, f.member.ID)
+	}
+}
+
+func TestDeleteUserAcrossOrganizations(t *testing.T) {
+	f := newTenantFixture(t)
+	s := NewUserService(f.db)
+	ctx := f.in(f.acme)
+
+	if err := s.DeleteUser(ctx, f.bob.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeleteUser(bob) in acme: got %v, want %v", err, ErrUserNotFound)
+	}
+	if err := f.db.First(&models.User{}, f.bob.ID).Error; err != nil {
+		t.Errorf("DeleteUser(bob) in acme deleted his account: %v", err)
+	}
+
+	// carol leaves acme but keeps her account for globex
+	if err := s.DeleteUser(ctx, f.carol.ID); err != nil {
+		t.Fatalf("DeleteUser(carol): %v", err)
+	}
+	if err := f.db.First(&models.User{}, f.carol.ID).Error; err != nil {
+		t.Errorf("DeleteUser(carol) in acme deleted her account: %v", err)
+	}
+	var memberships []models.Membership
+	f.db.Where("user_id = ?", f.carol.ID).Find(&memberships)
+	if len(memberships) != 1 || memberships[0].OrganizationID != f.globex.ID {
+		t.Errorf("carol's memberships after leaving acme = %+v, want globex only", memberships)
+	}
+
+	// alice was in acme only, so her account goes with the membership
+	if err := s.DeleteUser(ctx, f.alice.ID); err != nil {
+		t.Fatalf("DeleteUser(alice): %v", err)
+	}
+	if err := f.db.First(&models.User{}, f.alice.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("DeleteUser(alice) in acme kept her account: %v", err)
+	}
+}
+
+func TestScopedRoleQueries(t *testing.T) {
+	f := newTenantFixture(t)
+	s := NewRoleService(f.db)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"acme sees the shared roles and its own", f.in(f.acme), []string{"admin/org", "member/shared"}},
+		{"outside organizations only the shared roles", WithTenant(context.Background(), Tenant{}), []string{"member/shared"}},
+		{"internal callers see every role", context.Background(), []string{"admin/org", "admin/org", "member/shared"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, err := s.GetAllRoles(tt.ctx)
+			if err != nil {
+				t.Fatalf("GetAllRoles: %v", err)
+			}
+			if got := roleOwners(roles); !slices.Equal(got, tt.want) {
+				t.Errorf("GetAllRoles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.GetRoleByID(f.in(f.acme), f.globexAdmin.ID); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("GetRoleByID(globex admin) in acme: got %v, want %v", err, ErrRoleNotFound)
+	}
+	role, err := s.GetRoleByName(f.in(f.globex), "admin")
+	if err != nil {
+		t.Fatalf("GetRoleByName(admin) in globex: %v", err)
+	}
+	if role.ID != f.globexAdmin.ID {
+		t.Errorf("GetRoleByName(admin) in globex = role %d, want %d", role.ID, f.globexAdmin.ID)
+	}
+}
+
+func TestSuperAdminBypass(t *testing.T) {
+	f := newTenantFixture(t)
+	users := NewUserService(f.db)
+	roles := NewRoleService(f.db)
+
+	superAdmin := WithTenant(context.Background(), Tenant{SuperAdmin: true})
+	all, err := users.GetAllUsers(superAdmin)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if got, want := usernames(all), []string{"alice", "bob", "carol", "root"}; !slices.Equal(got, want) {
+		t.Errorf("GetAllUsers for a super-admin = %v, want %v", got, want)
+	}
+	if _, err := roles.GetRoleByID(superAdmin, f.globexAdmin.ID); err != nil {
+		t.Errorf("GetRoleByID(globex admin) for a super-admin: %v", err)
+	}
+
+	// Outside organizations other callers see nobody
+	none, err := users.GetAllUsers(WithTenant(context.Background(), Tenant{}))
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if len(none) != 0 {
+		t.Errorf("GetAllUsers outside organizations = %v, want none", usernames(none))
+	}
+
+	// Within an organization a super-admin is scoped to it like anyone else
+	inAcme := WithTenant(context.Background(), Tenant{OrganizationID: f.acme.ID, SuperAdmin: true})
+	if _, err := users.GetUserByID(inAcme, f.bob.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID(bob) for a super-admin in acme: got %v, want %v", err, ErrUserNotFound)
+	}
+}
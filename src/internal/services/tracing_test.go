@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/tracing"
+	"go_api/src/internal/tracingtest"
+)
+
+func TestUserServiceSpans(t *testing.T) {
+	exporter, restore := tracingtest.NewInMemory()
+	defer restore()
+	db := dbtest.New(t)
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	alice := dbtest.CreateUser(t, db, "alice", guest.ID)
+	exporter.Reset()
+
+	if _, err := NewUserService(db).GetUserByID(context.Background(), alice.ID); err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	var service, statements, children = -1, 0, 0
+	for i, span := range spans {
+		if span.Name == "UserService.GetUserByID" {
+			service = i
+		}
+	}
+	if service < 0 {
+		t.Fatalf("no UserService.GetUserByID span in %d spans", len(spans))
+	}
+	for _, kv := range spans[service].Attributes {
+		if kv.Key == "user.id" && kv.Value.AsInt64() != int64(alice.ID) {
+			t.Errorf("user.id = %d, want %d", kv.Value.AsInt64(), alice.ID)
+		}
+	}
+	// The user is queried within the service span, its role within that statement
+	for _, span := range spans {
+		if span.Name != "db.query" {
+			continue
+		}
+		statements++
+		if span.Parent.SpanID() == spans[service].SpanContext.SpanID() {
+			children++
+		}
+		if span.SpanContext.TraceID() != spans[service].SpanContext.TraceID() {
+			t.Errorf("statement %s is outside the service's trace", span.SpanContext.SpanID())
+		}
+	}
+	if statements != 2 || children != 1 {
+		t.Errorf("%d statement spans, %d of them under the service span, want the user query under it and the role query under that", statements, children)
+	}
+}
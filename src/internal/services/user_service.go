@@ -1,14 +1,21 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"log"
+	"log/slog"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
-	"go_api/internal/models"
+	"go_api/src/internal/models"
+	"go_api/src/internal/tracing"
 )
 
+// UserService manages user accounts. Within an organization it only sees the organization's
+// members, with their role in it.
 type UserService struct {
 	db *gorm.DB
 }
@@ -19,21 +26,40 @@ func NewUserService(db *gorm.DB) *UserService {
 }
 
 // PreloadRole preloads the Role association for a given user
-func (s *UserService) PreloadRole(user *models.User) error {
-	return s.db.Model(user).Association("Role").Find(&user.Role)
+func (s *UserService) PreloadRole(ctx context.Context, user *models.User) error {
+	return s.db.WithContext(ctx).Model(user).Association("Role").Find(&user.Role)
 }
 
 // GetAllUsers retrieves all users from the database
-func (s *UserService) GetAllUsers() ([]models.User, error) {
+func (s *UserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAllUsers")
+	defer span.End()
+
 	var users []models.User
-	if err := s.db.Preload("Role").Find(&users).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Scopes(scopeUsers(ctx)).Preload("Role").Find(&users).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := s.withOrganizationRoles(ctx, users); err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 	return users, nil
 }
 
-// CreateUser creates a new user in the database
-func (s *UserService) CreateUser(userCreateRequest *models.UserCreateRequest) (*models.User, error) {
+// CreateUser creates a new user in the database. Within an organization the user becomes
+// a member with the requested role, which must be visible there. The role may only grant
+// permissions the caller holds.
+func (s *UserService) CreateUser(ctx context.Context, userCreateRequest *models.UserCreateRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateUser")
+	defer span.End()
+
+	orgID, scoped := organizationScope(ctx)
+	if _, err := NewRoleService(s.db).knownRole(ctx, "role_id", userCreateRequest.RoleID); err != nil {
+		return nil, err
+	}
+	if err := checkGrantable(ctx, s.db, userCreateRequest.RoleID); err != nil {
+		return nil, err
+	}
+
 	user := &models.User{
 		Email:    userCreateRequest.Email,
 		Username: userCreateRequest.Username,
@@ -46,101 +72,356 @@ func (s *UserService) CreateUser(userCreateRequest *models.UserCreateRequest) (*
 
 	plaintextPassword := user.Password
 
-	log.Printf("CreateUser: Creating user with email: %s", user.Email)
+	slog.InfoContext(ctx, "CreateUser: creating user", "username", user.Username)
 
 	// Create user with plaintext password initially
-	if err := s.db.Create(user).Error; err != nil {
-		return nil, err
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return translateDBError(err, ErrUserExists)
+		}
+		if !scoped {
+			return nil
+		}
+		return tx.Create(&models.Membership{UserID: user.ID, OrganizationID: orgID, RoleID: user.RoleID}).Error
+	})
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 
 	// Hash password and update
-	if err := s.UpdateUserPassword(user.Username, plaintextPassword); err != nil {
-		log.Printf("CreateUser: Failed to update password for user '%s': %v", user.Username, err)
+	if err := s.UpdateUserPassword(ctx, user.Username, plaintextPassword); err != nil {
+		slog.ErrorContext(ctx, "CreateUser: failed to set password", "username", user.Username, "err", err)
 		// Consider returning error to caller here if preferred
 	}
 
 	// Preload Role association
-	if err := s.db.Model(user).Association("Role").Find(&user.Role); err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Model(user).Association("Role").Find(&user.Role); err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 
 	return user, nil
 }
 
 // GetUserByID retrieves a user by its ID from the database
-func (s *UserService) GetUserByID(id uint) (*models.User, error) {
+func (s *UserService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByID", attribute.Int64("user.id", int64(id)))
+	defer span.End()
+
 	var user models.User
-	if err := s.db.Preload("Role").First(&user, id).Error; err != nil {
+	if err := s.db.WithContext(ctx).Scopes(scopeUsers(ctx)).Preload("Role").First(&user, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+			return nil, ErrUserNotFound
 		}
-		return nil, err
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := s.withOrganizationRole(ctx, &user); err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 	return &user, nil
 }
 
-// UpdateUser updates a user
-func (s *UserService) UpdateUser(user *models.User) error {
-	return s.db.Save(user).Error
+// PolicyAttributes returns the attributes of the user id that policy rules can test: id,
+// username, email, role, auth_source, disabled, super_admin and organization_ids, the
+// organizations the user is a member of. Within an organization role is the user's role in it.
+func (s *UserService) PolicyAttributes(ctx context.Context, id uint) (map[string]any, error) {
+	ctx, span := tracing.Start(ctx, "UserService.PolicyAttributes", attribute.Int64("user.id", int64(id)))
+	defer span.End()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var organizationIDs []uint
+	if err := s.db.WithContext(ctx).Model(&models.Membership{}).Where("user_id = ?", id).Pluck("organization_id", &organizationIDs).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return map[string]any{
+		"id":               user.ID,
+		"username":         user.Username,
+		"email":            user.Email,
+		"role":             user.Role.Name,
+		"auth_source":      user.AuthSource,
+		"disabled":         user.Disabled,
+		"super_admin":      user.SuperAdmin,
+		"organization_ids": organizationIDs,
+	}, nil
 }
 
-// DeleteUser deletes a user by its ID from the database
-func (s *UserService) DeleteUser(id uint) error {
-	return s.db.Delete(&models.User{}, id).Error
+// UpdateUser updates the profile, role and status of the user id. Within an organization
+// the role is the user's role in it, and a new role may only grant permissions the caller
+// holds. Only the fields of the request are written, so the password, super-admin status
+// and token revocation of the account are kept.
+//
+// The profile and status belong to the account, which other organizations may share. Within
+// an organization only super-admins change them for users who are members elsewhere too;
+// other callers get ErrSharedAccount and may only change the role. Super-admin accounts are
+// only changed by super-admins.
+func (s *UserService) UpdateUser(ctx context.Context, id uint, req *models.UserUpdateRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUser", attribute.Int64("user.id", int64(id)))
+	defer span.End()
+
+	current, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tenant, hasTenant := TenantFromContext(ctx)
+	privileged := !hasTenant || tenant.SuperAdmin
+	if current.SuperAdmin && !privileged {
+		return nil, ErrSuperAdminUser
+	}
+	update := models.User{
+		Email:    req.Email,
+		Username: req.Username,
+		First:    req.First,
+		Last:     req.Last,
+		Phone:    req.Phone,
+		RoleID:   current.RoleID,
+		Disabled: current.Disabled,
+	}
+	if req.RoleID != 0 && req.RoleID != current.RoleID {
+		if _, err := NewRoleService(s.db).knownRole(ctx, "role_id", req.RoleID); err != nil {
+			return nil, err
+		}
+		if err := checkGrantable(ctx, s.db, req.RoleID); err != nil {
+			return nil, err
+		}
+		update.RoleID = req.RoleID
+	}
+	if req.Disabled != nil {
+		update.Disabled = *req.Disabled
+	}
+
+	columns := []string{"email", "username", "first", "last", "phone", "disabled"}
+	orgID, scoped := organizationScope(ctx)
+	if !scoped {
+		columns = append(columns, "role_id")
+	} else if !privileged {
+		var elsewhere int64
+		err := s.db.WithContext(ctx).Model(&models.Membership{}).Where("user_id = ? AND organization_id <> ?", id, orgID).Count(&elsewhere).Error
+		if err != nil {
+			return nil, tracing.RecordError(span, err)
+		}
+		if elsewhere > 0 {
+			if update.Email != current.Email || update.Username != current.Username || update.First != current.First ||
+				update.Last != current.Last || update.Phone != current.Phone || update.Disabled != current.Disabled {
+				return nil, ErrSharedAccount
+			}
+			columns = nil
+		}
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(columns) > 0 {
+			if err := tx.Model(&models.User{ID: id}).Select(columns).Updates(&update).Error; err != nil {
+				return translateDBError(err, ErrUserExists)
+			}
+		}
+		if !scoped {
+			return nil
+		}
+		return tx.Model(&models.Membership{}).Where("user_id = ? AND organization_id = ?", id, orgID).Update("role_id", update.RoleID).Error
+	})
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	return s.GetUserByID(ctx, id)
+}
+
+// DeleteUser deletes a user by its ID from the database, or within an organization
+// removes them from it
+func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser", attribute.Int64("user.id", int64(id)))
+	defer span.End()
+
+	orgID, scoped := organizationScope(ctx)
+	if !scoped {
+		result := s.db.WithContext(ctx).Delete(&models.User{}, id)
+		if result.Error != nil {
+			return tracing.RecordError(span, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		return nil
+	}
+
+	// Within an organization the user leaves it; the account goes once it is in none
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND organization_id = ?", id, orgID).Delete(&models.Membership{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		return tx.Where("id = ? AND NOT super_admin AND NOT EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id)", id).
+			Delete(&models.User{}).Error
+	})
+	if errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+	return tracing.RecordError(span, err)
 }
 
 // GetUserByEmail retrieves a user by its email from the database
-func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByEmail")
+	defer span.End()
+
 	var user models.User
-	if err := s.db.Preload("Role").Where("email = ?", email).First(&user).Error; err != nil {
+	if err := s.db.WithContext(ctx).Scopes(scopeUsers(ctx)).Preload("Role").Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+			return nil, ErrUserNotFound
 		}
-		return nil, err
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := s.withOrganizationRole(ctx, &user); err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 	return &user, nil
 }
 
 // GetUserByUsername retrieves a user by its username from the database
-func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByUsername")
+	defer span.End()
+
 	var user models.User
-	if err := s.db.Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
+	if err := s.db.WithContext(ctx).Scopes(scopeUsers(ctx)).Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+			return nil, ErrUserNotFound
 		}
-		return nil, err
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := s.withOrganizationRole(ctx, &user); err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 	return &user, nil
 }
 
-// GetUsersByRoleID retrieves all users who have the specified role ID
-func (s *UserService) GetUsersByRoleID(roleID uint) ([]models.User, error) {
+// GetUsersByRoleID retrieves all users who have the specified role ID, within an
+// organization their role in it
+func (s *UserService) GetUsersByRoleID(ctx context.Context, roleID uint) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsersByRoleID", attribute.Int64("role.id", int64(roleID)))
+	defer span.End()
+
+	query := s.db.WithContext(ctx).Preload("Role")
+	if orgID, scoped := organizationScope(ctx); scoped {
+		query = query.Where("EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id AND memberships.organization_id = ? AND memberships.role_id = ?)", orgID, roleID)
+	} else {
+		query = query.Where("role_id = ?", roleID)
+	}
+
 	var users []models.User
-	if err := s.db.Preload("Role").Where("role_id = ?", roleID).Find(&users).Error; err != nil {
-		return nil, err
+	if err := query.Find(&users).Error; err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := s.withOrganizationRoles(ctx, users); err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 	return users, nil
 }
 
 // UpdateUserPassword updates the password for a user, given the username and the new password.
-func (s *UserService) UpdateUserPassword(username, newPassword string) error {
+func (s *UserService) UpdateUserPassword(ctx context.Context, username, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUserPassword")
+	defer span.End()
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	return s.db.Model(&models.User{}).Where("username = ?", username).Update("password", string(hashedPassword)).Error
+	return s.updateByUsername(ctx, span, username, "password", string(hashedPassword))
 }
 
-// ChangeUserPassword changes the password for a user, given the username, the old password, and the new password.
-func (s *UserService) ChangeUserPassword(username, oldPassword, newPassword string) error {
+// SetDisabled disables or re-enables a user. Disabled users can't log in and their tokens are rejected.
+func (s *UserService) SetDisabled(ctx context.Context, username string, disabled bool) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetDisabled")
+	defer span.End()
+
+	return s.updateByUsername(ctx, span, username, "disabled", disabled)
+}
+
+// RevokeTokens rejects every token issued to a user until now
+func (s *UserService) RevokeTokens(ctx context.Context, username string) error {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeTokens")
+	defer span.End()
+
+	// Token issue times have a one second resolution
+	return s.updateByUsername(ctx, span, username, "tokens_revoked_at", time.Now().Truncate(time.Second))
+}
+
+// RevokeAllTokens rejects every token issued to any user until now and returns the number of users
+func (s *UserService) RevokeAllTokens(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeAllTokens")
+	defer span.End()
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).Where("1 = 1").Update("tokens_revoked_at", time.Now().Truncate(time.Second))
+	return result.RowsAffected, tracing.RecordError(span, result.Error)
+}
+
+// ErrTokenRevoked is returned by CheckToken for a token that must no longer be accepted
+var ErrTokenRevoked = Unauthenticated("token_revoked", "Token has been revoked")
+
+// CheckToken rejects a token issued at issuedAt for tenant when its user no longer exists,
+// is disabled, had their tokens revoked afterwards, left the token's organization or lost
+// super-admin status
+func (s *UserService) CheckToken(ctx context.Context, username string, issuedAt time.Time, tenant Tenant) error {
 	var user models.User
-	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := s.db.WithContext(ctx).Select("id", "disabled", "super_admin", "tokens_revoked_at").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked.Wrap(ErrUserNotFound)
+		}
 		return err
 	}
+	if user.Disabled {
+		return ErrTokenRevoked.Wrap(ErrUserDisabled)
+	}
+	if user.TokensRevokedAt != nil && issuedAt.Before(*user.TokensRevokedAt) {
+		return ErrTokenRevoked
+	}
+	if tenant.SuperAdmin && !user.SuperAdmin {
+		return ErrTokenRevoked
+	}
+	if tenant.OrganizationID != 0 && !user.SuperAdmin {
+		if _, err := membership(ctx, s.db, user.ID, tenant.OrganizationID); err != nil {
+			if errors.Is(err, ErrMembershipNotFound) {
+				return ErrTokenRevoked.Wrap(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// updateByUsername sets one column of a user, reporting ErrUserNotFound when there is no such user
+func (s *UserService) updateByUsername(ctx context.Context, span trace.Span, username, column string, value interface{}) error {
+	result := s.db.WithContext(ctx).Model(&models.User{}).Scopes(scopeUsers(ctx)).Where("username = ?", username).Update(column, value)
+	if result.Error != nil {
+		return tracing.RecordError(span, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// ChangeUserPassword changes the password for a user, given the username, the old password, and the new password.
+// It is the caller's own account, so it isn't limited to the active organization.
+func (s *UserService) ChangeUserPassword(ctx context.Context, username, oldPassword, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ChangeUserPassword")
+	defer span.End()
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return tracing.RecordError(span, err)
+	}
 
 	// Compare the provided old password with the stored hash
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
-		return errors.New("incorrect old password")
+		return ErrIncorrectPassword
 	}
 
 	// Hash the new password
@@ -151,6 +432,40 @@ func (s *UserService) ChangeUserPassword(username, oldPassword, newPassword stri
 
 	// Update the password
 	user.Password = string(hashedPassword)
-	return s.db.Save(&user).Error
+	return tracing.RecordError(span, s.db.WithContext(ctx).Save(&user).Error)
 }
 
+// withOrganizationRole replaces the role of user by their role in the organization of ctx
+func (s *UserService) withOrganizationRole(ctx context.Context, user *models.User) error {
+	users := []models.User{*user}
+	if err := s.withOrganizationRoles(ctx, users); err != nil {
+		return err
+	}
+	*user = users[0]
+	return nil
+}
+
+// withOrganizationRoles replaces the roles of users by their roles in the organization of ctx
+func (s *UserService) withOrganizationRoles(ctx context.Context, users []models.User) error {
+	orgID, scoped := organizationScope(ctx)
+	if !scoped || len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	var memberships []models.Membership
+	if err := s.db.WithContext(ctx).Preload("Role").Where("organization_id = ? AND user_id IN ?", orgID, ids).Find(&memberships).Error; err != nil {
+		return err
+	}
+	for _, m := range memberships {
+		for i := range users {
+			if users[i].ID == m.UserID {
+				users[i].RoleID, users[i].Role = m.RoleID, m.Role
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+func TestUpdateUserKeepsCredentials(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	editor := dbtest.CreateRole(t, db, "editor", 0, "users.read", "users.update")
+	user := dbtest.CreateUser(t, db, "alice", guest.ID)
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := db.Model(user).Update("tokens_revoked_at", revokedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	var before models.User
+	db.First(&before, user.ID)
+
+	disabled := true
+	updated, err := NewUserService(db).UpdateUser(context.Background(), user.ID, &models.UserUpdateRequest{
+		Email:    "alice@example.org",
+		Username: "alice",
+		First:    "Alice",
+		RoleID:   editor.ID,
+		Disabled: &disabled,
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.Email != "alice@example.org" || updated.First != "Alice" || updated.RoleID != editor.ID || !updated.Disabled {
+		t.Errorf("UpdateUser returned %+v, want the new profile, role and status", updated)
+	}
+
+	var after models.User
+	db.First(&after, user.ID)
+	if after.Password == "" || after.Password != before.Password {
+		t.Error("UpdateUser changed the password hash")
+	}
+	if after.TokensRevokedAt == nil || !after.TokensRevokedAt.Equal(*before.TokensRevokedAt) {
+		t.Errorf("UpdateUser changed tokens_revoked_at from %v to %v", before.TokensRevokedAt, after.TokensRevokedAt)
+	}
+	if !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("UpdateUser changed created_at from %v to %v", before.CreatedAt, after.CreatedAt)
+	}
+}
+
+func TestUpdateUserKeepsOmittedRoleAndStatus(t *testing.T) {
+	db := dbtest.New(t)
+	admin := dbtest.CreateRole(t, db, "admin", 0, "users.update")
+	user := dbtest.CreateUser(t, db, "alice", admin.ID)
+	if err := db.Model(user).Update("disabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := NewUserService(db).UpdateUser(context.Background(), user.ID, &models.UserUpdateRequest{Email: "alice@example.com", Username: "alice"})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.RoleID != admin.ID || !updated.Disabled {
+		t.Errorf("UpdateUser without role and status set role %d, disabled %v; want %d, true", updated.RoleID, updated.Disabled, admin.ID)
+	}
+}
+
+func TestUpdateUserConflicts(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	dbtest.CreateUser(t, db, "alice", guest.ID)
+	bob := dbtest.CreateUser(t, db, "bob", guest.ID)
+
+	_, err := NewUserService(db).UpdateUser(context.Background(), bob.ID, &models.UserUpdateRequest{Email: "bob@example.com", Username: "alice"})
+	if !errors.Is(err, ErrUserExists) {
+		t.Errorf("UpdateUser to a taken username: got %v, want ErrUserExists", err)
+	}
+	_, err = NewUserService(db).UpdateUser(context.Background(), 999, &models.UserUpdateRequest{Email: "x@example.com", Username: "nobody"})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("UpdateUser of a missing user: got %v, want ErrUserNotFound", err)
+	}
+}
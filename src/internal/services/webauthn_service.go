@@ -0,0 +1,551 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/models"
+)
+
+// ErrWebAuthnSession is returned when a ceremony is finished with an unknown, expired or mismatched session
+var ErrWebAuthnSession = Unauthenticated("webauthn_session_invalid", "Passkey session not found or expired")
+
+// ErrPasskeyNotFound is returned when a credential does not exist or belongs to another user
+var ErrPasskeyNotFound = NotFound("passkey_not_found", "Passkey not found")
+
+// ErrNoPasskeys is returned when passkey second factor is enabled for a user without passkeys
+var ErrNoPasskeys = Validation("no_passkeys", "Register a passkey first")
+
+// webAuthnSessionTTL bounds how long a begun ceremony may take to finish
+const webAuthnSessionTTL = 5 * time.Minute
+
+// Ceremony purposes stored with each session so a session cannot be finished by the wrong endpoint
+const (
+	webAuthnPurposeRegister     = "register"
+	webAuthnPurposeLogin        = "login"
+	webAuthnPurposeSecondFactor = "second_factor"
+)
+
+// WebAuthnService runs the WebAuthn registration and assertion ceremonies and manages stored passkeys
+type WebAuthnService struct {
+	db           *gorm.DB
+	webAuthn     *webauthn.WebAuthn
+	loginService *LoginService
+	sessions     *webAuthnSessionStore
+}
+
+// WebAuthnConfig describes the relying party
+type WebAuthnConfig struct {
+	RPID      string
+	RPName    string
+	RPOrigins []string
+}
+
+// NewWebAuthnService creates a new WebAuthnService for the relying party described by cfg
+func NewWebAuthnService(db *gorm.DB, loginService *LoginService, cfg WebAuthnConfig) (*WebAuthnService, error) {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebAuthn configuration: %w", err)
+	}
+	return NewWebAuthnServiceWithConfig(db, loginService, webAuthn), nil
+}
+
+// NewWebAuthnServiceWithConfig creates a WebAuthnService around an already configured relying party
+func NewWebAuthnServiceWithConfig(db *gorm.DB, loginService *LoginService, webAuthn *webauthn.WebAuthn) *WebAuthnService {
+	return &WebAuthnService{
+		db:           db,
+		webAuthn:     webAuthn,
+		loginService: loginService,
+		sessions:     newWebAuthnSessionStore(),
+	}
+}
+
+// BeginRegistration starts an attestation ceremony for an authenticated user
+func (s *WebAuthnService) BeginRegistration(username string) (*protocol.CredentialCreation, string, error) {
+	user, err := s.loadUser(username)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.sessions.put(webAuthnPurposeRegister, username, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, sessionID, nil
+}
+
+// FinishRegistration parses the browser's attestation response and stores the new passkey
+func (s *WebAuthnService) FinishRegistration(username, sessionID, name string, body io.Reader) (*models.WebAuthnCredential, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBody(body)
+	if err != nil {
+		return nil, err
+	}
+	return s.RegisterCredential(username, sessionID, name, parsed)
+}
+
+// RegisterCredential verifies a parsed attestation against the session and stores the new passkey
+func (s *WebAuthnService) RegisterCredential(username, sessionID, name string, parsed *protocol.ParsedCredentialCreationData) (*models.WebAuthnCredential, error) {
+	session, ok := s.sessions.take(sessionID, webAuthnPurposeRegister, username)
+	if !ok {
+		return nil, ErrWebAuthnSession
+	}
+
+	user, err := s.loadUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = "Passkey " + time.Now().Format("2006-01-02")
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	stored := &models.WebAuthnCredential{
+		UserID:          user.user.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.db.Create(stored).Error; err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// BeginLogin starts an assertion ceremony for a primary passkey login.
+// An empty username starts a discoverable (username-less) login. Unknown usernames and
+// users without passkeys get a challenge like any other, so it can't be used to probe
+// accounts; finishing it fails.
+func (s *WebAuthnService) BeginLogin(username string) (*protocol.CredentialAssertion, string, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		err       error
+	)
+
+	if username == "" {
+		assertion, session, err = s.webAuthn.BeginDiscoverableLogin()
+	} else {
+		user, loadErr := s.loadUser(username)
+		switch {
+		case errors.Is(loadErr, ErrInvalidCredentials):
+			user = s.decoyUser(username)
+		case loadErr != nil:
+			return nil, "", loadErr
+		case len(user.credentials) == 0:
+			user = s.decoyUser(username)
+		}
+		assertion, session, err = s.webAuthn.BeginLogin(user)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.sessions.put(webAuthnPurposeLogin, username, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, sessionID, nil
+}
+
+// FinishLogin parses the browser's assertion response and returns a JWT
+func (s *WebAuthnService) FinishLogin(ctx context.Context, sessionID string, body io.Reader) (string, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return "", err
+	}
+	return s.ValidateLogin(ctx, sessionID, parsed)
+}
+
+// ValidateLogin verifies a parsed assertion for a primary passkey login and returns a JWT
+func (s *WebAuthnService) ValidateLogin(ctx context.Context, sessionID string, parsed *protocol.ParsedCredentialAssertionData) (string, error) {
+	entry, ok := s.sessions.takeEntry(sessionID, webAuthnPurposeLogin)
+	if !ok {
+		return "", ErrWebAuthnSession
+	}
+
+	var user *webAuthnUser
+	if entry.username == "" {
+		_, err := s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			found, err := s.loadUserByHandle(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			user = found
+			return found, nil
+		}, *entry.session, parsed)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		if user, err = s.loadUser(entry.username); err != nil {
+			return "", err
+		}
+		if _, err := s.webAuthn.ValidateLogin(user, *entry.session, parsed); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.recordUse(user, parsed.RawID, parsed.Response.AuthenticatorData); err != nil {
+		return "", err
+	}
+	return s.loginService.IssueToken(ctx, user.user, models.LoginMethodPasskey)
+}
+
+// BeginSecondFactor starts the passkey step of a password login that returned a second-factor ticket
+func (s *WebAuthnService) BeginSecondFactor(ticket string) (*protocol.CredentialAssertion, string, error) {
+	username, err := s.loginService.ParseSecondFactorTicket(ticket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := s.loadUser(username)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(user.credentials) == 0 {
+		return nil, "", ErrNoPasskeys
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.sessions.put(webAuthnPurposeSecondFactor, username, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, sessionID, nil
+}
+
+// FinishSecondFactor verifies the passkey assertion of a second-factor login and returns a JWT
+func (s *WebAuthnService) FinishSecondFactor(ctx context.Context, sessionID string, body io.Reader) (string, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return "", err
+	}
+
+	entry, ok := s.sessions.takeEntry(sessionID, webAuthnPurposeSecondFactor)
+	if !ok {
+		return "", ErrWebAuthnSession
+	}
+
+	user, err := s.loadUser(entry.username)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.webAuthn.ValidateLogin(user, *entry.session, parsed); err != nil {
+		return "", err
+	}
+
+	if err := s.recordUse(user, parsed.RawID, parsed.Response.AuthenticatorData); err != nil {
+		return "", err
+	}
+	return s.loginService.IssueToken(ctx, user.user, models.LoginMethodMFA)
+}
+
+// ListCredentials returns the passkeys registered by a user
+func (s *WebAuthnService) ListCredentials(username string) ([]models.WebAuthnCredential, error) {
+	user, err := s.loadUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return user.credentials, nil
+}
+
+// RenameCredential changes the display name of one of the user's passkeys
+func (s *WebAuthnService) RenameCredential(username string, id uint, name string) (*models.WebAuthnCredential, error) {
+	credential, err := s.ownedCredential(username, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(credential).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	credential.Name = name
+	return credential, nil
+}
+
+// DeleteCredential removes one of the user's passkeys. Removing the last passkey
+// also turns off the passkey second factor so the user is not locked out.
+func (s *WebAuthnService) DeleteCredential(username string, id uint) error {
+	credential, err := s.ownedCredential(username, id)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(credential).Error; err != nil {
+			return err
+		}
+		var remaining int64
+		if err := tx.Model(&models.WebAuthnCredential{}).Where("user_id = ?", credential.UserID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Model(&models.User{}).Where("id = ?", credential.UserID).Update("passkey_mfa", false).Error
+		}
+		return nil
+	})
+}
+
+// SetSecondFactor requires (or stops requiring) a passkey assertion after password login
+func (s *WebAuthnService) SetSecondFactor(username string, enabled bool) error {
+	user, err := s.loadUser(username)
+	if err != nil {
+		return err
+	}
+	if enabled && len(user.credentials) == 0 {
+		return ErrNoPasskeys
+	}
+	return s.db.Model(&models.User{}).Where("id = ?", user.user.ID).Update("passkey_mfa", enabled).Error
+}
+
+// ownedCredential loads a credential by id, hiding credentials of other users
+func (s *WebAuthnService) ownedCredential(username string, id uint) (*models.WebAuthnCredential, error) {
+	var user models.User
+	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+
+	var credential models.WebAuthnCredential
+	if err := s.db.Where("id = ? AND user_id = ?", id, user.ID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// recordUse stores the new signature counter and rejects assertions from a cloned authenticator
+func (s *WebAuthnService) recordUse(user *webAuthnUser, rawID []byte, authData protocol.AuthenticatorData) error {
+	for _, credential := range user.credentials {
+		if !bytes.Equal(credential.CredentialID, rawID) {
+			continue
+		}
+		if authData.Counter != 0 && authData.Counter <= credential.SignCount {
+			slog.Warn("WebAuthn: possible cloned authenticator", "user", user.user.Username, "credential_id", credential.ID)
+			return ErrInvalidCredentials
+		}
+		now := time.Now()
+		return s.db.Model(&models.WebAuthnCredential{}).Where("id = ?", credential.ID).Updates(map[string]interface{}{
+			"sign_count":   authData.Counter,
+			"backup_state": authData.Flags.HasBackupState(),
+			"last_used_at": now,
+		}).Error
+	}
+	return ErrPasskeyNotFound
+}
+
+// decoyUser stands in for a username without passkeys in BeginLogin. Its one credential
+// is derived from the username and the JWT key, so it is the same on every request and
+// every instance, and no authenticator holds it.
+func (s *WebAuthnService) decoyUser(username string) *webAuthnUser {
+	mac := hmac.New(sha256.New, s.loginService.jwtKey)
+	mac.Write([]byte("webauthn-decoy:" + username))
+	return &webAuthnUser{
+		user:        &models.User{Username: username},
+		credentials: []models.WebAuthnCredential{{CredentialID: mac.Sum(nil)[:16]}},
+	}
+}
+
+// loadUser loads a user with its Role and passkeys
+func (s *WebAuthnService) loadUser(username string) (*webAuthnUser, error) {
+	var user models.User
+	if err := s.db.Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	return s.withCredentials(&user)
+}
+
+// loadUserByHandle resolves the user handle sent by a discoverable credential
+func (s *WebAuthnService) loadUserByHandle(userHandle []byte) (*webAuthnUser, error) {
+	id, err := strconv.ParseUint(string(userHandle), 10, 32)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var user models.User
+	if err := s.db.Preload("Role").First(&user, uint(id)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	return s.withCredentials(&user)
+}
+
+func (s *WebAuthnService) withCredentials(user *models.User) (*webAuthnUser, error) {
+	var credentials []models.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", user.ID).Order("id").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// webAuthnUser adapts models.User and its stored passkeys to webauthn.User
+type webAuthnUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+// WebAuthnID returns the user handle, which is the decimal user id
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatUint(uint64(u.user.ID), 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.First + " " + u.user.Last); name != "" {
+		return name
+	}
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// webAuthnSession is a begun ceremony waiting to be finished
+type webAuthnSession struct {
+	purpose  string
+	username string
+	session  *webauthn.SessionData
+	expires  time.Time
+}
+
+// webAuthnSessionStore keeps ceremony state in memory. Sessions are single use.
+type webAuthnSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]webAuthnSession
+}
+
+func newWebAuthnSessionStore() *webAuthnSessionStore {
+	return &webAuthnSessionStore{sessions: make(map[string]webAuthnSession)}
+}
+
+func (st *webAuthnSessionStore) put(purpose, username string, session *webauthn.SessionData) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range st.sessions {
+		if now.After(entry.expires) {
+			delete(st.sessions, key)
+		}
+	}
+	st.sessions[id] = webAuthnSession{purpose: purpose, username: username, session: session, expires: now.Add(webAuthnSessionTTL)}
+	return id, nil
+}
+
+// takeEntry removes and returns the session if it exists, is unexpired and has the given purpose
+func (st *webAuthnSessionStore) takeEntry(id, purpose string) (webAuthnSession, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	entry, ok := st.sessions[id]
+	delete(st.sessions, id)
+	if !ok || entry.purpose != purpose || time.Now().After(entry.expires) {
+		return webAuthnSession{}, false
+	}
+	return entry, true
+}
+
+// take is takeEntry for ceremonies bound to a known user
+func (st *webAuthnSessionStore) take(id, purpose, username string) (*webauthn.SessionData, bool) {
+	entry, ok := st.takeEntry(id, purpose)
+	if !ok || entry.username != username {
+		return nil, false
+	}
+	return entry.session, true
+}
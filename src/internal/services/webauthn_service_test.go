@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"gorm.io/gorm"
+
+	"go_api/src/internal/dbtest"
+	"go_api/src/internal/models"
+)
+
+const (
+	testRPID     = "localhost"
+	testRPOrigin = "http://localhost:8081"
+)
+
+// softAuthenticator is a software passkey: it answers the ceremonies of
+// navigator.credentials.create() and get() with one P-256 key and "none" attestation
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	counter      uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{t: t, key: key, credentialID: id}
+}
+
+func (a *softAuthenticator) clientData(ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(protocol.CollectedClientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    testRPOrigin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+// authData is the authenticator data for the relying party with the user present and
+// verified, and the attested credential when attested is set
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified
+	if attested {
+		flags |= protocol.FlagAttestedCredentialData
+	}
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+// create answers navigator.credentials.create() with a response body as a browser sends it
+func (a *softAuthenticator) create(creation *protocol.CredentialCreation) []byte {
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(true),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.body(map[string]any{
+		"clientDataJSON":    protocol.URLEncodedBase64(a.clientData(protocol.CreateCeremony, creation.Response.Challenge)),
+		"attestationObject": protocol.URLEncodedBase64(attestation),
+	})
+}
+
+// get answers navigator.credentials.get() with a response body as a browser sends it
+func (a *softAuthenticator) get(assertion *protocol.CredentialAssertion) []byte {
+	a.counter++
+	authData := a.authData(false)
+	clientData := a.clientData(protocol.AssertCeremony, assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.body(map[string]any{
+		"clientDataJSON":    protocol.URLEncodedBase64(clientData),
+		"authenticatorData": protocol.URLEncodedBase64(authData),
+		"signature":         protocol.URLEncodedBase64(signature),
+		"userHandle":        protocol.URLEncodedBase64(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) body(response map[string]any) []byte {
+	body, err := json.Marshal(map[string]any{
+		"id":       base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId":    protocol.URLEncodedBase64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return body
+}
+
+func newTestWebAuthnService(t *testing.T, db *gorm.DB) *WebAuthnService {
+	t.Helper()
+	service, err := NewWebAuthnService(db, NewLoginService(db, []byte("test-key")), WebAuthnConfig{
+		RPID:      testRPID,
+		RPName:    "GO API",
+		RPOrigins: []string{testRPOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// registerPasskey runs the registration ceremony for username with a new software authenticator
+func registerPasskey(t *testing.T, s *WebAuthnService, username string) *softAuthenticator {
+	t.Helper()
+	authenticator := newSoftAuthenticator(t)
+	creation, sessionID, err := s.BeginRegistration(username)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if _, err := s.FinishRegistration(username, sessionID, "laptop", bytes.NewReader(authenticator.create(creation))); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return authenticator
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	alice := dbtest.CreateUser(t, db, "alice", guest.ID)
+	s := newTestWebAuthnService(t, db)
+
+	authenticator := registerPasskey(t, s, "alice")
+	credentials, err := s.ListCredentials("alice")
+	if err != nil {
+		t.Fatalf("ListCredentials: %v", err)
+	}
+	if len(credentials) != 1 || credentials[0].Name != "laptop" || !bytes.Equal(credentials[0].CredentialID, authenticator.credentialID) {
+		t.Fatalf("credentials = %+v, want the laptop passkey", credentials)
+	}
+	if string(authenticator.userHandle) != strconv.FormatUint(uint64(alice.ID), 10) {
+		t.Errorf("user handle = %q, want the user id %d", authenticator.userHandle, alice.ID)
+	}
+
+	// The session is single use and bound to its user
+	creation, sessionID, err := s.BeginRegistration("alice")
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if len(creation.Response.CredentialExcludeList) != 1 {
+		t.Errorf("exclude list = %v, want the registered passkey", creation.Response.CredentialExcludeList)
+	}
+	dbtest.CreateUser(t, db, "bob", guest.ID)
+	body := newSoftAuthenticator(t).create(creation)
+	if _, err := s.FinishRegistration("bob", sessionID, "", bytes.NewReader(body)); !errors.Is(err, ErrWebAuthnSession) {
+		t.Errorf("FinishRegistration with another user's session: got %v, want %v", err, ErrWebAuthnSession)
+	}
+	if _, err := s.FinishRegistration("alice", sessionID, "", bytes.NewReader(body)); !errors.Is(err, ErrWebAuthnSession) {
+		t.Errorf("FinishRegistration with a used session: got %v, want %v", err, ErrWebAuthnSession)
+	}
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	dbtest.CreateUser(t, db, "alice", guest.ID)
+	s := newTestWebAuthnService(t, db)
+	authenticator := registerPasskey(t, s, "alice")
+	ctx := context.Background()
+
+	for _, username := range []string{"alice", ""} {
+		assertion, sessionID, err := s.BeginLogin(username)
+		if err != nil {
+			t.Fatalf("BeginLogin(%q): %v", username, err)
+		}
+		token, err := s.FinishLogin(ctx, sessionID, bytes.NewReader(authenticator.get(assertion)))
+		if err != nil || token == "" {
+			t.Fatalf("FinishLogin(%q): %q, %v", username, token, err)
+		}
+	}
+
+	var stored models.WebAuthnCredential
+	db.First(&stored, "credential_id = ?", authenticator.credentialID)
+	if stored.SignCount != authenticator.counter || stored.LastUsedAt == nil {
+		t.Errorf("stored credential has sign count %d and last use %v, want %d and a time", stored.SignCount, stored.LastUsedAt, authenticator.counter)
+	}
+
+	// A replayed session is refused
+	assertion, sessionID, err := s.BeginLogin("alice")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	body := authenticator.get(assertion)
+	if _, err := s.FinishLogin(ctx, sessionID, bytes.NewReader(body)); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if _, err := s.FinishLogin(ctx, sessionID, bytes.NewReader(body)); !errors.Is(err, ErrWebAuthnSession) {
+		t.Errorf("FinishLogin with a used session: got %v, want %v", err, ErrWebAuthnSession)
+	}
+
+	// So is a signature counter that doesn't move forward, the sign of a cloned authenticator
+	assertion, sessionID, err = s.BeginLogin("alice")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	authenticator.counter--
+	if _, err := s.FinishLogin(ctx, sessionID, bytes.NewReader(authenticator.get(assertion))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("FinishLogin with a stale counter: got %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestPasskeySecondFactor(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	dbtest.CreateUser(t, db, "alice", guest.ID)
+	s := newTestWebAuthnService(t, db)
+	ctx := context.Background()
+
+	if err := s.SetSecondFactor("alice", true); !errors.Is(err, ErrNoPasskeys) {
+		t.Fatalf("SetSecondFactor without passkeys: got %v, want %v", err, ErrNoPasskeys)
+	}
+	authenticator := registerPasskey(t, s, "alice")
+	if err := s.SetSecondFactor("alice", true); err != nil {
+		t.Fatalf("SetSecondFactor: %v", err)
+	}
+
+	ticket, err := s.loginService.Authenticate(ctx, "alice", "password")
+	if !errors.Is(err, ErrSecondFactorRequired) {
+		t.Fatalf("password login: got %q, %v, want a ticket and %v", ticket, err, ErrSecondFactorRequired)
+	}
+	assertion, sessionID, err := s.BeginSecondFactor(ticket)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor: %v", err)
+	}
+
+	// A second-factor session can't finish a passkey login
+	body := authenticator.get(assertion)
+	if _, err := s.FinishLogin(ctx, sessionID, bytes.NewReader(body)); !errors.Is(err, ErrWebAuthnSession) {
+		t.Errorf("FinishLogin with a second-factor session: got %v, want %v", err, ErrWebAuthnSession)
+	}
+
+	assertion, sessionID, err = s.BeginSecondFactor(ticket)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor: %v", err)
+	}
+	token, err := s.FinishSecondFactor(ctx, sessionID, bytes.NewReader(authenticator.get(assertion)))
+	if err != nil || token == "" {
+		t.Fatalf("FinishSecondFactor: %q, %v", token, err)
+	}
+
+	if _, _, err := s.BeginSecondFactor("not-a-ticket"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("BeginSecondFactor with a forged ticket: got %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestBeginLoginDoesNotRevealAccounts(t *testing.T) {
+	db := dbtest.New(t)
+	guest := dbtest.CreateRole(t, db, "guest", 0, "users.read")
+	dbtest.CreateUser(t, db, "alice", guest.ID)
+	dbtest.CreateUser(t, db, "bob", guest.ID)
+	s := newTestWebAuthnService(t, db)
+	authenticator := registerPasskey(t, s, "alice")
+
+	allowed := func(username string) []protocol.CredentialDescriptor {
+		t.Helper()
+		assertion, _, err := s.BeginLogin(username)
+		if err != nil {
+			t.Fatalf("BeginLogin(%s): %v", username, err)
+		}
+		return assertion.Response.AllowedCredentials
+	}
+	for _, username := range []string{"alice", "bob", "mallory"} {
+		first, second := allowed(username), allowed(username)
+		if len(first) != 1 || len(second) != 1 || !bytes.Equal(first[0].CredentialID, second[0].CredentialID) {
+			t.Errorf("BeginLogin(%s) allowed %v then %v, want the same single credential", username, first, second)
+		}
+	}
+	if bytes.Equal(allowed("bob")[0].CredentialID, allowed("mallory")[0].CredentialID) {
+		t.Error("BeginLogin gave bob and mallory the same credential")
+	}
+
+	// Users without passkeys can't log in with someone else's
+	assertion, sessionID, err := s.BeginLogin("bob")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	if _, err := s.FinishLogin(context.Background(), sessionID, bytes.NewReader(authenticator.get(assertion))); err == nil {
+		t.Error("FinishLogin as bob with alice's passkey succeeded")
+	}
+	assertion, sessionID, err = s.BeginLogin("mallory")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	if _, err := s.FinishLogin(context.Background(), sessionID, bytes.NewReader(authenticator.get(assertion))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("FinishLogin as an unknown user: got %v, want %v", err, ErrInvalidCredentials)
+	}
+}
@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go_api/src/internal/tracingtest"
+)
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func newTracedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&widget{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	t.Fatalf("no span %q in %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+func attributeValue(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// A request is one trace: the server span of the route, the service span started by
+// the handler and the statement span of the query the service runs
+func TestRequestSpans(t *testing.T) {
+	exporter, restore := tracingtest.NewInMemory()
+	defer restore()
+	gin.SetMode(gin.TestMode)
+	db := newTracedDB(t)
+	exporter.Reset()
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/widgets/:id", func(c *gin.Context) {
+		ctx, span := Start(c.Request.Context(), "WidgetService.GetWidget", attribute.String("widget.id", c.Param("id")))
+		defer span.End()
+		var w widget
+		if err := db.WithContext(ctx).Where("name = 'secret'").First(&w, 42).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("query: %v", err)
+		}
+		c.Status(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/widgets/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	server := spanNamed(t, spans, "GET /widgets/:id")
+	service := spanNamed(t, spans, "WidgetService.GetWidget")
+	statement := spanNamed(t, spans, "db.query")
+
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the incoming traceparent's", got)
+	}
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" || !server.Parent.IsRemote() {
+		t.Errorf("server span parent = %v, want the remote caller", server.Parent.SpanID())
+	}
+	if server.SpanKind != trace.SpanKindServer || statement.SpanKind != trace.SpanKindClient {
+		t.Errorf("span kinds = %v and %v, want server and client", server.SpanKind, statement.SpanKind)
+	}
+	if service.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("service span is not a child of the server span")
+	}
+	if statement.Parent.SpanID() != service.SpanContext.SpanID() {
+		t.Error("statement span is not a child of the service span")
+	}
+
+	if v, _ := attributeValue(server, "http.route"); v.AsString() != "/widgets/:id" {
+		t.Errorf("http.route = %q", v.AsString())
+	}
+	if v, _ := attributeValue(server, "http.response.status_code"); v.AsInt64() != http.StatusNotFound {
+		t.Errorf("http.response.status_code = %d, want 404", v.AsInt64())
+	}
+	if server.Status.Code == codes.Error {
+		t.Error("a 404 marked the server span as failed")
+	}
+	if v, _ := attributeValue(service, "widget.id"); v.AsString() != "42" {
+		t.Errorf("widget.id = %q", v.AsString())
+	}
+	if v, _ := attributeValue(statement, "db.collection.name"); v.AsString() != "widgets" {
+		t.Errorf("db.collection.name = %q", v.AsString())
+	}
+	query, _ := attributeValue(statement, "db.query.text")
+	if want := "SELECT * FROM `widgets` WHERE name = ? AND `widgets`.`id` = ? ORDER BY `widgets`.`id` LIMIT ?"; query.AsString() != want {
+		t.Errorf("db.query.text = %q, want %q", query.AsString(), want)
+	}
+	// Not found is an answer, not a failure
+	if statement.Status.Code == codes.Error {
+		t.Error("record not found marked the statement span as failed")
+	}
+}
+
+func TestServerErrorSpan(t *testing.T) {
+	exporter, restore := tracingtest.NewInMemory()
+	defer restore()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("database unreachable"))
+		c.Status(http.StatusInternalServerError)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	spans := exporter.GetSpans()
+	failed := spanNamed(t, spans, "GET /fail")
+	if failed.Status.Code != codes.Error {
+		t.Errorf("status = %v, want an error", failed.Status.Code)
+	}
+	if len(failed.Events) != 1 || failed.Events[0].Name != "exception" {
+		t.Errorf("events = %v, want the recorded error", failed.Events)
+	}
+	// Unmatched paths are named after the method only, keeping span names bounded
+	spanNamed(t, spans, "GET")
+}
+
+func TestStatementErrorSpan(t *testing.T) {
+	exporter, restore := tracingtest.NewInMemory()
+	defer restore()
+	db := newTracedDB(t)
+	exporter.Reset()
+
+	if err := db.Exec("INSERT INTO missing (name) VALUES ('x')").Error; err == nil {
+		t.Fatal("insert into a missing table succeeded")
+	}
+	statement := spanNamed(t, exporter.GetSpans(), "db.raw")
+	if statement.Status.Code != codes.Error {
+		t.Errorf("status = %v, want an error", statement.Status.Code)
+	}
+	if query, _ := attributeValue(statement, "db.query.text"); query.AsString() != "INSERT INTO missing (name) VALUES (?)" {
+		t.Errorf("db.query.text = %q", query.AsString())
+	}
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{"SELECT * FROM users WHERE email = 'a@b.c' AND id = 7", "SELECT * FROM users WHERE email = ? AND id = ?"},
+		{"UPDATE users SET first = 'O''Brien', score = -1.5", "UPDATE users SET first = ?, score = ?"},
+		{"SELECT * FROM users WHERE id = $1 LIMIT 10", "SELECT * FROM users WHERE id = $1 LIMIT ?"},
+		{"SELECT col1, t2.x FROM t2", "SELECT col1, t2.x FROM t2"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeSQL(tt.sql); got != tt.want {
+			t.Errorf("SanitizeSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
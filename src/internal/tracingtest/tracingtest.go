@@ -0,0 +1,29 @@
+// Package tracingtest records spans in memory for tests. It is kept out of package
+// tracing so the server binary doesn't link the test exporter.
+package tracingtest
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// NewInMemory installs a tracer provider that records every span synchronously into
+// an in-memory exporter, so tests can assert on exporter.GetSpans(). Call restore when done.
+func NewInMemory() (exporter *tracetest.InMemoryExporter, restore func()) {
+	exporter = tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return exporter, func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}
+}
@@ -0,0 +1,154 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"go_api/src/internal/models"
+)
+
+// Custom rules, usable in binding tags next to the built-in ones (required, email, min, max, e164, ...)
+const (
+	// RuleUsername allows letters, digits, '.', '_' and '-', starting with a letter or digit
+	RuleUsername = "username"
+	// RuleRoleName allows lower-case letters, digits, '_' and '-', starting with a letter
+	RuleRoleName = "role_name"
+	// RuleOrganizationName allows the same characters as role_name
+	RuleOrganizationName = "organization_name"
+	// RuleGroupName allows the same characters as role_name
+	RuleGroupName = "group_name"
+	// RulePermission requires a permission name from the registry
+	RulePermission = "permission"
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+)
+
+var (
+	mu          sync.RWMutex
+	permissions = map[string]struct{}{}
+)
+
+// Setup registers the custom rules on gin's validator and reports fields by their JSON
+// name. Role ids aren't checked here: whether a role is visible depends on the tenant of
+// the request, so the services check them.
+func Setup() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("validation: gin validator is not go-playground/validator")
+	}
+
+	engine.RegisterTagNameFunc(jsonName)
+	return errors.Join(
+		engine.RegisterValidation(RuleUsername, matches(usernamePattern)),
+		engine.RegisterValidation(RuleRoleName, matches(roleNamePattern)),
+		engine.RegisterValidation(RuleOrganizationName, matches(roleNamePattern)),
+		engine.RegisterValidation(RuleGroupName, matches(roleNamePattern)),
+		engine.RegisterValidation(RulePermission, knownPermission),
+	)
+}
+
+// RegisterPermissions adds names to the permissions accepted by the permission rule
+func RegisterPermissions(names ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range names {
+		permissions[name] = struct{}{}
+	}
+}
+
+// Fields converts a binding error into field errors. It reports false for errors that
+// are not about individual fields, such as malformed JSON.
+func Fields(err error) ([]models.FieldError, bool) {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]models.FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			fields = append(fields, models.FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: message(fe)})
+		}
+		return fields, true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []models.FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be of type " + typeErr.Type.String(),
+		}}, true
+	}
+	return nil, false
+}
+
+func matches(pattern *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return pattern.MatchString(fl.Field().String())
+	}
+}
+
+func knownPermission(fl validator.FieldLevel) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := permissions[fl.Field().String()]
+	return ok
+}
+
+// jsonName names fields after their JSON key, so reports match the request body
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
+
+// fieldPath drops the struct name from the namespace, e.g. "Role.permissions[1]" becomes "permissions[1]"
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+func message(fe validator.FieldError) string {
+	unit := "characters"
+	if kind := fe.Kind(); kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map {
+		unit = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s %s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s %s", fe.Param(), unit)
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +14155550123"
+	case RuleUsername:
+		return "may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"
+	case RuleRoleName, RuleOrganizationName, RuleGroupName:
+		return "may only contain lower-case letters, digits, '_' and '-', and must start with a letter"
+	case RulePermission:
+		return fmt.Sprintf("unknown permission %q", fe.Value())
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
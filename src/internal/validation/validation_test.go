@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"go_api/src/internal/models"
+)
+
+func TestMain(m *testing.M) {
+	if err := Setup(); err != nil {
+		panic(err)
+	}
+	RegisterPermissions("users.read", "users.update")
+	os.Exit(m.Run())
+}
+
+// bind decodes body into v the way handlers do and reports the failed rules
+func bind(t *testing.T, body string, v any) []models.FieldError {
+	t.Helper()
+	err := binding.JSON.BindBody([]byte(body), v)
+	if err == nil {
+		return nil
+	}
+	fields, ok := Fields(err)
+	if !ok {
+		t.Fatalf("Fields(%v) reported no field errors", err)
+	}
+	return fields
+}
+
+func TestCustomRules(t *testing.T) {
+	const user = `"email": "ada@example.com", "password": "long-enough"`
+	tests := []struct {
+		name string
+		body string
+		v    any
+		want []models.FieldError
+	}{
+		{name: "valid user", body: `{"username": "ada.lovelace_1-x", ` + user + `}`, v: &models.UserCreateRequest{}},
+		{name: "username starting with a dot", body: `{"username": ".ada", ` + user + `}`, v: &models.UserCreateRequest{}, want: []models.FieldError{
+			{Field: "username", Rule: RuleUsername, Message: "may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"},
+		}},
+		{name: "username with a space", body: `{"username": "ada lovelace", ` + user + `}`, v: &models.UserCreateRequest{}, want: []models.FieldError{
+			{Field: "username", Rule: RuleUsername, Message: "may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"},
+		}},
+		{name: "valid role", body: `{"name": "help-desk_2", "permissions": ["users.read"]}`, v: &models.Role{}},
+		{name: "role name with upper case", body: `{"name": "Admin"}`, v: &models.Role{}, want: []models.FieldError{
+			{Field: "name", Rule: RuleRoleName, Message: "may only contain lower-case letters, digits, '_' and '-', and must start with a letter"},
+		}},
+		{name: "role name starting with a digit", body: `{"name": "1st"}`, v: &models.Role{}, want: []models.FieldError{
+			{Field: "name", Rule: RuleRoleName, Message: "may only contain lower-case letters, digits, '_' and '-', and must start with a letter"},
+		}},
+		{name: "unknown permission", body: `{"name": "editor", "permissions": ["users.read", "users.fly"]}`, v: &models.Role{}, want: []models.FieldError{
+			{Field: "permissions[1]", Rule: RulePermission, Message: `unknown permission "users.fly"`},
+		}},
+		{name: "organization name", body: `{"name": "Acme Inc"}`, v: &models.Organization{}, want: []models.FieldError{
+			{Field: "name", Rule: RuleOrganizationName, Message: "may only contain lower-case letters, digits, '_' and '-', and must start with a letter"},
+		}},
+		{name: "group name", body: `{"name": "support!"}`, v: &models.Group{}, want: []models.FieldError{
+			{Field: "name", Rule: RuleGroupName, Message: "may only contain lower-case letters, digits, '_' and '-', and must start with a letter"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bind(t, tt.body, tt.v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Every failed rule of every field is reported, named after the JSON key
+func TestFieldsReportsEveryRule(t *testing.T) {
+	body := `{"username": "a", "email": "not-an-email", "password": "short", "phone": "555-0100", "last": "` + strings.Repeat("x", 101) + `"}`
+	got := bind(t, body, &models.UserCreateRequest{})
+	want := []models.FieldError{
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+		{Field: "username", Rule: "min", Message: "must be at least 3 characters"},
+		{Field: "password", Rule: "min", Message: "must be at least 8 characters"},
+		{Field: "last", Rule: "max", Message: "must be at most 100 characters"},
+		{Field: "phone", Rule: "e164", Message: "must be a phone number in E.164 format, e.g. +14155550123"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	got = bind(t, `{}`, &models.UserCreateRequest{})
+	for _, fe := range got {
+		if fe.Rule != "required" || fe.Message != "is required" {
+			t.Errorf("empty body: got %+v, want only required rules", fe)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("empty body: got %d errors, want email, username and password", len(got))
+	}
+}
+
+func TestFieldsOfDecodingErrors(t *testing.T) {
+	got := bind(t, `{"parent_ids": "2"}`, &models.RoleUpdateRequest{})
+	want := []models.FieldError{{Field: "parent_ids", Rule: "type", Message: "must be of type []uint"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Malformed JSON is not about a field: the handler answers it with invalid_request
+	err := binding.JSON.BindBody([]byte(`{"name": `), &models.Role{})
+	if fields, ok := Fields(err); ok {
+		t.Errorf("Fields(%v) = %+v, want no field errors", err, fields)
+	}
+}
@@ -31,7 +31,7 @@ func main() {
 		log.Printf("bcrypt.CompareHashAndPassword FAILED with generated hash: %v", err)
 		fmt.Println("bcrypt comparison failed with generated hash")
 	} else {
-		log.Println("bcrypt.CompareHashAndPassword SUCCEEDED with generated hash %s", hashedString)
+		log.Printf("bcrypt.CompareHashAndPassword SUCCEEDED with generated hash %s", hashedString)
 		fmt.Println("bcrypt comparison succeeded with generated hash")
 	}
 }